@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Bookmarks are named directories plus vim-style single-letter marks,
+// saved in the state directory.
+type Bookmarks struct {
+	path  string
+	Named map[string]string `json:"bookmarks,omitempty"` // name -> directory
+	Marks map[string]string `json:"marks,omitempty"`     // letter -> directory
+}
+
+// LoadBookmarks reads bookmarks from path; a missing or corrupt file
+// yields none.
+func LoadBookmarks(path string) *Bookmarks {
+	b := &Bookmarks{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		b.Named, b.Marks = nil, nil
+	}
+	return b
+}
+
+func (b *Bookmarks) save() error {
+	return writeStateFile(b.path, b)
+}
+
+func (b *Bookmarks) add(name, dir string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("bookmark name cannot be empty")
+	}
+	if b.Named == nil {
+		b.Named = make(map[string]string)
+	}
+	b.Named[name] = dir
+	return b.save()
+}
+
+func (b *Bookmarks) remove(name string) error {
+	delete(b.Named, name)
+	return b.save()
+}
+
+func (b *Bookmarks) setMark(letter rune, dir string) error {
+	if b.Marks == nil {
+		b.Marks = make(map[string]string)
+	}
+	b.Marks[string(letter)] = dir
+	return b.save()
+}
+
+func (b *Bookmarks) mark(letter rune) (string, bool) {
+	dir, ok := b.Marks[string(letter)]
+	return dir, ok
+}
+
+// pickerItems lists the bookmarks by name for the picker.
+func (b *Bookmarks) pickerItems() []PickerItem {
+	names := make([]string, 0, len(b.Named))
+	for name := range b.Named {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	items := make([]PickerItem, len(names))
+	for i, name := range names {
+		items[i] = PickerItem{Label: name, Detail: b.Named[name]}
+	}
+	return items
+}
+
+// jumpTo opens dir, as bookmarks and marks do.
+func (app *App) jumpTo(dir string) {
+	if err := app.navigator.changeDirectory(dir); err != nil {
+		app.statusBar.showError("Cannot open " + dir + ": " + err.Error())
+	}
+}
+
+func (app *App) startAddBookmark() {
+	app.showPopup(PopupBookmark, "Bookmark this directory", "Name: ", filepath.Base(app.navigator.currentPath), nil)
+}
+
+func (app *App) addBookmark(name string) {
+	dir := app.navigator.currentPath
+	if err := app.bookmarks.add(name, dir); err != nil {
+		app.statusBar.showError("Cannot save bookmark: " + err.Error())
+		return
+	}
+	app.statusBar.showMessage("Bookmarked " + dir + " as " + strings.TrimSpace(name))
+}
+
+func (app *App) showBookmarks() {
+	app.showPicker(&Picker{
+		title: "Bookmarks",
+		items: app.bookmarks.pickerItems(),
+		empty: "No bookmarks yet - " + app.keys().hint("bookmark.add") + " adds this directory",
+		onSelect: func(app *App, item PickerItem, _ int) {
+			app.jumpTo(item.Detail)
+		},
+		onDelete: func(app *App, item PickerItem) {
+			if err := app.bookmarks.remove(item.Label); err != nil {
+				app.statusBar.showError("Cannot save bookmarks: " + err.Error())
+			}
+			app.picker.setItems(app.bookmarks.pickerItems())
+		},
+	})
+}
+
+// MarkPrompt is the mark command waiting for its letter.
+type MarkPrompt int
+
+const (
+	MarkNone MarkPrompt = iota
+	MarkSet
+	MarkJump
+)
+
+// handleMarkLetter finishes "m<letter>" or "'<letter>". Any key that isn't
+// a letter cancels.
+func (app *App) handleMarkLetter(letter rune) {
+	prompt := app.markPrompt
+	app.markPrompt = MarkNone
+	if !unicode.IsLetter(letter) {
+		return
+	}
+
+	dir := app.navigator.currentPath
+	switch prompt {
+	case MarkSet:
+		if err := app.bookmarks.setMark(letter, dir); err != nil {
+			app.statusBar.showError("Cannot save mark: " + err.Error())
+			return
+		}
+		app.statusBar.showMessage("Mark '" + string(letter) + " set to " + dir)
+	case MarkJump:
+		target, ok := app.bookmarks.mark(letter)
+		if !ok {
+			app.statusBar.showError("Mark '" + string(letter) + " is not set")
+			return
+		}
+		app.jumpTo(target)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Detail columns, drawn right-aligned after the name.
+const (
+	ColumnSize        = "size"
+	ColumnTime        = "mtime"
+	ColumnPermissions = "permissions"
+	ColumnOwner       = "owner"
+)
+
+var allColumns = []string{ColumnSize, ColumnTime, ColumnPermissions, ColumnOwner}
+
+const (
+	TimeRelative = "relative" // "5m ago"
+	TimeAbsolute = "absolute" // "2006-01-02 15:04"
+)
+
+// minNameWidth is the room kept for names; columns hide from the end of
+// the configured list until it fits.
+const minNameWidth = 20
+
+const maxOwnerWidth = 20
+
+type columnLayout struct {
+	name  string
+	width int
+}
+
+func (app *App) toggleDetails() {
+	app.details = !app.details
+	if app.details {
+		app.statusBar.showMessage("Details on")
+	} else {
+		app.statusBar.showMessage("Details off")
+	}
+}
+
+// detailColumns picks the columns that fit in width for items, returning
+// them with the total width they take including separating spaces.
+func (app *App) detailColumns(items []FileItem, width int) ([]columnLayout, int) {
+	if !app.details {
+		return nil, 0
+	}
+	var columns []columnLayout
+	total := 0
+	for _, name := range app.cfg().Columns {
+		w := app.columnWidth(name, items)
+		if width-(total+w+2) < minNameWidth {
+			break
+		}
+		columns = append(columns, columnLayout{name, w})
+		total += w + 2
+	}
+	return columns, total
+}
+
+func (app *App) columnWidth(name string, items []FileItem) int {
+	switch name {
+	case ColumnSize:
+		return len("1023.9 KB")
+	case ColumnTime:
+		switch layout := app.cfg().TimeFormat; layout {
+		case TimeRelative:
+			return len("11mo ago")
+		case TimeAbsolute:
+			return len("2006-01-02 15:04")
+		default:
+			return utf8.RuneCountInString(time.Date(2006, 12, 22, 22, 22, 22, 0, time.UTC).Format(layout))
+		}
+	case ColumnPermissions:
+		return len("drwxr-xr-x")
+	case ColumnOwner:
+		w := 0
+		for _, item := range items {
+			w = max(w, utf8.RuneCountInString(ownerText(item)))
+		}
+		return min(w, maxOwnerWidth)
+	}
+	return 0
+}
+
+func (app *App) columnText(name string, item FileItem, now time.Time) string {
+	switch name {
+	case ColumnSize:
+		if item.IsDir {
+			return "-"
+		}
+		return formatSize(item.Size)
+	case ColumnTime:
+		switch layout := app.cfg().TimeFormat; layout {
+		case TimeRelative:
+			return formatAge(item.ModTime, now)
+		case TimeAbsolute:
+			return item.ModTime.Format("2006-01-02 15:04")
+		default:
+			return item.ModTime.Format(layout)
+		}
+	case ColumnPermissions:
+		return item.Mode.String()
+	case ColumnOwner:
+		return ownerText(item)
+	}
+	return ""
+}
+
+// formatAge describes how long ago t was, in at most eight characters.
+func formatAge(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(d/(30*24*time.Hour)))
+	}
+	return fmt.Sprintf("%dy ago", int(d/(365*24*time.Hour)))
+}
+
+// Owner names are looked up once per id; unknown ids show as numbers.
+var (
+	userNames  = make(map[int]string)
+	groupNames = make(map[int]string)
+)
+
+func ownerText(item FileItem) string {
+	if item.UID < 0 {
+		return "-"
+	}
+	return lookupName(userNames, item.UID, func(id string) (string, error) {
+		u, err := user.LookupId(id)
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	}) + ":" + lookupName(groupNames, item.GID, func(id string) (string, error) {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	})
+}
+
+func lookupName(cache map[int]string, id int, lookup func(string) (string, error)) string {
+	if name, ok := cache[id]; ok {
+		return name
+	}
+	name, err := lookup(strconv.Itoa(id))
+	if err != nil {
+		name = strconv.Itoa(id)
+	}
+	cache[id] = name
+	return name
+}
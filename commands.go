@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxLoggedRuns   = 50
+	maxLoggedOutput = 64 << 10 // bytes kept per run; the start is dropped first
+)
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellCommand runs script with the system shell.
+func shellCommand(script string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", script}
+	}
+	return []string{"sh", "-c", script}
+}
+
+// expandCommand fills in the placeholders of a user command: {file} and
+// {name} are the selected item's path and name, {files} the marked items
+// (or the selected one) and {dir} the current directory.
+func expandCommand(script string, nav *Navigator) (string, error) {
+	pairs := []string{"{dir}", shellQuote(nav.currentPath)}
+	if selected := nav.getSelectedItem(); selected != nil {
+		pairs = append(pairs, "{file}", shellQuote(selected.Path), "{name}", shellQuote(selected.Name))
+	} else if strings.Contains(script, "{file}") || strings.Contains(script, "{name}") {
+		return "", errors.New("nothing is selected")
+	}
+	var files []string
+	for _, item := range nav.targetItems() {
+		files = append(files, shellQuote(item.Path))
+	}
+	if len(files) == 0 && strings.Contains(script, "{files}") {
+		return "", errors.New("nothing is selected")
+	}
+	pairs = append(pairs, "{files}", strings.Join(files, " "))
+	return strings.NewReplacer(pairs...).Replace(script), nil
+}
+
+// CommandRun is one background command and the output it has produced.
+type CommandRun struct {
+	log     *CommandLog
+	name    string
+	script  string // as run, placeholders filled in
+	started time.Time
+	output  []byte
+	dropped bool // output was cut to maxLoggedOutput
+	cmd     *exec.Cmd
+	done    bool
+	stopped bool // killed by the user
+	err     error
+}
+
+// Write collects output; commands write from their own goroutines.
+func (r *CommandRun) Write(p []byte) (int, error) {
+	r.log.mu.Lock()
+	r.output = append(r.output, p...)
+	if over := len(r.output) - maxLoggedOutput; over > 0 {
+		r.output = append(r.output[:0], r.output[over:]...)
+		r.dropped = true
+	}
+	r.log.mu.Unlock()
+	if r.log.changed != nil {
+		r.log.changed()
+	}
+	return len(p), nil
+}
+
+// CommandLog keeps the latest background runs for the log viewer.
+type CommandLog struct {
+	mu      sync.Mutex
+	runs    []*CommandRun
+	changed func() // called when output arrives; may be nil
+}
+
+func (l *CommandLog) start(name, script string) *CommandRun {
+	run := &CommandRun{log: l, name: name, script: script, started: time.Now()}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.runs = append(l.runs, run)
+	if len(l.runs) > maxLoggedRuns {
+		l.runs = l.runs[len(l.runs)-maxLoggedRuns:]
+	}
+	return run
+}
+
+// errStopped is how a command the user stopped ended.
+var errStopped = errors.New("stopped")
+
+// finish records how run ended and returns it.
+func (l *CommandLog) finish(run *CommandRun, err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if run.stopped {
+		err = errStopped
+	}
+	run.done, run.err = true, err
+	return err
+}
+
+// lines renders the log, oldest run first.
+func (l *CommandLog) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.runs) == 0 {
+		return []string{"No background commands have run yet"}
+	}
+	var lines []string
+	for _, run := range l.runs {
+		lines = append(lines, fmt.Sprintf("[%s] %s - %s", run.started.Format("15:04:05"), run.name, run.state()), "$ "+run.script)
+		lines = append(run.appendOutput(lines), "")
+	}
+	return lines[:len(lines)-1]
+}
+
+// outputLines is the output of a single run, ending with how it exited
+// once it has.
+func (run *CommandRun) outputLines() []string {
+	run.log.mu.Lock()
+	defer run.log.mu.Unlock()
+	lines := run.appendOutput(nil)
+	if run.done {
+		lines = append(lines, "", "-- "+run.state()+" --")
+	}
+	return lines
+}
+
+// state and appendOutput expect the log to be locked.
+func (run *CommandRun) state() string {
+	switch {
+	case !run.done:
+		return "running"
+	case run.err == errStopped:
+		return "stopped"
+	case run.err != nil:
+		return "failed: " + run.err.Error()
+	}
+	return "done"
+}
+
+func (run *CommandRun) appendOutput(lines []string) []string {
+	if run.dropped {
+		lines = append(lines, "...")
+	}
+	if output := strings.TrimRight(string(run.output), "\n"); output != "" {
+		lines = append(lines, strings.Split(output, "\n")...)
+	}
+	return lines
+}
+
+// commandActions turns the configured commands into actions, so they can
+// be bound, listed in help and picked from the palette.
+func commandActions(commands []CommandConfig) []*Action {
+	var list []*Action
+	for _, command := range commands {
+		command := command
+		description := command.Description
+		if description == "" {
+			description = command.Run
+		}
+		list = append(list, &Action{
+			Name:        "command." + command.Name,
+			Context:     ContextNormal,
+			Section:     "Commands",
+			Description: description,
+			Keys:        command.Keys,
+			Run:         func(app *App) { app.runUserCommand(command) },
+		})
+	}
+	return list
+}
+
+// runUserCommand runs a configured command in the current directory. In
+// the foreground it gets the terminal; in the background its output goes
+// to the command log.
+func (app *App) runUserCommand(command CommandConfig) {
+	script, err := expandCommand(command.Run, app.navigator)
+	if err != nil {
+		app.statusBar.showError(command.Name + ": " + err.Error())
+		return
+	}
+	shell := shellCommand(script)
+	if !command.Background {
+		run := app.runPaused
+		if !command.Pause {
+			run = app.runSuspended
+		}
+		if err := run(shell[0], shell[1:]...); err != nil {
+			app.statusBar.showError(command.Name + " failed: " + err.Error())
+		}
+		app.navigator.reload()
+		return
+	}
+
+	_, err = app.startLogged(command.Name, script, shell, func(err error) {
+		if err != nil {
+			app.statusBar.showError(fmt.Sprintf("%s failed: %v (%s: log)", command.Name, err, app.keys().hint("app.log")))
+		} else {
+			app.statusBar.showMessage(command.Name + " finished")
+		}
+	})
+	if err != nil {
+		app.statusBar.showError(command.Name + " failed: " + err.Error())
+		return
+	}
+	app.statusBar.showMessage("Running " + command.Name + " in the background")
+}
+
+func (run *CommandRun) running() bool {
+	run.log.mu.Lock()
+	defer run.log.mu.Unlock()
+	return !run.done
+}
+
+// stop kills the command and everything it started, if it is still running.
+func (run *CommandRun) stop() {
+	run.log.mu.Lock()
+	defer run.log.mu.Unlock()
+	if run.done || run.stopped || run.cmd == nil {
+		return
+	}
+	if killProcessGroup(run.cmd) == nil {
+		run.stopped = true
+	}
+}
+
+// startLogged starts cmd in the current directory without the terminal,
+// its output going to the command log under name and script. Once it
+// exits, done is called on the UI goroutine and the listing refreshed if
+// still in the same directory.
+func (app *App) startLogged(name, script string, cmd []string, done func(err error)) (*CommandRun, error) {
+	run := app.commandLog.start(name, script)
+	process := exec.Command(cmd[0], cmd[1:]...)
+	process.Dir = app.navigator.currentPath
+	process.Stdout, process.Stderr = run, run
+	ownProcessGroup(process)
+	// Don't wait forever on output from programs that escaped a stop
+	process.WaitDelay = time.Second
+	if err := process.Start(); err != nil {
+		app.commandLog.finish(run, err)
+		return nil, err
+	}
+	run.cmd = process
+	go func() {
+		err := process.Wait()
+		app.postUI(func() {
+			done(app.commandLog.finish(run, err))
+			// The command may have changed files here
+			if app.navigator.currentPath == process.Dir {
+				app.navigator.reload()
+			}
+		})
+	}()
+	return run, nil
+}
+
+func (app *App) showCommandLog() {
+	app.showPager("Command log", app.commandLog.lines, true)
+}
+
+// showPalette lists every action of the file list, configured commands
+// included, to run one by name.
+func (app *App) showPalette() {
+	var items []PickerItem
+	var list []*Action
+	for _, action := range app.keys().actions {
+		if action.Context != ContextNormal || action.Name == "app.palette" {
+			continue
+		}
+		detail := action.Name
+		if keys := app.keys().keysFor(action.Name); len(keys) > 0 {
+			detail = app.keys().hint(action.Name)
+		}
+		items = append(items, PickerItem{Label: action.Description, Detail: detail})
+		list = append(list, action)
+	}
+	app.showPicker(&Picker{
+		title: "Commands",
+		items: items,
+		onSelect: func(app *App, _ PickerItem, index int) {
+			list[index].Run(app)
+		},
+	})
+}
+
+// validCommandName reports whether name can follow "command." in an action name.
+func validCommandName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
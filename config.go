@@ -0,0 +1,805 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type SortOptions struct {
+	By            SortMode `json:"by"`
+	DirsFirst     bool     `json:"dirs_first"`
+	CaseSensitive bool     `json:"case_sensitive"`
+	Reverse       bool     `json:"reverse"`
+}
+
+// FilterOptions choose which directory entries are listed.
+type FilterOptions struct {
+	ShowHidden bool
+	GitIgnore  bool     // honor .gitignore (inside repositories) and .ignore files
+	Ignore     []string // name globs that are never listed
+}
+
+// OpenerRule opens files matching any of its MIME patterns ("image/*"),
+// extensions or name globs with Command.
+type OpenerRule struct {
+	MIME       []string
+	Extensions []string
+	Globs      []string
+	Command    string // shell words; {file} is replaced, or the file appended
+	Background bool   // a windowed program started alongside powpow
+}
+
+// CommandConfig is a user-defined command. Run is a shell script in which
+// {file}, {files}, {name} and {dir} are replaced, shell-quoted.
+type CommandConfig struct {
+	Name        string // the action is "command.<Name>"
+	Description string
+	Run         string
+	Keys        []string
+	Background  bool // run alongside powpow, output going to the command log
+	Pause       bool // wait for Enter after a terminal command, keeping its output on screen
+}
+
+// ColorConfig overrides single colors of the active theme; unset fields
+// are tcell.ColorDefault and leave the theme alone.
+type ColorConfig struct {
+	Directory  tcell.Color
+	File       tcell.Color
+	Hidden     tcell.Color
+	Marked     tcell.Color
+	SelectedFg tcell.Color
+	SelectedBg tcell.Color
+	BarFg      tcell.Color // breadcrumb and status bar
+	BarBg      tcell.Color
+	SearchFg   tcell.Color
+	SearchBg   tcell.Color
+	ErrorFg    tcell.Color
+	ErrorBg    tcell.Color
+}
+
+// SyntaxConfig describes a user-defined highlighter for extra file types.
+type SyntaxConfig struct {
+	Extensions    []string
+	Keywords      []string
+	Types         []string
+	LineComments  []string
+	BlockComments [][2]string
+	Quotes        string
+}
+
+type Config struct {
+	TextExtensions []string
+	StatusTimeout  time.Duration
+	PageSize       int
+	Sort           SortOptions
+	Filter         FilterOptions
+	FindMaxDepth   int    // directory levels the finder descends
+	FindMaxFiles   int    // entries the finder collects before stopping
+	GrepMaxMatches int    // matching lines grep collects before stopping
+	SortRemember   string // RememberGlobal, RememberDirectory or RememberNone
+	Details        bool   // start with detail columns shown
+	Columns        []string
+	TimeFormat     string // TimeRelative, TimeAbsolute or a Go time layout
+	ThemeName      string // empty unless chosen in the config file
+	Themes         map[string]map[string]string
+	Theme          *Theme
+	UseLSColors    bool
+	Watch          bool              // refresh the listing when the directory changes on disk
+	Editor         string            // used when $VISUAL and $EDITOR are unset
+	EditorLines    map[string]string // editor name -> line argument template
+	ExitAfterEdit  bool              // quit once the editor closes instead of returning to the listing
+	EditNewFiles   bool              // open files created in powpow in the editor
+	Openers        []OpenerRule      // tried in order before the editor and the system opener
+	Commands       []CommandConfig
+	Colors         ColorConfig
+	Syntax         []SyntaxConfig
+	Keys           map[string][]string // action name -> key specs, replacing its defaults
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		TextExtensions: []string{
+			".txt", ".md", ".py", ".js", ".json", ".yaml", ".yml", ".html", ".css",
+			".sh", ".conf", ".cfg", ".ini", ".log", ".sql", ".xml", ".csv", ".toml",
+			".rs", ".go", ".c", ".cpp", ".h", ".hpp", ".java", ".php", ".rb", ".pl",
+			".ts", ".jsx", ".tsx", ".vue", ".svelte", ".scss", ".sass", ".less",
+		},
+		StatusTimeout:  2 * time.Second,
+		PageSize:       10,
+		Sort:           SortOptions{By: SortName, DirsFirst: true},
+		Filter:         FilterOptions{ShowHidden: true},
+		FindMaxDepth:   20,
+		FindMaxFiles:   100000,
+		GrepMaxMatches: 10000,
+		SortRemember:   RememberGlobal,
+		Columns:        allColumns,
+		TimeFormat:     TimeRelative,
+		Theme:          builtinTheme("dark"),
+		UseLSColors:    true,
+		Watch:          true,
+		EditNewFiles:   true,
+	}
+}
+
+// defaultConfig backs Apps created without a loaded config, such as in tests.
+var defaultConfig = DefaultConfig()
+
+func (app *App) cfg() *Config {
+	if app.config != nil {
+		return app.config
+	}
+	return defaultConfig
+}
+
+// ConfigError lists every problem found in a config file.
+type ConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid config %s:\n  %s", e.Path, strings.Join(e.Problems, "\n  "))
+}
+
+func configDir() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "powpow")
+}
+
+// findConfigFile looks in $XDG_CONFIG_HOME, then each of $XDG_CONFIG_DIRS.
+func findConfigFile() string {
+	candidates := []string{filepath.Join(configDir(), "config.toml")}
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		candidates = append(candidates, filepath.Join(dir, "powpow", "config.toml"))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads the config at path, or the XDG default when path is empty.
+// A missing default file is not an error; a missing explicit file is.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = findConfigFile()
+		if path == "" {
+			config := DefaultConfig()
+			config.applyEnvironment(os.Getenv("NO_COLOR"), os.Getenv("LS_COLORS"))
+			return config, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config: %v", err)
+	}
+	config, err := parseConfig(path, string(data))
+	if err != nil {
+		return nil, err
+	}
+	config.applyEnvironment(os.Getenv("NO_COLOR"), os.Getenv("LS_COLORS"))
+	return config, nil
+}
+
+// applyEnvironment honors NO_COLOR (unless the config names a theme, as
+// https://no-color.org allows) and LS_COLORS for file list colors.
+func (c *Config) applyEnvironment(noColor, lsColors string) {
+	if noColor != "" && c.ThemeName == "" {
+		c.Theme = builtinTheme("mono")
+		return
+	}
+	if c.UseLSColors && lsColors != "" {
+		c.Theme.LSColors = parseLSColors(lsColors)
+	}
+}
+
+func parseConfig(path, data string) (*Config, error) {
+	root, err := parseTOML(data)
+	if err != nil {
+		return nil, &ConfigError{Path: path, Problems: []string{err.Error()}}
+	}
+
+	config := DefaultConfig()
+	d := &configDecoder{}
+	d.decode(root, config)
+	if len(d.problems) > 0 {
+		return nil, &ConfigError{Path: path, Problems: d.problems}
+	}
+	return config, nil
+}
+
+// hasCommand reports whether action names one of the configured commands.
+func (c *Config) hasCommand(action string) bool {
+	name, ok := strings.CutPrefix(action, "command.")
+	return ok && slices.ContainsFunc(c.Commands, func(command CommandConfig) bool { return command.Name == name })
+}
+
+// registerLexers installs the user-defined [[syntax]] highlighters.
+func (c *Config) registerLexers() {
+	for _, syntax := range c.Syntax {
+		registerLexer(&GenericLexer{
+			Keywords:      syntax.Keywords,
+			Types:         syntax.Types,
+			LineComments:  syntax.LineComments,
+			BlockComments: syntax.BlockComments,
+			Quotes:        syntax.Quotes,
+		}, syntax.Extensions...)
+	}
+}
+
+// configDecoder copies values from a parsed TOML tree into a Config,
+// collecting every problem instead of stopping at the first.
+type configDecoder struct {
+	problems []string
+}
+
+func (d *configDecoder) errorf(key, format string, args ...any) {
+	d.problems = append(d.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *configDecoder) decode(root map[string]any, config *Config) {
+	d.checkKeys(root, "", "files", "ui", "sort", "find", "themes", "colors", "syntax", "keys", "editor", "opener", "command")
+
+	if files := d.table(root, "files"); files != nil {
+		d.checkKeys(files, "files.", "text_extensions", "extra_text_extensions", "show_hidden", "gitignore", "ignore")
+		d.extensionList(files, "files.text_extensions", &config.TextExtensions)
+		var extra []string
+		d.extensionList(files, "files.extra_text_extensions", &extra)
+		config.TextExtensions = append(config.TextExtensions, extra...)
+		d.boolean(files, "files.show_hidden", &config.Filter.ShowHidden)
+		d.boolean(files, "files.gitignore", &config.Filter.GitIgnore)
+		d.stringList(files, "files.ignore", &config.Filter.Ignore)
+		for _, glob := range config.Filter.Ignore {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				d.errorf("files.ignore", "bad pattern %q", glob)
+			}
+		}
+	}
+
+	if ui := d.table(root, "ui"); ui != nil {
+		d.checkKeys(ui, "ui.", "status_timeout", "page_size", "theme", "ls_colors", "details", "columns", "time_format", "watch")
+		d.duration(ui, "ui.status_timeout", &config.StatusTimeout)
+		d.integer(ui, "ui.page_size", 1, 1000, &config.PageSize)
+		d.str(ui, "ui.theme", &config.ThemeName)
+		d.boolean(ui, "ui.ls_colors", &config.UseLSColors)
+		d.boolean(ui, "ui.details", &config.Details)
+		d.boolean(ui, "ui.watch", &config.Watch)
+		d.stringList(ui, "ui.columns", &config.Columns)
+		for _, column := range config.Columns {
+			if !slices.Contains(allColumns, column) {
+				d.errorf("ui.columns", "unknown column %q (use %s)", column, strings.Join(allColumns, ", "))
+			}
+		}
+		d.str(ui, "ui.time_format", &config.TimeFormat)
+		if config.TimeFormat == "" {
+			d.errorf("ui.time_format", "must not be empty")
+		}
+	}
+
+	if editor := d.table(root, "editor"); editor != nil {
+		d.checkKeys(editor, "editor.", "command", "line_args", "exit_after_edit", "edit_new_files")
+		d.str(editor, "editor.command", &config.Editor)
+		if _, err := shellWords(config.Editor); err != nil {
+			d.errorf("editor.command", "%v", err)
+		}
+		if lines := d.table(editor, "line_args"); lines != nil {
+			config.EditorLines = make(map[string]string)
+			for _, name := range sortedKeys(lines) {
+				key := "editor.line_args." + name
+				template, ok := lines[name].(string)
+				if !ok {
+					d.errorf(key, "expected a string, got %v", lines[name])
+					continue
+				}
+				if _, err := shellWords(template); err != nil {
+					d.errorf(key, "%v", err)
+				} else if !strings.Contains(template, "{line}") {
+					d.errorf(key, "template must contain {line}")
+				} else {
+					config.EditorLines[name] = template
+				}
+			}
+		}
+		d.boolean(editor, "editor.exit_after_edit", &config.ExitAfterEdit)
+		d.boolean(editor, "editor.edit_new_files", &config.EditNewFiles)
+	}
+
+	if themes := d.table(root, "themes"); themes != nil {
+		config.Themes = make(map[string]map[string]string)
+		for _, name := range sortedKeys(themes) {
+			table := d.table(themes, name)
+			if table == nil {
+				continue
+			}
+			slots := (&Theme{}).slots()
+			specs := make(map[string]string)
+			for _, key := range sortedKeys(table) {
+				path := "themes." + name + "." + key
+				spec, ok := table[key].(string)
+				if !ok {
+					d.errorf(path, "expected a string, got %v", table[key])
+					continue
+				}
+				if _, isSlot := slots[key]; !isSlot && key != "inherit" {
+					d.errorf(path, "unknown setting")
+					continue
+				}
+				if key != "inherit" {
+					if _, err := parseStyle(spec); err != nil {
+						d.errorf(path, "%v", err)
+						continue
+					}
+				}
+				specs[key] = spec
+			}
+			config.Themes[name] = specs
+		}
+	}
+	d.theme(config)
+
+	if find := d.table(root, "find"); find != nil {
+		d.checkKeys(find, "find.", "max_depth", "max_files", "max_matches")
+		d.integer(find, "find.max_depth", 1, 1000, &config.FindMaxDepth)
+		d.integer(find, "find.max_files", 1, 10000000, &config.FindMaxFiles)
+		d.integer(find, "find.max_matches", 1, 1000000, &config.GrepMaxMatches)
+	}
+
+	if sortTable := d.table(root, "sort"); sortTable != nil {
+		d.checkKeys(sortTable, "sort.", "by", "dirs_first", "case_sensitive", "reverse", "remember")
+		var by string
+		d.str(sortTable, "sort.by", &by)
+		if by != "" {
+			if mode := SortMode(strings.ToLower(by)); validSortMode(mode) {
+				config.Sort.By = mode
+			} else {
+				d.errorf("sort.by", "unknown sort %q (use one of %v)", by, sortModes)
+			}
+		}
+		d.str(sortTable, "sort.remember", &config.SortRemember)
+		switch config.SortRemember {
+		case RememberGlobal, RememberDirectory, RememberNone:
+		default:
+			d.errorf("sort.remember", "expected \"global\", \"directory\" or \"none\", got %q", config.SortRemember)
+		}
+		d.boolean(sortTable, "sort.dirs_first", &config.Sort.DirsFirst)
+		d.boolean(sortTable, "sort.case_sensitive", &config.Sort.CaseSensitive)
+		d.boolean(sortTable, "sort.reverse", &config.Sort.Reverse)
+	}
+
+	if colors := d.table(root, "colors"); colors != nil && config.Theme != nil {
+		c := &config.Colors
+		fields := map[string]*tcell.Color{
+			"directory": &c.Directory, "file": &c.File, "hidden": &c.Hidden, "marked": &c.Marked,
+			"selected_fg": &c.SelectedFg, "selected_bg": &c.SelectedBg,
+			"bar_fg": &c.BarFg, "bar_bg": &c.BarBg,
+			"search_fg": &c.SearchFg, "search_bg": &c.SearchBg,
+			"error_fg": &c.ErrorFg, "error_bg": &c.ErrorBg,
+		}
+		d.checkKeys(colors, "colors.", sortedKeys(fields)...)
+		for _, name := range sortedKeys(fields) {
+			d.color(colors, "colors."+name, fields[name])
+		}
+		config.Theme.applyColors(c)
+	}
+
+	for i, syntax := range d.tableArray(root, "syntax") {
+		prefix := fmt.Sprintf("syntax[%d].", i)
+		d.checkKeys(syntax, prefix, "extensions", "keywords", "types", "line_comments", "block_comment", "quotes")
+		var s SyntaxConfig
+		d.extensionList(syntax, prefix+"extensions", &s.Extensions)
+		if len(s.Extensions) == 0 {
+			d.errorf(prefix+"extensions", "at least one extension is required")
+		}
+		d.stringList(syntax, prefix+"keywords", &s.Keywords)
+		d.stringList(syntax, prefix+"types", &s.Types)
+		d.stringList(syntax, prefix+"line_comments", &s.LineComments)
+		var block []string
+		d.stringList(syntax, prefix+"block_comment", &block)
+		if len(block) == 2 {
+			s.BlockComments = [][2]string{{block[0], block[1]}}
+		} else if block != nil {
+			d.errorf(prefix+"block_comment", "expected [open, close], got %d strings", len(block))
+		}
+		s.Quotes = `"'`
+		d.str(syntax, prefix+"quotes", &s.Quotes)
+		config.Syntax = append(config.Syntax, s)
+	}
+
+	for i, opener := range d.tableArray(root, "opener") {
+		prefix := fmt.Sprintf("opener[%d].", i)
+		d.checkKeys(opener, prefix, "mime", "extensions", "globs", "command", "background")
+		var rule OpenerRule
+		d.stringList(opener, prefix+"mime", &rule.MIME)
+		d.extensionList(opener, prefix+"extensions", &rule.Extensions)
+		d.stringList(opener, prefix+"globs", &rule.Globs)
+		for _, pattern := range rule.MIME {
+			if _, err := path.Match(pattern, ""); err != nil || !strings.Contains(pattern, "/") {
+				d.errorf(prefix+"mime", "bad MIME pattern %q (use e.g. \"image/*\")", pattern)
+			}
+		}
+		for _, glob := range rule.Globs {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				d.errorf(prefix+"globs", "bad pattern %q", glob)
+			}
+		}
+		if len(rule.MIME)+len(rule.Extensions)+len(rule.Globs) == 0 {
+			d.errorf(prefix+"mime", "at least one of mime, extensions or globs is required")
+		}
+		d.str(opener, prefix+"command", &rule.Command)
+		if words, err := shellWords(rule.Command); err != nil {
+			d.errorf(prefix+"command", "%v", err)
+		} else if len(words) == 0 {
+			d.errorf(prefix+"command", "is required")
+		}
+		d.boolean(opener, prefix+"background", &rule.Background)
+		config.Openers = append(config.Openers, rule)
+	}
+
+	names := make(map[string]bool)
+	for i, table := range d.tableArray(root, "command") {
+		prefix := fmt.Sprintf("command[%d].", i)
+		d.checkKeys(table, prefix, "name", "description", "run", "keys", "background", "pause")
+		command := CommandConfig{Pause: true}
+		d.str(table, prefix+"name", &command.Name)
+		if !validCommandName(command.Name) {
+			d.errorf(prefix+"name", "expected letters, digits, - and _, got %q", command.Name)
+		} else if names[command.Name] {
+			d.errorf(prefix+"name", "%q is defined twice", command.Name)
+		}
+		names[command.Name] = true
+		d.str(table, prefix+"description", &command.Description)
+		d.str(table, prefix+"run", &command.Run)
+		if strings.TrimSpace(command.Run) == "" {
+			d.errorf(prefix+"run", "is required")
+		}
+		d.stringList(table, prefix+"keys", &command.Keys)
+		for _, spec := range command.Keys {
+			if _, err := parseKeySpec(spec); err != nil {
+				d.errorf(prefix+"keys", "%v", err)
+			}
+		}
+		d.boolean(table, prefix+"background", &command.Background)
+		d.boolean(table, prefix+"pause", &command.Pause)
+		config.Commands = append(config.Commands, command)
+	}
+
+	if keys := d.table(root, "keys"); keys != nil {
+		d.keys(keys, "", config)
+	}
+	// Conflicts only show up once every override and command is in place
+	if len(d.problems) == 0 {
+		if _, err := NewKeymap(config.Keys, commandActions(config.Commands)...); err != nil {
+			d.errorf("keys", "%v", err)
+		}
+	}
+}
+
+// keys reads action bindings. Action names contain dots, so both
+// "nav.up" = [...] and nav.up = [...] (a nested table) are accepted.
+func (d *configDecoder) keys(table map[string]any, prefix string, config *Config) {
+	for _, key := range sortedKeys(table) {
+		name := prefix + key
+		if nested, ok := table[key].(map[string]any); ok {
+			d.keys(nested, name+".", config)
+			continue
+		}
+		if _, ok := actionsByName[name]; !ok && !config.hasCommand(name) {
+			d.errorf("keys."+name, "unknown action")
+			continue
+		}
+
+		var specs []string
+		switch value := table[key].(type) {
+		case string:
+			specs = []string{value}
+		case []any:
+			specs = make([]string, 0, len(value))
+			for _, element := range value {
+				if spec, ok := element.(string); ok {
+					specs = append(specs, spec)
+				}
+			}
+			if len(specs) != len(value) {
+				d.errorf("keys."+name, "expected a list of key strings")
+				continue
+			}
+		default:
+			d.errorf("keys."+name, "expected a key string or a list of them, got %v", value)
+			continue
+		}
+		valid := true
+		for _, spec := range specs {
+			if _, err := parseKeySpec(spec); err != nil {
+				d.errorf("keys."+name, "%v", err)
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+		if config.Keys == nil {
+			config.Keys = make(map[string][]string)
+		}
+		config.Keys[name] = specs
+	}
+}
+
+// theme resolves the selected theme, following the inherit chain of
+// user themes down to a built-in.
+func (d *configDecoder) theme(config *Config) {
+	name, source := config.ThemeName, "ui.theme"
+	if name == "" {
+		name = "dark"
+	}
+
+	var layers []map[string]string
+	seen := make(map[string]bool)
+	for {
+		if seen[name] {
+			d.errorf("themes."+name, "inherits from itself")
+			return
+		}
+		seen[name] = true
+
+		if specs, ok := config.Themes[name]; ok {
+			layer := make(map[string]string, len(specs))
+			for key, spec := range specs {
+				if key != "inherit" {
+					layer[key] = spec
+				}
+			}
+			layers = append([]map[string]string{layer}, layers...)
+			name, source = specs["inherit"], "themes."+name+".inherit"
+			if name == "" {
+				name = "dark"
+			}
+			continue
+		}
+		specs, ok := builtinThemes[name]
+		if !ok {
+			d.errorf(source, "unknown theme %q (built-in: dark, light, mono)", name)
+			return
+		}
+		layers = append([]map[string]string{specs}, layers...)
+		break
+	}
+
+	theme, err := newTheme(layers...)
+	if err != nil {
+		d.errorf("themes", "%v", err)
+		return
+	}
+	config.Theme = theme
+}
+
+// lookup returns the value for the last segment of a dotted config key.
+func lookup(table map[string]any, key string) (any, bool) {
+	value, ok := table[key[strings.LastIndex(key, ".")+1:]]
+	return value, ok
+}
+
+func (d *configDecoder) checkKeys(table map[string]any, prefix string, allowed ...string) {
+	known := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		known[key] = true
+	}
+	var unknown []string
+	for key := range table {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		d.errorf(prefix+key, "unknown setting")
+	}
+}
+
+func (d *configDecoder) table(root map[string]any, key string) map[string]any {
+	value, ok := root[key]
+	if !ok {
+		return nil
+	}
+	table, ok := value.(map[string]any)
+	if !ok {
+		d.errorf(key, "expected a [%s] table", key)
+		return nil
+	}
+	return table
+}
+
+func (d *configDecoder) tableArray(root map[string]any, key string) []map[string]any {
+	value, ok := root[key]
+	if !ok {
+		return nil
+	}
+	array, ok := value.([]any)
+	if !ok {
+		d.errorf(key, "expected [[%s]] entries", key)
+		return nil
+	}
+	var tables []map[string]any
+	for _, element := range array {
+		table, ok := element.(map[string]any)
+		if !ok {
+			d.errorf(key, "expected [[%s]] entries", key)
+			return nil
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func (d *configDecoder) str(table map[string]any, key string, dst *string) {
+	value, ok := lookup(table, key)
+	if !ok {
+		return
+	}
+	s, ok := value.(string)
+	if !ok {
+		d.errorf(key, "expected a string, got %v", value)
+		return
+	}
+	*dst = s
+}
+
+func (d *configDecoder) boolean(table map[string]any, key string, dst *bool) {
+	value, ok := lookup(table, key)
+	if !ok {
+		return
+	}
+	b, ok := value.(bool)
+	if !ok {
+		d.errorf(key, "expected true or false, got %v", value)
+		return
+	}
+	*dst = b
+}
+
+func (d *configDecoder) integer(table map[string]any, key string, min, max int, dst *int) {
+	value, ok := lookup(table, key)
+	if !ok {
+		return
+	}
+	n, ok := value.(int64)
+	if !ok {
+		d.errorf(key, "expected a whole number, got %v", value)
+		return
+	}
+	if n < int64(min) || n > int64(max) {
+		d.errorf(key, "must be between %d and %d, got %d", min, max, n)
+		return
+	}
+	*dst = int(n)
+}
+
+// duration accepts a Go duration string ("1500ms") or a number of seconds.
+func (d *configDecoder) duration(table map[string]any, key string, dst *time.Duration) {
+	value, ok := lookup(table, key)
+	if !ok {
+		return
+	}
+	var duration time.Duration
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			d.errorf(key, "invalid duration %q (use e.g. \"2s\" or \"500ms\")", v)
+			return
+		}
+		duration = parsed
+	case int64:
+		duration = time.Duration(v) * time.Second
+	case float64:
+		duration = time.Duration(v * float64(time.Second))
+	default:
+		d.errorf(key, "expected a duration, got %v", value)
+		return
+	}
+	if duration < 0 {
+		d.errorf(key, "must not be negative")
+		return
+	}
+	*dst = duration
+}
+
+func (d *configDecoder) stringList(table map[string]any, key string, dst *[]string) {
+	value, ok := lookup(table, key)
+	if !ok {
+		return
+	}
+	array, ok := value.([]any)
+	if !ok {
+		d.errorf(key, "expected a list of strings, got %v", value)
+		return
+	}
+	list := make([]string, 0, len(array))
+	for _, element := range array {
+		s, ok := element.(string)
+		if !ok {
+			d.errorf(key, "expected a list of strings, got element %v", element)
+			return
+		}
+		list = append(list, s)
+	}
+	*dst = list
+}
+
+// extensionList reads extensions, normalizing them to a lowercase ".ext".
+func (d *configDecoder) extensionList(table map[string]any, key string, dst *[]string) {
+	var list []string
+	d.stringList(table, key, &list)
+	if list == nil {
+		return
+	}
+	for i, ext := range list {
+		if ext == "" || strings.ContainsAny(ext, "/ ") {
+			d.errorf(key, "invalid extension %q", ext)
+			return
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		list[i] = strings.ToLower(ext)
+	}
+	*dst = list
+}
+
+// color accepts tcell color names ("darkblue") and "#rrggbb" hex values.
+func (d *configDecoder) color(table map[string]any, key string, dst *tcell.Color) {
+	var name string
+	d.str(table, key, &name)
+	if name == "" {
+		return
+	}
+	color, err := parseColor(name)
+	if err != nil {
+		d.errorf(key, "%v", err)
+		return
+	}
+	*dst = color
+}
+
+func parseColor(name string) (tcell.Color, error) {
+	lower := strings.ToLower(name)
+	if lower == "default" || lower == "reset" {
+		return tcell.ColorReset, nil
+	}
+	color := tcell.GetColor(lower)
+	if color == tcell.ColorDefault {
+		return color, fmt.Errorf("unknown color %q (use a name like \"blue\" or hex like \"#1e90ff\")", name)
+	}
+	return color, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
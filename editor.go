@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fallbackEditor is used when neither the environment nor the config
+// names one; POSIX requires vi to exist.
+const fallbackEditor = "vi"
+
+// defaultLineTemplates tell how known editors open a file at a line.
+// {line} and {file} are replaced; a template without {file} gets the file
+// appended.
+var defaultLineTemplates = map[string]string{
+	"vi": "+{line}", "vim": "+{line}", "nvim": "+{line}", "gvim": "+{line}", "view": "+{line}",
+	"nano": "+{line}", "pico": "+{line}", "micro": "+{line}", "emacs": "+{line}", "emacsclient": "+{line}",
+	"kak": "+{line}", "joe": "+{line}", "jed": "+{line}", "mg": "+{line}", "ne": "+{line}",
+	"code": "--goto {file}:{line}", "code-insiders": "--goto {file}:{line}", "codium": "--goto {file}:{line}",
+	"subl": "{file}:{line}", "hx": "{file}:{line}", "helix": "{file}:{line}", "zed": "{file}:{line}",
+	"idea": "--line {line} {file}", "goland": "--line {line} {file}", "pycharm": "--line {line} {file}",
+}
+
+// shellWords splits s the way a POSIX shell splits a simple command:
+// on unquoted blanks, with single quotes, double quotes and backslash
+// escapes. Expansions such as $VAR and globs are not performed.
+func shellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				// Inside double quotes a backslash only escapes these
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// pickEditor returns the first editor command set in $VISUAL, $EDITOR or
+// the config, falling back to vi, split into words.
+func pickEditor(visual, editor, configured string) ([]string, error) {
+	for _, source := range []struct{ name, value string }{
+		{"$VISUAL", visual}, {"$EDITOR", editor}, {"editor.command", configured},
+	} {
+		if strings.TrimSpace(source.value) == "" {
+			continue
+		}
+		words, err := shellWords(source.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", source.name, err)
+		}
+		return words, nil
+	}
+	return []string{fallbackEditor}, nil
+}
+
+// editorChosen reports whether $VISUAL, $EDITOR or the config names an
+// editor, rather than pickEditor falling back to vi.
+func editorChosen(visual, editor, configured string) bool {
+	return strings.TrimSpace(visual) != "" || strings.TrimSpace(editor) != "" || strings.TrimSpace(configured) != ""
+}
+
+// editorCommand builds the command line opening filePaths in editor. A
+// single file with a line uses the editor's line template from templates
+// or the defaults; editors without one just open the file.
+func editorCommand(editor []string, templates map[string]string, line int, filePaths ...string) []string {
+	cmd := slices.Clone(editor)
+	name := filepath.Base(editor[0])
+	template, ok := templates[name]
+	if !ok {
+		template, ok = defaultLineTemplates[name]
+	}
+	if line <= 0 || len(filePaths) != 1 || !ok {
+		return append(cmd, filePaths...)
+	}
+	args, _ := shellWords(template) // checked when the config is loaded
+	return append(cmd, fillArgs(args, map[string]string{"line": strconv.Itoa(line), "file": filePaths[0]}, "file")...)
+}
+
+// fillArgs replaces {name} placeholders in args with values. When no
+// argument mentions {last}, its value is added as a final argument.
+func fillArgs(args []string, values map[string]string, last string) []string {
+	var pairs []string
+	for name, value := range values {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	// A single pass, so values that look like placeholders stay as they are
+	replacer := strings.NewReplacer(pairs...)
+	filled := make([]string, 0, len(args)+1)
+	seen := false
+	for _, arg := range args {
+		seen = seen || strings.Contains(arg, "{"+last+"}")
+		filled = append(filled, replacer.Replace(arg))
+	}
+	if !seen {
+		filled = append(filled, values[last])
+	}
+	return filled
+}
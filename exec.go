@@ -1,14 +1,47 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 )
 
-func execCommand(name string, args ...string) error {
+// execCommand runs a program on the terminal in dir ("" for powpow's own
+// working directory) and waits for it.
+func execCommand(dir, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
+}
+
+// waitForEnter keeps a finished command's output on the terminal until
+// Enter is pressed. It reads a byte at a time so that nothing typed after
+// the Enter is taken from powpow.
+func waitForEnter(in io.Reader, out io.Writer, err error) {
+	if err != nil {
+		fmt.Fprintf(out, "\n%v. Press Enter to return to powpow", err)
+	} else {
+		fmt.Fprint(out, "\nPress Enter to return to powpow")
+	}
+	buf := make([]byte, 1)
+	for {
+		if n, err := in.Read(buf); err != nil || n == 1 && buf[0] == '\n' {
+			return
+		}
+	}
+}
+
+// startDetached starts a program that runs alongside powpow, such as an
+// image viewer, without giving it the terminal.
+func startDetached(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
 }
\ No newline at end of file
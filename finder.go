@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sahilm/fuzzy"
+)
+
+const (
+	findWorkers       = 8 // directories read at once
+	findFlushInterval = 100 * time.Millisecond
+)
+
+// findEntry is one path below the finder's root.
+type findEntry struct {
+	rel   string // slash-separated path relative to the root
+	isDir bool
+	mode  os.FileMode // type bits from the directory entry
+}
+
+// findEntries lets fuzzy match relative paths without copying them.
+type findEntries []findEntry
+
+func (e findEntries) String(i int) string { return e[i].rel }
+func (e findEntries) Len() int            { return len(e) }
+
+// Finder fuzzy-searches the whole tree below root. The walk runs in the
+// background and streams entries in; closing done stops it.
+type Finder struct {
+	root         string
+	query        string
+	entries      findEntries
+	matches      findEntries
+	scores       []int // fuzzy score of each match, to rank later batches among them
+	selectedIdx  int
+	scrollOffset int
+	searching    bool
+	truncated    bool // the walk stopped at the file cap
+	done         chan struct{}
+}
+
+// walkJob is a directory waiting to be read by walkTree.
+type walkJob struct {
+	dir, rel string
+	depth    int
+	filter   *itemFilter
+}
+
+// walkTree lists the tree below root with findWorkers goroutines reading
+// directories from a shared queue, and hands each directory's entries to
+// emit, which must be safe for concurrent use. It stops maxDepth levels
+// down, after maxFiles entries or when done is closed, and reports whether
+// the file cap was hit.
+func walkTree(root string, filter *itemFilter, maxDepth, maxFiles int, done <-chan struct{}, emit func([]findEntry)) bool {
+	var (
+		found   atomic.Int64
+		limited atomic.Bool
+		mu      sync.Mutex
+		// Directories queued or being read; the walk ends when it drops to 0
+		pending = 1
+		queue   = []walkJob{{dir: root, depth: 1, filter: filter}}
+	)
+	ready := sync.NewCond(&mu)
+
+	// read lists one directory and returns its subdirectories to walk
+	read := func(job walkJob) []walkJob {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		filter := job.filter
+		if job.depth > 1 {
+			filter = filter.descend(job.dir)
+		}
+		entries, err := os.ReadDir(job.dir)
+		if err != nil {
+			return nil
+		}
+
+		var children []walkJob
+		batch := make([]findEntry, 0, len(entries))
+		for _, entry := range entries {
+			name := entry.Name()
+			if name == ".git" {
+				continue // repository internals are never what you're looking for
+			}
+			fullPath := filepath.Join(job.dir, name)
+			item := FileItem{Name: name, Path: fullPath, IsDir: entry.IsDir(), IsHidden: strings.HasPrefix(name, ".")}
+			if filter.hides(item) {
+				continue
+			}
+			if found.Add(1) > int64(maxFiles) {
+				limited.Store(true)
+				break
+			}
+			childRel := path.Join(job.rel, name)
+			batch = append(batch, findEntry{rel: childRel, isDir: item.IsDir, mode: entry.Type()})
+			// entry.IsDir is false for symlinks, so links are never followed
+			if item.IsDir && job.depth < maxDepth {
+				children = append(children, walkJob{dir: fullPath, rel: childRel, depth: job.depth + 1, filter: filter})
+			}
+		}
+		emit(batch)
+		return children
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < findWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				for len(queue) == 0 && pending > 0 {
+					ready.Wait()
+				}
+				if len(queue) == 0 {
+					mu.Unlock()
+					return
+				}
+				// Oldest first, so shallow entries show up before deep ones
+				job := queue[0]
+				queue = queue[1:]
+				mu.Unlock()
+
+				children := read(job)
+				mu.Lock()
+				queue = append(queue, children...)
+				pending += len(children) - 1
+				ready.Broadcast()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return limited.Load()
+}
+
+func (app *App) startFind() {
+	nav := app.navigator
+	f := &Finder{root: nav.currentPath, searching: true, done: make(chan struct{})}
+	app.finder = f
+	filter := newItemFilter(f.root, nav.filter)
+	maxDepth, maxFiles := app.cfg().FindMaxDepth, app.cfg().FindMaxFiles
+
+	streamBatches(app, func(collect func([]findEntry)) bool {
+		return walkTree(f.root, filter, maxDepth, maxFiles, f.done, collect)
+	}, func(batch []findEntry, final, truncated bool) {
+		if app.finder != f {
+			return
+		}
+		f.add(batch)
+		if final {
+			f.searching = false
+			f.truncated = truncated
+		}
+	})
+}
+
+// streamBatches runs work in the background and hands what it collects to
+// deliver on the UI goroutine, at most every findFlushInterval, so large
+// result sets don't flood the event queue. The final delivery carries
+// work's result.
+func streamBatches[T any](app *App, work func(collect func([]T)) bool, deliver func(batch []T, final, truncated bool)) {
+	var mu sync.Mutex
+	var pending []T
+	collect := func(batch []T) {
+		mu.Lock()
+		pending = append(pending, batch...)
+		mu.Unlock()
+	}
+	flush := func(final, truncated bool) {
+		mu.Lock()
+		batch := pending
+		pending = nil
+		mu.Unlock()
+		if len(batch) == 0 && !final {
+			return
+		}
+		app.postUI(func() { deliver(batch, final, truncated) })
+	}
+
+	go func() {
+		finished := make(chan bool, 1)
+		go func() { finished <- work(collect) }()
+		ticker := time.NewTicker(findFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case truncated := <-finished:
+				flush(true, truncated)
+				return
+			case <-ticker.C:
+				flush(false, false)
+			}
+		}
+	}()
+}
+
+func (app *App) exitFind() {
+	if app.finder != nil {
+		close(app.finder.done)
+		app.finder = nil
+	}
+}
+
+// selectFindResult leaves the finder in the directory holding the selected
+// entry, with the entry selected.
+func (app *App) selectFindResult() {
+	f := app.finder
+	entry := f.selected()
+	if entry == nil {
+		return
+	}
+	target := filepath.Join(f.root, filepath.FromSlash(entry.rel))
+	app.exitFind()
+	if err := app.navigator.revealPath(target); err != nil {
+		app.statusBar.showError("Cannot read directory: " + err.Error())
+	}
+}
+
+// add takes in entries streamed from the walk. Only the new batch is
+// matched against the query, and its matches merged into those ranked so
+// far, so a long walk doesn't rematch everything on every flush.
+func (f *Finder) add(batch []findEntry) {
+	selectedRel := f.selectedRel()
+	f.entries = append(f.entries, batch...)
+	if f.query == "" {
+		f.matches = f.entries
+	} else {
+		results := fuzzy.FindFrom(f.query, findEntries(batch))
+		f.matches, f.scores = mergeMatches(f.matches, f.scores, batch, results)
+	}
+	f.reselect(selectedRel)
+}
+
+func (f *Finder) setQuery(query string) {
+	f.query = query
+	f.selectedIdx = 0
+	f.match()
+}
+
+// match refilters all entries against the query, keeping the selected
+// entry selected.
+func (f *Finder) match() {
+	selectedRel := f.selectedRel()
+	f.matches, f.scores = nil, nil
+	if f.query == "" {
+		f.matches = f.entries
+	} else {
+		f.matches, f.scores = mergeMatches(nil, nil, f.entries, fuzzy.FindFrom(f.query, f.entries))
+	}
+	f.reselect(selectedRel)
+}
+
+// mergeMatches merges results, the ranked matches of query in entries, into
+// matches ranked by scores. Earlier matches stay first on equal scores.
+func mergeMatches(matches findEntries, scores []int, entries findEntries, results fuzzy.Matches) (findEntries, []int) {
+	if len(results) == 0 {
+		return matches, scores
+	}
+	merged := make(findEntries, 0, len(matches)+len(results))
+	mergedScores := make([]int, 0, len(matches)+len(results))
+	i := 0
+	for _, result := range results {
+		for i < len(matches) && scores[i] >= result.Score {
+			merged, mergedScores = append(merged, matches[i]), append(mergedScores, scores[i])
+			i++
+		}
+		merged, mergedScores = append(merged, entries[result.Index]), append(mergedScores, result.Score)
+	}
+	merged = append(merged, matches[i:]...)
+	return merged, append(mergedScores, scores[i:]...)
+}
+
+func (f *Finder) selectedRel() string {
+	if entry := f.selected(); entry != nil {
+		return entry.rel
+	}
+	return ""
+}
+
+// reselect moves the cursor back to the entry at rel after the matches
+// changed.
+func (f *Finder) reselect(rel string) {
+	for i, entry := range f.matches {
+		if entry.rel == rel {
+			f.selectedIdx = i
+			break
+		}
+	}
+	f.moveSelection(0)
+}
+
+func (f *Finder) moveSelection(delta int) {
+	f.selectedIdx = max(0, min(f.selectedIdx+delta, len(f.matches)-1))
+}
+
+func (f *Finder) selected() *findEntry {
+	if f.selectedIdx < 0 || f.selectedIdx >= len(f.matches) {
+		return nil
+	}
+	return &f.matches[f.selectedIdx]
+}
+
+func (app *App) drawFinder() {
+	f := app.finder
+	theme := app.theme()
+	style := theme.Bar
+	for i := 0; i < app.width; i++ {
+		app.screen.SetContent(i, 0, ' ', nil, style)
+	}
+	header := fmt.Sprintf("Find in %s - %d of %d", f.root, len(f.matches), len(f.entries))
+	switch {
+	case f.searching:
+		header += " (searching...)"
+	case f.truncated:
+		header += fmt.Sprintf(" (stopped at %d files)", app.cfg().FindMaxFiles)
+	}
+	app.drawText(1, 0, header, style)
+
+	maxItems := app.height - 2
+	if f.selectedIdx >= f.scrollOffset+maxItems {
+		f.scrollOffset = f.selectedIdx - maxItems + 1
+	}
+	if f.selectedIdx < f.scrollOffset {
+		f.scrollOffset = f.selectedIdx
+	}
+
+	if len(f.matches) == 0 && !f.searching {
+		app.drawText(2, 1, "No matches", theme.Dim)
+		return
+	}
+
+	for i := 0; i < maxItems && i+f.scrollOffset < len(f.matches); i++ {
+		idx := i + f.scrollOffset
+		entry := f.matches[idx]
+		y := 1 + i
+
+		var style tcell.Style
+		prefix := "  "
+		if idx == f.selectedIdx {
+			style = theme.Selected
+			prefix = "> "
+			for j := 0; j < app.width; j++ {
+				app.screen.SetContent(j, y, ' ', nil, style)
+			}
+		} else if entry.isDir {
+			style = theme.Directory
+		} else {
+			style = theme.File
+		}
+
+		text := prefix + entry.rel
+		if entry.isDir {
+			text += "/"
+		}
+		if len(text) > app.width-1 {
+			text = text[:app.width-4] + "..."
+		}
+		app.drawText(0, y, text, style)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxCompletionsShown = 6
+
+// expandPath resolves what was typed in the go-to prompt: environment
+// variables, a leading ~, and paths relative to cwd.
+func expandPath(input, cwd string) (string, error) {
+	path := os.ExpandEnv(strings.TrimSpace(input))
+	if path == "" {
+		return "", errors.New("path cannot be empty")
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// completePath completes the last segment of input to the directories it
+// could name. With one candidate the segment is finished with a slash;
+// with several it is extended to their common prefix and the candidates
+// are returned. The rest of input stays as typed.
+func completePath(input, cwd string) (string, []string) {
+	typedDir, prefix := "", input
+	if i := strings.LastIndex(input, "/"); i >= 0 {
+		typedDir, prefix = input[:i+1], input[i+1:]
+	} else if input == "~" {
+		return "~/", nil
+	}
+
+	dir := cwd
+	if typedDir != "" {
+		expanded, err := expandPath(typedDir, cwd)
+		if err != nil {
+			return input, nil
+		}
+		dir = expanded
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return input, nil
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if isDirectory(filepath.Join(dir, name), entry) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return input, nil
+	case 1:
+		return typedDir + candidates[0] + "/", nil
+	}
+	common := candidates[0]
+	for _, name := range candidates[1:] {
+		for !strings.HasPrefix(name, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	return typedDir + common, candidates
+}
+
+// isDirectory reports whether entry is a directory or a link to one.
+func isDirectory(path string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func (app *App) startGoTo() {
+	app.showPopup(PopupGoTo, "Go to", "Path: ", "", nil)
+}
+
+func (app *App) completeGoTo() {
+	if app.popup.popupType != PopupGoTo {
+		return
+	}
+	app.popup.inputBuffer, app.popup.completions = completePath(app.popup.inputBuffer, app.navigator.currentPath)
+}
+
+// goTo opens the directory typed in the go-to prompt. A file path opens
+// its directory with the file selected.
+func (app *App) goTo(input string) {
+	path, err := expandPath(input, app.navigator.currentPath)
+	if err != nil {
+		app.statusBar.showError(err.Error())
+		return
+	}
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		app.statusBar.showError("No such file or directory: " + path)
+		return
+	case errors.Is(err, fs.ErrPermission):
+		app.statusBar.showError("Permission denied: " + path)
+		return
+	case err != nil:
+		app.statusBar.showError("Cannot open " + path + ": " + err.Error())
+		return
+	}
+
+	if !info.IsDir() {
+		err = app.navigator.revealPath(path)
+	} else {
+		err = app.navigator.changeDirectory(path)
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		app.statusBar.showError("Permission denied: " + path)
+	} else if err != nil {
+		app.statusBar.showError("Cannot read directory: " + err.Error())
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxSnippetLength = 200
+
+// grepMatch is one matching line.
+type grepMatch struct {
+	rel  string // slash-separated path relative to the grep root
+	line int    // 1-based
+	text string
+}
+
+// Grep searches file contents below root. Every query change restarts
+// the search; closing done stops the running one.
+type Grep struct {
+	root         string
+	query        string
+	regex        bool // query is a regular expression rather than literal text
+	err          string
+	matches      []grepMatch
+	selectedIdx  int
+	scrollOffset int
+	searching    bool
+	truncated    bool // stopped at the file or match cap
+	done         chan struct{}
+}
+
+// grepMatcher builds the line test for query. Like ripgrep's smart case,
+// queries without capitals ignore case.
+func grepMatcher(query string, regex bool) (func(string) bool, error) {
+	ignoreCase := !strings.ContainsFunc(query, unicode.IsUpper)
+	if regex {
+		if ignoreCase {
+			query = "(?i)" + query
+		}
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	if ignoreCase {
+		return func(line string) bool {
+			return strings.Contains(strings.ToLower(line), query)
+		}, nil
+	}
+	return func(line string) bool { return strings.Contains(line, query) }, nil
+}
+
+// grepTree searches the text files walkTree finds below root, handing each
+// file's matches to emit, which must be safe for concurrent use. It reports
+// whether the file cap or maxMatches stopped it.
+func grepTree(root string, filter *itemFilter, maxDepth, maxFiles, maxMatches int, isText func(FileItem) bool, match func(string) bool, done <-chan struct{}, emit func([]grepMatch)) bool {
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	halt := func() { stopOnce.Do(func() { close(stop) }) }
+	go func() {
+		select {
+		case <-done:
+			halt()
+		case <-stop:
+		}
+	}()
+
+	var (
+		workers sync.WaitGroup
+		found   atomic.Int64
+		limited atomic.Bool
+		files   = make(chan string)
+	)
+	for i := 0; i < findWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for rel := range files {
+				path := filepath.Join(root, filepath.FromSlash(rel))
+				if !isText(FileItem{Name: filepath.Base(path), Path: path}) {
+					continue
+				}
+				matches := grepFile(path, rel, match, stop)
+				if len(matches) == 0 {
+					continue
+				}
+				if total := found.Add(int64(len(matches))); total > int64(maxMatches) {
+					matches = matches[:max(0, len(matches)-int(total-int64(maxMatches)))]
+					limited.Store(true)
+					halt()
+				}
+				emit(matches)
+			}
+		}()
+	}
+
+	walkLimited := walkTree(root, filter, maxDepth, maxFiles, stop, func(batch []findEntry) {
+		for _, entry := range batch {
+			if !searchable(root, entry) {
+				continue
+			}
+			select {
+			case files <- entry.rel:
+			case <-stop:
+				return
+			}
+		}
+	})
+	close(files)
+	workers.Wait()
+	halt()
+	return walkLimited || limited.Load()
+}
+
+// searchable reports whether entry is a regular file, directly or through
+// a link. Reading a pipe or a device could block a worker forever.
+func searchable(root string, entry findEntry) bool {
+	if entry.mode.IsRegular() {
+		return true
+	}
+	if entry.mode&os.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(entry.rel)))
+	return err == nil && info.Mode().IsRegular()
+}
+
+func grepFile(path, rel string, match func(string) bool, stop <-chan struct{}) []grepMatch {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var matches []grepMatch
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if line%1000 == 0 {
+			select {
+			case <-stop:
+				return matches
+			default:
+			}
+		}
+		text := scanner.Text()
+		if !match(text) {
+			continue
+		}
+		text = truncateRunes(strings.TrimSpace(text), maxSnippetLength)
+		matches = append(matches, grepMatch{rel: rel, line: line, text: text})
+	}
+	return matches
+}
+
+// truncateRunes cuts s to at most n runes, never inside one.
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+func (app *App) startGrep() {
+	app.grep = &Grep{root: app.navigator.currentPath, done: make(chan struct{})}
+}
+
+func (app *App) exitGrep() {
+	if app.grep != nil {
+		close(app.grep.done)
+		app.grep = nil
+	}
+}
+
+func (app *App) setGrepQuery(query string) {
+	app.grep.query = query
+	app.runGrep()
+}
+
+func (app *App) toggleGrepRegex() {
+	app.grep.regex = !app.grep.regex
+	app.runGrep()
+}
+
+// runGrep stops the previous search and starts one for the current query.
+func (app *App) runGrep() {
+	old := app.grep
+	close(old.done)
+	g := &Grep{root: old.root, query: old.query, regex: old.regex, done: make(chan struct{})}
+	app.grep = g
+	if g.query == "" {
+		return
+	}
+	match, err := grepMatcher(g.query, g.regex)
+	if err != nil {
+		g.err = err.Error()
+		return
+	}
+
+	g.searching = true
+	filter := newItemFilter(g.root, app.navigator.filter)
+	config := app.cfg()
+	streamBatches(app, func(collect func([]grepMatch)) bool {
+		return grepTree(g.root, filter, config.FindMaxDepth, config.FindMaxFiles, config.GrepMaxMatches, app.detectTextContent, match, g.done, collect)
+	}, func(batch []grepMatch, final, truncated bool) {
+		if app.grep != g {
+			return
+		}
+		g.add(batch)
+		if final {
+			g.searching = false
+			g.truncated = truncated
+		}
+	})
+}
+
+// add merges a batch in path and line order, keeping the selected match
+// selected as results stream in.
+func (g *Grep) add(batch []grepMatch) {
+	var selected grepMatch
+	if match := g.selected(); match != nil {
+		selected = *match
+	}
+	g.matches = append(g.matches, batch...)
+	slices.SortFunc(g.matches, compareGrepMatches)
+	if selected.rel != "" {
+		g.selectedIdx, _ = slices.BinarySearchFunc(g.matches, selected, compareGrepMatches)
+	}
+}
+
+func compareGrepMatches(a, b grepMatch) int {
+	if c := strings.Compare(a.rel, b.rel); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.line, b.line)
+}
+
+func (g *Grep) moveSelection(delta int) {
+	g.selectedIdx = max(0, min(g.selectedIdx+delta, len(g.matches)-1))
+}
+
+func (g *Grep) selected() *grepMatch {
+	if g.selectedIdx < 0 || g.selectedIdx >= len(g.matches) {
+		return nil
+	}
+	return &g.matches[g.selectedIdx]
+}
+
+func (app *App) openGrepResult() {
+	g := app.grep
+	match := g.selected()
+	if match == nil {
+		return
+	}
+	app.openFileAtLine(filepath.Join(g.root, filepath.FromSlash(match.rel)), match.line)
+}
+
+func (app *App) drawGrep() {
+	g := app.grep
+	theme := app.theme()
+	style := theme.Bar
+	for i := 0; i < app.width; i++ {
+		app.screen.SetContent(i, 0, ' ', nil, style)
+	}
+	header := fmt.Sprintf("Grep in %s - %d matches", g.root, len(g.matches))
+	switch {
+	case g.searching:
+		header += " (searching...)"
+	case g.truncated:
+		header += " (stopped early, refine the search)"
+	}
+	app.drawText(1, 0, header, style)
+
+	maxItems := app.height - 2
+	if g.selectedIdx >= g.scrollOffset+maxItems {
+		g.scrollOffset = g.selectedIdx - maxItems + 1
+	}
+	if g.selectedIdx < g.scrollOffset {
+		g.scrollOffset = g.selectedIdx
+	}
+
+	switch {
+	case g.err != "":
+		app.drawText(2, 1, "Invalid pattern: "+g.err, theme.PreviewError)
+		return
+	case g.query == "":
+		app.drawText(2, 1, "Type to search file contents", theme.Dim)
+		return
+	case len(g.matches) == 0 && !g.searching:
+		app.drawText(2, 1, "No matches", theme.Dim)
+		return
+	}
+
+	for i := 0; i < maxItems && i+g.scrollOffset < len(g.matches); i++ {
+		idx := i + g.scrollOffset
+		match := g.matches[idx]
+		y := 1 + i
+
+		location := fmt.Sprintf("%s:%d: ", match.rel, match.line)
+		locationStyle, textStyle := theme.Dim, theme.File
+		prefix := "  "
+		if idx == g.selectedIdx {
+			locationStyle, textStyle = theme.Selected, theme.Selected
+			prefix = "> "
+			for j := 0; j < app.width; j++ {
+				app.screen.SetContent(j, y, ' ', nil, theme.Selected)
+			}
+		}
+
+		app.drawText(0, y, prefix+location, locationStyle)
+		app.drawTextWithin(utf8.RuneCountInString(prefix+location), y, app.width-1, match.text, textStyle)
+	}
+}
+
+// grepStatus is the status bar text while grepping.
+func (g *Grep) status() string {
+	if g.regex {
+		return "Grep (regex): " + g.query
+	}
+	return "Grep: " + g.query
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type TokenKind int
+
+const (
+	TokenPlain TokenKind = iota
+	TokenKeyword
+	TokenType
+	TokenString
+	TokenNumber
+	TokenComment
+	TokenKey
+	TokenHeading
+	TokenTag
+)
+
+type Token struct {
+	Text string
+	Kind TokenKind
+}
+
+// LexState carries constructs that span lines, such as block comments,
+// from one Tokenize call to the next.
+type LexState struct {
+	closer string    // delimiter that ends the open construct
+	kind   TokenKind // kind of the open construct
+}
+
+// Lexer splits a single line into tokens.
+type Lexer interface {
+	Tokenize(line string, state *LexState) []Token
+}
+
+// lexers maps a lowercase extension (".go") or exact base name ("Makefile") to a lexer.
+var lexers = map[string]Lexer{}
+
+// registerLexer makes lexer the highlighter for the given extensions or file names.
+func registerLexer(lexer Lexer, keys ...string) {
+	for _, key := range keys {
+		if strings.HasPrefix(key, ".") {
+			key = strings.ToLower(key)
+		}
+		lexers[key] = lexer
+	}
+}
+
+func lexerFor(name string) Lexer {
+	if lexer, ok := lexers[name]; ok {
+		return lexer
+	}
+	return lexers[strings.ToLower(filepath.Ext(name))]
+}
+
+// highlightLines tokenizes lines in order so multi-line state carries over.
+func highlightLines(lexer Lexer, lines []string) [][]Token {
+	var state LexState
+	tokens := make([][]Token, len(lines))
+	for i, line := range lines {
+		tokens[i] = lexer.Tokenize(line, &state)
+	}
+	return tokens
+}
+
+// tokenAppender merges adjacent tokens of the same kind.
+type tokenAppender []Token
+
+func (t *tokenAppender) add(text string, kind TokenKind) {
+	if text == "" {
+		return
+	}
+	if n := len(*t); n > 0 && (*t)[n-1].Kind == kind {
+		(*t)[n-1].Text += text
+		return
+	}
+	*t = append(*t, Token{Text: text, Kind: kind})
+}
+
+// GenericLexer covers C-like, script and config languages from a small
+// description of their keywords, comments and string delimiters.
+type GenericLexer struct {
+	Keywords        []string
+	Types           []string
+	LineComments    []string    // e.g. "//", "#"
+	BlockComments   [][2]string // e.g. {"/*", "*/"}
+	MultiStrings    [][2]string // multi-line strings, e.g. {`"""`, `"""`}
+	Quotes          string      // single-line string delimiters, e.g. `"'`
+	KeysBeforeColon bool        // highlight "key": as TokenKey (JSON)
+
+	once     sync.Once
+	keywords map[string]bool
+	types    map[string]bool
+}
+
+func (g *GenericLexer) init() {
+	g.keywords = make(map[string]bool, len(g.Keywords))
+	for _, k := range g.Keywords {
+		g.keywords[k] = true
+	}
+	g.types = make(map[string]bool, len(g.Types))
+	for _, k := range g.Types {
+		g.types[k] = true
+	}
+}
+
+func (g *GenericLexer) Tokenize(line string, state *LexState) []Token {
+	// Previews tokenize on background goroutines, so build the sets exactly once
+	g.once.Do(g.init)
+	var out tokenAppender
+	i := 0
+
+	// Continue a construct left open by a previous line
+	if state.closer != "" {
+		end := strings.Index(line, state.closer)
+		if end < 0 {
+			out.add(line, state.kind)
+			return out
+		}
+		end += len(state.closer)
+		out.add(line[:end], state.kind)
+		i = end
+		*state = LexState{}
+	}
+
+scan:
+	for i < len(line) {
+		rest := line[i:]
+
+		for _, prefix := range g.LineComments {
+			if strings.HasPrefix(rest, prefix) {
+				out.add(rest, TokenComment)
+				break scan
+			}
+		}
+
+		for _, pair := range g.BlockComments {
+			if strings.HasPrefix(rest, pair[0]) {
+				i += g.openBlock(&out, line, i, pair, TokenComment, state)
+				continue scan
+			}
+		}
+
+		for _, pair := range g.MultiStrings {
+			if strings.HasPrefix(rest, pair[0]) {
+				i += g.openBlock(&out, line, i, pair, TokenString, state)
+				continue scan
+			}
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(g.Quotes, c) >= 0:
+			end := scanQuoted(line, i)
+			kind := TokenString
+			if g.KeysBeforeColon && strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ":") {
+				kind = TokenKey
+			}
+			out.add(line[i:end], kind)
+			i = end
+
+		case c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			out.add(line[i:end], TokenNumber)
+			i = end
+
+		case isWordByte(c):
+			end := i + 1
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case g.keywords[word]:
+				out.add(word, TokenKeyword)
+			case g.types[word]:
+				out.add(word, TokenType)
+			default:
+				out.add(word, TokenPlain)
+			}
+			i = end
+
+		default:
+			out.add(rest[:1], TokenPlain)
+			i++
+		}
+	}
+	return out
+}
+
+// openBlock emits a construct starting at line[i] and returns its length on
+// this line, leaving state open when it continues past the end of the line.
+func (g *GenericLexer) openBlock(out *tokenAppender, line string, i int, pair [2]string, kind TokenKind, state *LexState) int {
+	start := i + len(pair[0])
+	end := strings.Index(line[start:], pair[1])
+	if end < 0 {
+		out.add(line[i:], kind)
+		*state = LexState{closer: pair[1], kind: kind}
+		return len(line) - i
+	}
+	end = start + end + len(pair[1])
+	out.add(line[i:end], kind)
+	return end - i
+}
+
+// scanQuoted returns the index just past the string starting at line[i].
+func scanQuoted(line string, i int) int {
+	quote := line[i]
+	for j := i + 1; j < len(line); j++ {
+		if line[j] == '\\' && quote != '`' {
+			j++
+			continue
+		}
+		if line[j] == quote {
+			return j + 1
+		}
+	}
+	return len(line)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// keyValueLexer highlights line-oriented config formats (YAML, TOML, INI):
+// section headers, the key before the separator, and values via a GenericLexer.
+type keyValueLexer struct {
+	separators string // characters that end a key, e.g. ":" or "="
+	sections   bool   // [section] headers
+	listItems  bool   // YAML "- " list prefixes
+	values     *GenericLexer
+}
+
+func (k *keyValueLexer) Tokenize(line string, state *LexState) []Token {
+	if state.closer != "" {
+		return k.values.Tokenize(line, state)
+	}
+
+	var out tokenAppender
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	out.add(indent, TokenPlain)
+
+	if k.sections && strings.HasPrefix(trimmed, "[") {
+		end := strings.IndexByte(trimmed, ']')
+		if end > 0 {
+			out.add(trimmed[:end+1], TokenHeading)
+			for _, t := range k.values.Tokenize(trimmed[end+1:], state) {
+				out.add(t.Text, t.Kind)
+			}
+			return out
+		}
+	}
+	if k.listItems && strings.HasPrefix(trimmed, "- ") {
+		out.add("- ", TokenKeyword)
+		trimmed = trimmed[2:]
+	}
+
+	if sep := strings.IndexAny(trimmed, k.separators); sep > 0 && !strings.ContainsAny(trimmed[:sep], "#;\"'") {
+		out.add(trimmed[:sep], TokenKey)
+		trimmed = trimmed[sep:]
+	}
+	for _, t := range k.values.Tokenize(trimmed, state) {
+		out.add(t.Text, t.Kind)
+	}
+	return out
+}
+
+// markdownLexer highlights headings, fenced code, quotes, list markers and code spans.
+type markdownLexer struct{}
+
+func (markdownLexer) Tokenize(line string, state *LexState) []Token {
+	var out tokenAppender
+	trimmed := strings.TrimLeft(line, " ")
+
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		if state.closer != "" {
+			*state = LexState{}
+		} else {
+			*state = LexState{closer: trimmed[:3], kind: TokenString}
+		}
+		out.add(line, TokenString)
+		return out
+	}
+	if state.closer != "" {
+		out.add(line, TokenString)
+		return out
+	}
+
+	switch {
+	case strings.HasPrefix(trimmed, "#"):
+		out.add(line, TokenHeading)
+		return out
+	case strings.HasPrefix(trimmed, ">"):
+		out.add(line, TokenComment)
+		return out
+	case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "), strings.HasPrefix(trimmed, "+ "):
+		prefix := len(line) - len(trimmed) + 2
+		out.add(line[:prefix], TokenKeyword)
+		line = line[prefix:]
+	}
+
+	for {
+		start := strings.IndexByte(line, '`')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(line[start+1:], '`')
+		if end < 0 {
+			break
+		}
+		end += start + 2
+		out.add(line[:start], TokenPlain)
+		out.add(line[start:end], TokenString)
+		line = line[end:]
+	}
+	out.add(line, TokenPlain)
+	return out
+}
+
+// markupLexer highlights HTML/XML-like markup: tags, attribute strings and comments.
+type markupLexer struct{}
+
+func (markupLexer) Tokenize(line string, state *LexState) []Token {
+	var out tokenAppender
+	i := 0
+
+	if state.closer != "" {
+		end := strings.Index(line, state.closer)
+		if end < 0 {
+			out.add(line, state.kind)
+			return out
+		}
+		end += len(state.closer)
+		out.add(line[:end], state.kind)
+		i = end
+		*state = LexState{}
+	}
+
+	for i < len(line) {
+		rest := line[i:]
+		if strings.HasPrefix(rest, "<!--") {
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				out.add(rest, TokenComment)
+				*state = LexState{closer: "-->", kind: TokenComment}
+				break
+			}
+			out.add(rest[:end+3], TokenComment)
+			i += end + 3
+			continue
+		}
+		if rest[0] == '<' {
+			end := 1
+			for end < len(rest) && rest[end] != ' ' && rest[end] != '>' {
+				end++
+			}
+			out.add(rest[:end], TokenTag)
+			i += end
+			// Attributes up to the closing '>'
+			for i < len(line) && line[i] != '>' {
+				switch {
+				case line[i] == '"' || line[i] == '\'':
+					end := scanQuoted(line, i)
+					out.add(line[i:end], TokenString)
+					i = end
+				case isWordByte(line[i]):
+					end := i + 1
+					for end < len(line) && (isWordByte(line[end]) || line[end] == '-' || line[end] == ':') {
+						end++
+					}
+					out.add(line[i:end], TokenKey)
+					i = end
+				default:
+					out.add(line[i:i+1], TokenPlain)
+					i++
+				}
+			}
+			if i < len(line) {
+				out.add(">", TokenTag)
+				i++
+			}
+			continue
+		}
+		end := strings.IndexByte(rest, '<')
+		if end < 0 {
+			end = len(rest)
+		}
+		out.add(rest[:end], TokenPlain)
+		i += end
+	}
+	return out
+}
+
+func init() {
+	cStyle := [][2]string{{"/*", "*/"}}
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+			"range", "return", "select", "struct", "switch", "type", "var", "nil", "true", "false", "iota"},
+		Types: []string{"bool", "byte", "complex64", "complex128", "error", "float32", "float64", "int",
+			"int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32",
+			"uint64", "uintptr", "any"},
+		LineComments:  []string{"//"},
+		BlockComments: cStyle,
+		MultiStrings:  [][2]string{{"`", "`"}},
+		Quotes:        `"'`,
+	}, ".go")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def",
+			"del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+			"is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with",
+			"yield", "None", "True", "False", "self"},
+		Types:        []string{"int", "float", "str", "bool", "list", "dict", "set", "tuple", "bytes", "object"},
+		LineComments: []string{"#"},
+		MultiStrings: [][2]string{{`"""`, `"""`}, {"'''", "'''"}},
+		Quotes:       `"'`,
+	}, ".py")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"as", "async", "await", "break", "const", "continue", "crate", "dyn", "else",
+			"enum", "extern", "false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod",
+			"move", "mut", "pub", "ref", "return", "self", "Self", "static", "struct", "super", "trait",
+			"true", "type", "unsafe", "use", "where", "while"},
+		Types: []string{"i8", "i16", "i32", "i64", "i128", "isize", "u8", "u16", "u32", "u64", "u128",
+			"usize", "f32", "f64", "bool", "char", "str", "String", "Vec", "Option", "Result", "Box"},
+		LineComments:  []string{"//"},
+		BlockComments: cStyle,
+		Quotes:        `"`,
+	}, ".rs")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue",
+			"debugger", "default", "delete", "do", "else", "export", "extends", "finally", "for",
+			"from", "function", "if", "import", "in", "instanceof", "let", "new", "of", "return",
+			"super", "switch", "this", "throw", "try", "typeof", "var", "void", "while", "yield",
+			"true", "false", "null", "undefined", "interface", "type", "enum", "implements",
+			"private", "public", "protected", "readonly", "as"},
+		Types:         []string{"string", "number", "boolean", "any", "unknown", "never", "object"},
+		LineComments:  []string{"//"},
+		BlockComments: cStyle,
+		MultiStrings:  [][2]string{{"`", "`"}},
+		Quotes:        `"'`,
+	}, ".js", ".ts", ".jsx", ".tsx")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"auto", "break", "case", "catch", "class", "const", "continue", "default",
+			"delete", "do", "else", "enum", "extends", "extern", "final", "for", "goto", "if",
+			"implements", "import", "include", "define", "inline", "namespace", "new", "package",
+			"private", "protected", "public", "return", "sizeof", "static", "struct", "switch",
+			"template", "this", "throw", "try", "typedef", "union", "using", "virtual", "volatile",
+			"while", "true", "false", "null", "nullptr", "NULL"},
+		Types: []string{"void", "char", "short", "int", "long", "float", "double", "signed", "unsigned",
+			"bool", "boolean", "byte", "size_t", "String"},
+		LineComments:  []string{"//"},
+		BlockComments: cStyle,
+		Quotes:        `"'`,
+	}, ".c", ".h", ".cpp", ".hpp", ".java")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"abstract", "and", "array", "as", "break", "case", "catch", "class", "const",
+			"continue", "default", "do", "echo", "else", "elseif", "extends", "final", "for", "foreach",
+			"function", "if", "implements", "interface", "namespace", "new", "or", "private",
+			"protected", "public", "return", "static", "switch", "throw", "trait", "try", "use",
+			"while", "true", "false", "null"},
+		LineComments:  []string{"//", "#"},
+		BlockComments: cStyle,
+		Quotes:        `"'`,
+	}, ".php")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"alias", "and", "begin", "break", "case", "class", "def", "do",
+			"else", "elsif", "end", "ensure", "false", "for", "if", "in", "module", "next", "nil",
+			"not", "or", "redo", "rescue", "retry", "return", "self", "super", "then", "true",
+			"undef", "unless", "until", "when", "while", "yield", "require", "attr_accessor"},
+		LineComments: []string{"#"},
+		Quotes:       `"'`,
+	}, ".rb")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"my", "our", "local", "sub", "if", "elsif", "else", "unless", "while",
+			"until", "for", "foreach", "return", "use", "package", "last", "next", "print", "die"},
+		LineComments: []string{"#"},
+		Quotes:       `"'`,
+	}, ".pl")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done",
+			"case", "esac", "in", "function", "return", "local", "export", "readonly", "echo",
+			"exit", "source", "set", "unset", "shift", "FROM", "RUN", "CMD", "COPY", "ADD", "ENV",
+			"WORKDIR", "ENTRYPOINT", "EXPOSE", "ARG", "LABEL", "USER", "VOLUME"},
+		LineComments: []string{"#"},
+		Quotes:       `"'`,
+	}, ".sh", ".bash", ".zsh", "Makefile", "Dockerfile", ".bashrc", ".zshrc", ".profile", ".env")
+
+	registerLexer(&GenericLexer{
+		Keywords: []string{"SELECT", "FROM", "WHERE", "INSERT", "INTO", "VALUES", "UPDATE", "SET",
+			"DELETE", "CREATE", "TABLE", "DROP", "ALTER", "INDEX", "JOIN", "LEFT", "RIGHT", "INNER",
+			"OUTER", "ON", "AND", "OR", "NOT", "NULL", "AS", "ORDER", "BY", "GROUP", "HAVING",
+			"LIMIT", "PRIMARY", "KEY", "DISTINCT", "UNION", "select", "from", "where", "insert",
+			"into", "values", "update", "set", "delete", "create", "table", "drop", "alter", "join",
+			"on", "and", "or", "not", "null", "as", "order", "by", "group", "limit"},
+		Types:         []string{"INT", "INTEGER", "TEXT", "VARCHAR", "BOOLEAN", "DATE", "TIMESTAMP", "REAL"},
+		LineComments:  []string{"--"},
+		BlockComments: cStyle,
+		Quotes:        `"'`,
+	}, ".sql")
+
+	registerLexer(&GenericLexer{
+		Keywords:      []string{"important", "inherit", "initial", "none", "auto", "media", "import", "include", "mixin", "extend"},
+		LineComments:  []string{"//"},
+		BlockComments: cStyle,
+		Quotes:        `"'`,
+	}, ".css", ".scss", ".sass", ".less")
+
+	registerLexer(&GenericLexer{
+		Keywords:        []string{"true", "false", "null"},
+		Quotes:          `"`,
+		KeysBeforeColon: true,
+	}, ".json")
+
+	configValues := &GenericLexer{
+		Keywords:     []string{"true", "false", "null", "yes", "no", "on", "off"},
+		LineComments: []string{"#", ";"},
+		MultiStrings: [][2]string{{`"""`, `"""`}},
+		Quotes:       `"'`,
+	}
+	registerLexer(&keyValueLexer{separators: ":", listItems: true, values: configValues}, ".yaml", ".yml")
+	registerLexer(&keyValueLexer{separators: "=:", sections: true, values: configValues},
+		".toml", ".ini", ".cfg", ".conf", ".gitconfig")
+
+	registerLexer(markdownLexer{}, ".md")
+	registerLexer(markupLexer{}, ".html", ".xml", ".vue", ".svelte")
+}
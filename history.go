@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"time"
+)
+
+const (
+	maxHistory    = 100 // entries kept on each of the back and forward stacks
+	maxRecentDirs = 500
+	// recentSaveDelay collects the visits of a burst of navigation into one write
+	recentSaveDelay = 2 * time.Second
+)
+
+// position is where the cursor was when a directory was left.
+type position struct {
+	selected string // path of the selected item
+	scroll   int
+}
+
+func pushHistory(stack []string, dir string) []string {
+	stack = append(stack, dir)
+	if len(stack) > maxHistory {
+		stack = stack[len(stack)-maxHistory:]
+	}
+	return stack
+}
+
+func (n *Navigator) rememberPosition() {
+	selected := n.getSelectedItem()
+	if selected == nil {
+		return
+	}
+	if n.positions == nil {
+		n.positions = make(map[string]position)
+	}
+	n.positions[n.currentPath] = position{selected: selected.Path, scroll: n.scrollOffset}
+}
+
+func (n *Navigator) restorePosition() {
+	n.selectedIdx = 0
+	n.scrollOffset = 0
+	if p, ok := n.positions[n.currentPath]; ok {
+		n.selectPath(p.selected)
+		n.scrollOffset = p.scroll
+		if n.loading() {
+			// Drawing moves the scroll while the listing fills in
+			n.wantScroll = p.scroll
+		}
+	}
+}
+
+// goBack returns to the previous directory, like a browser's back button.
+// It reports false when there is nowhere to go back to.
+func (n *Navigator) goBack() (bool, error) {
+	return n.step(&n.back, &n.forward)
+}
+
+func (n *Navigator) goForward() (bool, error) {
+	return n.step(&n.forward, &n.back)
+}
+
+// step moves to the top of from, saving the current directory on to.
+// A directory that can no longer be read is dropped from the stack.
+func (n *Navigator) step(from, to *[]string) (bool, error) {
+	if len(*from) == 0 {
+		return false, nil
+	}
+	target := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	previous := n.currentPath
+	if err := n.moveTo(target); err != nil {
+		return true, err
+	}
+	*to = pushHistory(*to, previous)
+	return true, nil
+}
+
+func (app *App) goBack() {
+	moved, err := app.navigator.goBack()
+	app.reportHistoryStep(moved, err, "No previous directory")
+}
+
+func (app *App) goForward() {
+	moved, err := app.navigator.goForward()
+	app.reportHistoryStep(moved, err, "No next directory")
+}
+
+func (app *App) reportHistoryStep(moved bool, err error, none string) {
+	switch {
+	case err != nil:
+		app.statusBar.showError("Cannot read directory: " + err.Error())
+	case !moved:
+		app.statusBar.showMessage(none)
+	}
+}
+
+// RecentDirs records directory visits across sessions and ranks them by
+// frecency: how often and how recently each was visited.
+type RecentDirs struct {
+	path    string
+	Dirs    map[string]*RecentDir `json:"dirs"`
+	unsaved bool
+	changed func() // called after each visit, to schedule a save
+}
+
+type RecentDir struct {
+	Visits int       `json:"visits"`
+	Last   time.Time `json:"last"`
+}
+
+// LoadRecentDirs reads the recent list at path; a missing or corrupt file
+// starts an empty one.
+func LoadRecentDirs(path string) *RecentDirs {
+	r := &RecentDirs{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, r); err != nil {
+			r.Dirs = nil
+		}
+	}
+	if r.Dirs == nil {
+		r.Dirs = make(map[string]*RecentDir)
+	}
+	return r
+}
+
+// frecency weights visits by age, like zoxide: a visit in the last hour
+// counts sixteen times as much as one from last month.
+func (d RecentDir) frecency(now time.Time) float64 {
+	age := now.Sub(d.Last)
+	switch {
+	case age < time.Hour:
+		return float64(d.Visits) * 4
+	case age < 24*time.Hour:
+		return float64(d.Visits) * 2
+	case age < 7*24*time.Hour:
+		return float64(d.Visits) / 2
+	}
+	return float64(d.Visits) / 4
+}
+
+// visit records a visit in memory; save writes it out.
+func (r *RecentDirs) visit(dir string, now time.Time) {
+	entry := r.Dirs[dir]
+	if entry == nil {
+		entry = &RecentDir{}
+		r.Dirs[dir] = entry
+	}
+	entry.Visits++
+	entry.Last = now
+	if len(r.Dirs) > maxRecentDirs {
+		ranked := r.ranked(now)
+		for _, dir := range ranked[maxRecentDirs:] {
+			delete(r.Dirs, dir)
+		}
+	}
+	r.unsaved = true
+	if r.changed != nil {
+		r.changed()
+	}
+}
+
+func (r *RecentDirs) forget(dir string) error {
+	delete(r.Dirs, dir)
+	r.unsaved = true
+	return r.save()
+}
+
+// save writes the list if it changed since the last save.
+func (r *RecentDirs) save() error {
+	if !r.unsaved {
+		return nil
+	}
+	if err := writeStateFile(r.path, r); err != nil {
+		return err
+	}
+	r.unsaved = false
+	return nil
+}
+
+// ranked lists directories best first.
+func (r *RecentDirs) ranked(now time.Time) []string {
+	dirs := make([]string, 0, len(r.Dirs))
+	for dir := range r.Dirs {
+		dirs = append(dirs, dir)
+	}
+	slices.SortFunc(dirs, func(a, b string) int {
+		scoreA, scoreB := r.Dirs[a].frecency(now), r.Dirs[b].frecency(now)
+		if scoreA != scoreB {
+			if scoreA > scoreB {
+				return -1
+			}
+			return 1
+		}
+		return r.Dirs[b].Last.Compare(r.Dirs[a].Last)
+	})
+	return dirs
+}
+
+// scheduleRecentSave saves the recent list recentSaveDelay after the first
+// of a run of visits, so moving between directories never waits on a write.
+func (app *App) scheduleRecentSave() {
+	if app.recentSaveScheduled {
+		return
+	}
+	app.recentSaveScheduled = true
+	time.AfterFunc(recentSaveDelay, func() {
+		app.postUI(func() {
+			app.recentSaveScheduled = false
+			app.saveRecentDirs()
+		})
+	})
+}
+
+// saveRecentDirs writes pending visits, reporting only the first failure
+// so an unwritable state directory doesn't fill the status bar.
+func (app *App) saveRecentDirs() {
+	recent := app.navigator.recent
+	if recent == nil {
+		return
+	}
+	if err := recent.save(); err != nil && !app.recentSaveFailed {
+		app.recentSaveFailed = true
+		app.statusBar.showError("Cannot save recent directories: " + err.Error())
+	}
+}
+
+func (app *App) recentPickerItems() []PickerItem {
+	recent := app.navigator.recent
+	now := time.Now()
+	var items []PickerItem
+	for _, dir := range recent.ranked(now) {
+		if dir == app.navigator.currentPath {
+			continue
+		}
+		items = append(items, PickerItem{Label: dir, Detail: formatAge(recent.Dirs[dir].Last, now)})
+	}
+	return items
+}
+
+func (app *App) showRecentDirs() {
+	if app.navigator.recent == nil {
+		return
+	}
+	app.showPicker(&Picker{
+		title: "Recent directories",
+		items: app.recentPickerItems(),
+		empty: "No other directories visited yet",
+		onSelect: func(app *App, item PickerItem, _ int) {
+			app.jumpTo(item.Label)
+		},
+		onDelete: func(app *App, item PickerItem) {
+			if err := app.navigator.recent.forget(item.Label); err != nil {
+				app.statusBar.showError("Cannot save recent directories: " + err.Error())
+			}
+			app.picker.setItems(app.recentPickerItems())
+		},
+	})
+}
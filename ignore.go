@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore or .ignore file.
+type ignoreRule struct {
+	base     string   // directory holding the ignore file
+	segments []string // pattern split on "/"
+	anchored bool     // contains a slash, so it matches from base rather than any depth
+	negate   bool
+	dirOnly  bool
+}
+
+// parseIgnoreRule reads a gitignore pattern line, returning false for
+// blanks and comments.
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+func (r ignoreRule) matches(item FileItem) bool {
+	if r.dirOnly && !item.IsDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], item.Name)
+		return ok
+	}
+	rel, err := filepath.Rel(r.base, item.Path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	return matchSegments(r.segments, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// matchSegments matches path segments against a pattern where "**"
+// stands for any number of directories.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// loadIgnoreRules collects the rules that apply to entries of dir: .ignore
+// files from dir and its parents, and .gitignore files too when dir is
+// inside a git repository. Outer files come first so inner ones win.
+func loadIgnoreRules(dir string) (rules []ignoreRule, inRepo bool) {
+	var dirs []string
+	for current := dir; ; {
+		dirs = append(dirs, current)
+		if isRepoRoot(current) {
+			inRepo = true
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if inRepo {
+			rules = append(rules, readIgnoreFile(dirs[i], ".gitignore")...)
+		}
+		rules = append(rules, readIgnoreFile(dirs[i], ".ignore")...)
+	}
+	return rules, inRepo
+}
+
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+func readIgnoreFile(dir, name string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// itemFilter decides which entries of one directory are listed.
+type itemFilter struct {
+	options FilterOptions
+	rules   []ignoreRule
+	inRepo  bool
+}
+
+func newItemFilter(dir string, options FilterOptions) *itemFilter {
+	f := &itemFilter{options: options}
+	if options.GitIgnore {
+		f.rules, f.inRepo = loadIgnoreRules(dir)
+	}
+	return f
+}
+
+// descend returns the filter for subdirectory dir, adding its own ignore
+// files without rereading the parents'.
+func (f *itemFilter) descend(dir string) *itemFilter {
+	if !f.options.GitIgnore {
+		return f
+	}
+	child := &itemFilter{options: f.options, rules: slices.Clip(f.rules), inRepo: f.inRepo || isRepoRoot(dir)}
+	if child.inRepo {
+		child.rules = append(child.rules, readIgnoreFile(dir, ".gitignore")...)
+	}
+	child.rules = append(child.rules, readIgnoreFile(dir, ".ignore")...)
+	return child
+}
+
+func (f *itemFilter) hides(item FileItem) bool {
+	if item.IsHidden && !f.options.ShowHidden {
+		return true
+	}
+	for _, glob := range f.options.Ignore {
+		if ok, _ := filepath.Match(glob, item.Name); ok {
+			return true
+		}
+	}
+	// Last matching rule wins, so a later "!pattern" brings an entry back
+	ignored := false
+	for _, rule := range f.rules {
+		if rule.matches(item) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (app *App) toggleHidden() {
+	nav := app.navigator
+	nav.filter.ShowHidden = !nav.filter.ShowHidden
+	if err := nav.reload(); err != nil {
+		app.statusBar.showError("Cannot reload directory: " + err.Error())
+		return
+	}
+	if nav.filter.ShowHidden {
+		app.statusBar.showMessage("Showing hidden files")
+	} else {
+		app.statusBar.showMessage("Hiding hidden files")
+	}
+}
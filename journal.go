@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+type OpKind string
+
+const (
+	OpCreate OpKind = "create" // To was created; undo trashes it
+	OpCopy   OpKind = "copy"   // From was copied to To; undo trashes To
+	OpRename OpKind = "rename" // From was renamed to To
+	OpMove   OpKind = "move"   // From was moved to To
+	OpTrash  OpKind = "trash"  // From was moved to the trash as TrashName
+)
+
+const maxJournalEntries = 100
+
+type JournalItem struct {
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	TrashName string `json:"trash_name,omitempty"`
+	TrashDir  string `json:"trash_dir,omitempty"` // a volume trash; empty for the home trash
+}
+
+type JournalEntry struct {
+	Kind  OpKind        `json:"kind"`
+	Items []JournalItem `json:"items"`
+	Time  time.Time     `json:"time"`
+}
+
+// Journal is a persisted undo/redo history of file operations.
+type Journal struct {
+	path  string
+	trash *Trash
+	Undo  []JournalEntry `json:"undo"`
+	Redo  []JournalEntry `json:"redo"`
+}
+
+func defaultStateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "powpow")
+}
+
+// LoadJournal reads the journal at path; a missing or corrupt file yields an empty journal.
+func LoadJournal(path string, trash *Trash) *Journal {
+	j := &Journal{path: path, trash: trash}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return j
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		j.Undo, j.Redo = nil, nil
+	}
+	return j
+}
+
+func (j *Journal) save() error {
+	return writeStateFile(j.path, j)
+}
+
+// writeStateFile atomically replaces path with v encoded as JSON.
+func writeStateFile(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// record adds a completed operation and clears the redo stack.
+func (j *Journal) record(kind OpKind, items []JournalItem) error {
+	if j == nil || len(items) == 0 {
+		return nil
+	}
+	j.Undo = append(j.Undo, JournalEntry{Kind: kind, Items: items, Time: time.Now()})
+	if len(j.Undo) > maxJournalEntries {
+		j.Undo = j.Undo[len(j.Undo)-maxJournalEntries:]
+	}
+	j.Redo = nil
+	return j.save()
+}
+
+// undo reverts the latest entry and returns the part that was undone. When
+// an item fails, the items already reverted move to the redo stack and the
+// rest stay on the undo stack, so the journal keeps matching the disk.
+func (j *Journal) undo() (JournalEntry, error) {
+	if len(j.Undo) == 0 {
+		return JournalEntry{}, errors.New("nothing to undo")
+	}
+	top := &j.Undo[len(j.Undo)-1]
+	entry := *top
+	entry.Items = slices.Clone(top.Items)
+	n, err := j.revert(&entry)
+	if n == 0 && err != nil {
+		return JournalEntry{}, err
+	}
+	// Items are reverted from the end
+	split := len(entry.Items) - n
+	done := JournalEntry{Kind: entry.Kind, Items: entry.Items[split:], Time: entry.Time}
+	if err == nil {
+		j.Undo = j.Undo[:len(j.Undo)-1]
+	} else {
+		top.Items = entry.Items[:split:split]
+	}
+	j.Redo = append(j.Redo, done)
+	if saveErr := j.save(); err == nil {
+		err = saveErr
+	}
+	return done, err
+}
+
+// redo re-applies the latest undone entry, splitting it like undo when an
+// item fails.
+func (j *Journal) redo() (JournalEntry, error) {
+	if len(j.Redo) == 0 {
+		return JournalEntry{}, errors.New("nothing to redo")
+	}
+	top := &j.Redo[len(j.Redo)-1]
+	entry := *top
+	entry.Items = slices.Clone(top.Items)
+	n, err := j.apply(&entry)
+	if n == 0 && err != nil {
+		return JournalEntry{}, err
+	}
+	done := JournalEntry{Kind: entry.Kind, Items: entry.Items[:n:n], Time: entry.Time}
+	if err == nil {
+		j.Redo = j.Redo[:len(j.Redo)-1]
+	} else {
+		top.Items = entry.Items[n:]
+	}
+	j.Undo = append(j.Undo, done)
+	if saveErr := j.save(); err == nil {
+		err = saveErr
+	}
+	return done, err
+}
+
+// revert undoes entry, walking its items in reverse order, and returns
+// how many items it reverted.
+func (j *Journal) revert(entry *JournalEntry) (int, error) {
+	for i := len(entry.Items) - 1; i >= 0; i-- {
+		item := &entry.Items[i]
+		var err error
+		switch entry.Kind {
+		case OpCreate, OpCopy:
+			var trashed TrashEntry
+			trashed, err = j.trash.put(item.To)
+			item.TrashName, item.TrashDir = trashed.Name, trashed.TrashDir
+		case OpRename, OpMove:
+			err = moveIfFree(item.To, item.From)
+		case OpTrash:
+			err = j.restoreIfFree(*item, item.From)
+		}
+		if err != nil {
+			return len(entry.Items) - 1 - i, err
+		}
+	}
+	return len(entry.Items), nil
+}
+
+// apply re-does entry after it was reverted and returns how many items it
+// applied.
+func (j *Journal) apply(entry *JournalEntry) (int, error) {
+	for i := range entry.Items {
+		item := &entry.Items[i]
+		var err error
+		switch entry.Kind {
+		case OpCreate, OpCopy:
+			err = j.restoreIfFree(*item, item.To)
+		case OpRename, OpMove:
+			err = moveIfFree(item.From, item.To)
+		case OpTrash:
+			var trashed TrashEntry
+			trashed, err = j.trash.put(item.From)
+			item.TrashName, item.TrashDir = trashed.Name, trashed.TrashDir
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(entry.Items), nil
+}
+
+func (j *Journal) restoreIfFree(item JournalItem, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+	return j.trash.restoreTo(TrashEntry{Name: item.TrashName, TrashDir: item.TrashDir}, target)
+}
+
+// moveIfFree moves src to dst without ever overwriting an existing dst.
+func moveIfFree(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	return movePath(src, dst)
+}
+
+// describe summarizes an entry for status messages.
+func (e JournalEntry) describe() string {
+	if len(e.Items) == 0 {
+		return string(e.Kind)
+	}
+	first := e.Items[0]
+	var what string
+	switch e.Kind {
+	case OpRename:
+		what = fmt.Sprintf("rename %s -> %s", filepath.Base(first.From), filepath.Base(first.To))
+	case OpCreate, OpCopy:
+		what = fmt.Sprintf("%s %s", e.Kind, filepath.Base(first.To))
+	default:
+		what = fmt.Sprintf("%s %s", e.Kind, filepath.Base(first.From))
+	}
+	if len(e.Items) > 1 {
+		what += fmt.Sprintf(" (+%d more)", len(e.Items)-1)
+	}
+	return what
+}
+
+func (app *App) recordOp(kind OpKind, items []JournalItem) {
+	if err := app.journal.record(kind, items); err != nil {
+		app.statusBar.showError("Cannot save undo journal: " + err.Error())
+	}
+}
+
+func (app *App) undoOp() {
+	entry, err := app.journal.undo()
+	app.navigator.loadDirectory()
+	if err != nil && len(entry.Items) > 0 {
+		app.statusBar.showError("Undid " + entry.describe() + ", then stopped: " + err.Error())
+		return
+	} else if err != nil {
+		app.statusBar.showError("Cannot undo: " + err.Error())
+		return
+	}
+	app.statusBar.showMessage("Undid " + entry.describe())
+}
+
+func (app *App) redoOp() {
+	entry, err := app.journal.redo()
+	app.navigator.loadDirectory()
+	if err != nil && len(entry.Items) > 0 {
+		app.statusBar.showError("Redid " + entry.describe() + ", then stopped: " + err.Error())
+		return
+	} else if err != nil {
+		app.statusBar.showError("Cannot redo: " + err.Error())
+		return
+	}
+	app.statusBar.showMessage("Redid " + entry.describe())
+}
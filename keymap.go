@@ -0,0 +1,678 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// KeyContext selects which bindings apply: each mode has its own keymap.
+type KeyContext int
+
+const (
+	ContextNormal KeyContext = iota
+	ContextSearch
+	ContextPopup
+	ContextTrash
+	ContextHelp
+	ContextFind
+	ContextGrep
+	ContextPicker
+	ContextPager
+)
+
+// Action is a named command that keys can be bound to.
+type Action struct {
+	Name        string
+	Context     KeyContext
+	Section     string // heading the action is listed under in help
+	Description string
+	Keys        []string // default bindings
+	Run         func(app *App)
+}
+
+var (
+	actions       []*Action
+	actionsByName = make(map[string]*Action)
+)
+
+func registerAction(action *Action) {
+	if _, exists := actionsByName[action.Name]; exists {
+		panic("duplicate action " + action.Name)
+	}
+	actions = append(actions, action)
+	actionsByName[action.Name] = action
+}
+
+// defaultKeymap backs Apps created without a loaded config, such as in tests.
+var defaultKeymap *Keymap
+
+func init() {
+	nav := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "Navigation", Description: description, Keys: keys, Run: run})
+	}
+	nav("nav.down", "Move down", func(app *App) { app.navigator.moveSelection(1) }, "j", "down")
+	nav("nav.up", "Move up", func(app *App) { app.navigator.moveSelection(-1) }, "k", "up")
+	nav("nav.open", "Enter directory / Open file", (*App).openSelected, "l", "right", "enter")
+	nav("nav.parent", "Go to parent directory", (*App).goUp, "h", "left", "backspace")
+	nav("nav.top", "Jump to first item", func(app *App) { app.navigator.selectedIdx = 0 }, "home", "g g")
+	nav("nav.bottom", "Jump to last item", func(app *App) {
+		app.navigator.selectedIdx = len(app.navigator.filteredItems) - 1
+		app.navigator.clampSelection()
+	}, "end", "G")
+	nav("nav.follow", "Follow symlink to its real location", (*App).followLink, "g l")
+	nav("nav.goto", "Go to a typed path", (*App).startGoTo, ":")
+	nav("nav.back", "Go back to the previous directory", (*App).goBack, "H")
+	nav("nav.forward", "Go forward again", (*App).goForward, "L")
+	nav("nav.recent", "Pick a recently visited directory", (*App).showRecentDirs, "r")
+	nav("nav.page_up", "Jump up a page", func(app *App) { app.navigator.moveSelection(-app.cfg().PageSize) }, "pgup")
+	nav("nav.page_down", "Jump down a page", func(app *App) { app.navigator.moveSelection(app.cfg().PageSize) }, "pgdn")
+
+	file := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "File Operations", Description: description, Keys: keys, Run: run})
+	}
+	file("file.new", "Create new file", func(app *App) {
+		app.showPopup(PopupCreateFile, "Create new file", "Name: ", "", nil)
+	}, "ctrl+n")
+	file("file.new_folder", "Create new folder", func(app *App) {
+		app.showPopup(PopupCreateFolder, "Create new folder", "Name: ", "", nil)
+	}, "ctrl+f")
+	file("file.open", "Open file(s) in editor or their opener", (*App).openFile, "ctrl+o")
+	file("file.open_with", "Open with...", (*App).openWith, "o")
+	file("file.rename", "Rename file/folder", (*App).startRename, "ctrl+r")
+	file("file.delete", "Move file/folder to trash", func(app *App) { app.confirmTargets(PopupDelete, "Delete Confirmation") }, "ctrl+d", "d d")
+	file("file.purge", "Delete permanently", func(app *App) { app.confirmTargets(PopupPurge, "Delete Forever") }, "D")
+	file("file.trash", "Browse trash (restore/purge)", (*App).openTrash, "T")
+
+	selection := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "Selection", Description: description, Keys: keys, Run: run})
+	}
+	selection("select.toggle", "Mark / unmark item", func(app *App) {
+		app.navigator.toggleMark()
+		app.navigator.moveSelection(1)
+	}, "space")
+	selection("select.all", "Mark all (visible) items", func(app *App) { app.navigator.markAll() }, "a")
+	selection("select.invert", "Invert marks", func(app *App) { app.navigator.invertMarks() }, "*")
+	selection("select.glob", "Mark by glob pattern", func(app *App) {
+		app.showPopup(PopupSelectGlob, "Mark by pattern", "Glob: ", "", nil)
+	}, "+")
+	selection("app.cancel", "Cancel transfer / clear marks", (*App).cancelOrClear, "esc")
+
+	clip := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "Clipboard", Description: description, Keys: keys, Run: run})
+	}
+	clip("clip.yank", "Yank (copy) marked/selected items", func(app *App) { app.yankItems(ClipCopy) }, "y")
+	clip("clip.cut", "Cut marked/selected items", func(app *App) { app.yankItems(ClipCut) }, "x")
+	clip("clip.paste", "Paste into current directory", (*App).pasteItems, "p")
+
+	registerAction(&Action{Name: "edit.undo", Context: ContextNormal, Section: "Undo", Description: "Undo last file operation", Keys: []string{"u"}, Run: (*App).undoOp})
+	registerAction(&Action{Name: "edit.redo", Context: ContextNormal, Section: "Undo", Description: "Redo", Keys: []string{"ctrl+y"}, Run: (*App).redoOp})
+
+	view := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "View", Description: description, Keys: keys, Run: run})
+	}
+	view("view.preview", "Toggle preview pane", (*App).togglePreview, "v")
+	view("view.hidden", "Show or hide hidden files", (*App).toggleHidden, ".")
+	view("view.details", "Toggle detail columns (size, mtime, permissions, owner)", (*App).toggleDetails, "i")
+	view("sort.next", "Cycle sort (name, natural, size, mtime, extension, type)", func(app *App) {
+		app.changeSort(SortOptions.next)
+	}, "s")
+	view("sort.reverse", "Reverse sort order", func(app *App) {
+		app.changeSort(func(o SortOptions) SortOptions {
+			o.Reverse = !o.Reverse
+			return o
+		})
+	}, "S")
+
+	bookmark := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "Bookmarks", Description: description, Keys: keys, Run: run})
+	}
+	bookmark("bookmark.list", "Pick a bookmark to jump to", (*App).showBookmarks, "b")
+	bookmark("bookmark.add", "Bookmark this directory", (*App).startAddBookmark, "B")
+	bookmark("mark.set", "Set mark: follow with a letter", func(app *App) { app.markPrompt = MarkSet }, "m")
+	bookmark("mark.jump", "Jump to mark: follow with a letter", func(app *App) { app.markPrompt = MarkJump }, "'")
+
+	general := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextNormal, Section: "General", Description: description, Keys: keys, Run: run})
+	}
+	general("search.start", "Start fuzzy search", (*App).startSearch, "/")
+	general("find.start", "Find files anywhere below this directory", (*App).startFind, "f")
+	general("grep.start", "Search file contents below this directory", (*App).startGrep, "ctrl+g")
+	general("app.help", "Show this help", func(app *App) {
+		app.helpMode = true
+		app.helpScroll = 0
+	}, "f1", "?")
+	general("app.palette", "Run a command by name", (*App).showPalette, "ctrl+p")
+	general("app.log", "Show output of background commands", (*App).showCommandLog, "ctrl+l")
+	general("app.shell_command", "Run a shell command here", (*App).startShellCommand, "!")
+	general("app.shell", "Open a shell here", (*App).openShell, "$")
+	general("app.quit", "Quit application", (*App).quit, "q", "ctrl+c")
+
+	search := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextSearch, Section: "Search Mode", Description: description, Keys: keys, Run: run})
+	}
+	search("search.down", "Next result", func(app *App) {
+		app.navigator.selectedIdx++
+		app.navigator.clampSelection()
+	}, "down")
+	search("search.up", "Previous result", func(app *App) {
+		app.navigator.selectedIdx--
+		app.navigator.clampSelection()
+	}, "up")
+	search("search.mark", "Mark / unmark item", func(app *App) {
+		app.navigator.toggleMark()
+		app.navigator.moveSelection(1)
+	}, "tab")
+	search("search.select", "Select file/directory", (*App).selectSearchResult, "enter")
+	search("search.backspace", "Delete search character", func(app *App) {
+		if query := app.navigator.searchQuery; len(query) > 0 {
+			app.navigator.searchQuery = query[:len(query)-1]
+			app.navigator.setSearch(app.navigator.searchQuery)
+		}
+	}, "backspace")
+	search("search.exit", "Exit search mode", (*App).exitSearch, "esc")
+
+	find := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextFind, Section: "Find Mode", Description: description, Keys: keys, Run: run})
+	}
+	find("find.down", "Next result", func(app *App) { app.finder.moveSelection(1) }, "down")
+	find("find.up", "Previous result", func(app *App) { app.finder.moveSelection(-1) }, "up")
+	find("find.select", "Go to the result's directory", (*App).selectFindResult, "enter")
+	find("find.backspace", "Delete search character", func(app *App) {
+		if query := app.finder.query; len(query) > 0 {
+			app.finder.setQuery(query[:len(query)-1])
+		}
+	}, "backspace")
+	find("find.exit", "Exit find mode", (*App).exitFind, "esc")
+
+	grep := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextGrep, Section: "Grep Mode", Description: description, Keys: keys, Run: run})
+	}
+	grep("grep.down", "Next match", func(app *App) { app.grep.moveSelection(1) }, "down")
+	grep("grep.up", "Previous match", func(app *App) { app.grep.moveSelection(-1) }, "up")
+	grep("grep.select", "Open the file at the matching line", (*App).openGrepResult, "enter")
+	grep("grep.backspace", "Delete search character", func(app *App) {
+		if query := app.grep.query; len(query) > 0 {
+			app.setGrepQuery(query[:len(query)-1])
+		}
+	}, "backspace")
+	grep("grep.regex", "Switch between literal and regex search", (*App).toggleGrepRegex, "ctrl+r")
+	grep("grep.exit", "Exit grep mode", (*App).exitGrep, "esc")
+
+	trash := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextTrash, Section: "Trash", Description: description, Keys: keys, Run: run})
+	}
+	trash("trash.down", "Move down", func(app *App) { app.moveTrashSelection(1) }, "j", "down")
+	trash("trash.up", "Move up", func(app *App) { app.moveTrashSelection(-1) }, "k", "up")
+	trash("trash.restore", "Restore item", (*App).restoreTrashEntry, "enter", "r")
+	trash("trash.purge", "Delete item forever", func(app *App) {
+		if entry := app.trashView.getSelectedEntry(); entry != nil {
+			app.showTrashPopup(PopupTrashPurge, entry)
+		}
+	}, "D", "delete")
+	trash("trash.empty", "Empty trash", func(app *App) {
+		if len(app.trashView.entries) > 0 {
+			app.showTrashPopup(PopupEmptyTrash, nil)
+		}
+	}, "E")
+	trash("trash.close", "Back to file list", (*App).closeTrash, "esc", "q", "T")
+
+	popup := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextPopup, Section: "Popups", Description: description, Keys: keys, Run: run})
+	}
+	popup("popup.confirm", "Accept input / confirm trashing", (*App).submitPopup, "enter")
+	popup("popup.yes", "Answer yes", func(app *App) { app.answerPopup(true) }, "y", "Y")
+	popup("popup.no", "Answer no", func(app *App) { app.answerPopup(false) }, "n", "N")
+	popup("popup.backspace", "Delete input character", func(app *App) {
+		if !app.popup.isConfirmation() {
+			app.backspacePopupInput()
+		}
+	}, "backspace")
+	popup("popup.complete", "Complete path (go-to prompt)", (*App).completeGoTo, "tab")
+	popup("popup.cancel", "Cancel", (*App).hidePopup, "esc")
+
+	picker := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextPicker, Section: "Pickers", Description: description, Keys: keys, Run: run})
+	}
+	picker("picker.down", "Next item", func(app *App) { app.picker.moveSelection(1) }, "down", "ctrl+n")
+	picker("picker.up", "Previous item", func(app *App) { app.picker.moveSelection(-1) }, "up", "ctrl+p")
+	picker("picker.select", "Choose item", (*App).selectPickerItem, "enter")
+	picker("picker.delete", "Remove item", (*App).deletePickerItem, "ctrl+d")
+	picker("picker.backspace", "Delete filter character", func(app *App) {
+		if query := app.picker.query; len(query) > 0 {
+			app.picker.setQuery(query[:len(query)-1])
+		}
+	}, "backspace")
+	picker("picker.cancel", "Close", (*App).closePicker, "esc")
+
+	pager := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextPager, Section: "Pager", Description: description, Keys: keys, Run: run})
+	}
+	pager("pager.down", "Scroll down", func(app *App) { app.scrollPager(1) }, "j", "down")
+	pager("pager.up", "Scroll up", func(app *App) { app.scrollPager(-1) }, "k", "up")
+	pager("pager.page_down", "Scroll down a page", func(app *App) { app.scrollPager(app.pagerRows()) }, "pgdn", "space")
+	pager("pager.page_up", "Scroll up a page", func(app *App) { app.scrollPager(-app.pagerRows()) }, "pgup")
+	pager("pager.top", "Jump to the top", func(app *App) { app.scrollPager(-app.pager.scroll) }, "home", "g g")
+	pager("pager.bottom", "Jump to the end", (*App).pagerBottom, "end", "G")
+	pager("pager.stop", "Stop the running command", (*App).stopPagerCommand, "ctrl+c")
+	pager("pager.close", "Close, stopping the running command", (*App).closePager, "esc", "q")
+
+	help := func(name, description string, run func(app *App), keys ...string) {
+		registerAction(&Action{Name: name, Context: ContextHelp, Section: "Help Screen", Description: description, Keys: keys, Run: run})
+	}
+	help("help.down", "Scroll down", func(app *App) { app.helpScroll++ }, "j", "down")
+	help("help.up", "Scroll up", func(app *App) { app.helpScroll-- }, "k", "up")
+	help("help.close", "Close help", func(app *App) { app.helpMode = false }, "esc", "f1", "q")
+
+	keymap, err := NewKeymap(nil)
+	if err != nil {
+		panic("default keymap: " + err.Error())
+	}
+	defaultKeymap = keymap
+}
+
+// keyNode is a trie over key sequences; a node either runs an action or
+// waits for more keys, never both.
+type keyNode struct {
+	action *Action
+	next   map[string]*keyNode
+}
+
+// Keymap resolves key sequences to actions for every context.
+type Keymap struct {
+	bindings map[string][]string // action name -> key specs
+	roots    map[KeyContext]*keyNode
+	actions  []*Action // the built-in actions, then the extra ones
+}
+
+// NewKeymap builds the default bindings with overrides applied. An override
+// replaces all default keys of its action; an empty list unbinds it. Extra
+// actions, such as configured commands, are bound alongside the built-in ones.
+func NewKeymap(overrides map[string][]string, extra ...*Action) (*Keymap, error) {
+	k := &Keymap{
+		bindings: make(map[string][]string),
+		roots:    make(map[KeyContext]*keyNode),
+		actions:  append(append([]*Action(nil), actions...), extra...),
+	}
+	for _, action := range k.actions {
+		if _, exists := k.bindings[action.Name]; exists {
+			return nil, fmt.Errorf("duplicate action %s", action.Name)
+		}
+		k.bindings[action.Name] = action.Keys
+	}
+	for _, name := range sortedKeys(overrides) {
+		if _, ok := k.bindings[name]; !ok {
+			return nil, fmt.Errorf("unknown action %s", name)
+		}
+		k.bindings[name] = overrides[name]
+	}
+
+	for _, action := range k.actions {
+		for _, spec := range k.bindings[action.Name] {
+			sequence, err := parseKeySpec(spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", action.Name, err)
+			}
+			if err := k.bind(action, sequence); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return k, nil
+}
+
+func (k *Keymap) bind(action *Action, sequence []string) error {
+	node := k.roots[action.Context]
+	if node == nil {
+		node = &keyNode{}
+		k.roots[action.Context] = node
+	}
+	for i, key := range sequence {
+		if node.action != nil {
+			return fmt.Errorf("%s (%s) blocks %s (%s)", formatKeys(sequence[:i]), node.action.Name, formatKeys(sequence), action.Name)
+		}
+		if node.next == nil {
+			node.next = make(map[string]*keyNode)
+		}
+		child := node.next[key]
+		if child == nil {
+			child = &keyNode{}
+			node.next[key] = child
+		}
+		node = child
+	}
+
+	switch {
+	case node.action == action:
+		return nil
+	case node.action != nil:
+		return fmt.Errorf("%s is bound to both %s and %s", formatKeys(sequence), node.action.Name, action.Name)
+	case len(node.next) > 0:
+		return fmt.Errorf("%s (%s) blocks longer sequences bound to %s", formatKeys(sequence), action.Name, node.firstAction().Name)
+	}
+	node.action = action
+	return nil
+}
+
+func (n *keyNode) firstAction() *Action {
+	if n.action != nil {
+		return n.action
+	}
+	for _, key := range sortedKeys(n.next) {
+		if action := n.next[key].firstAction(); action != nil {
+			return action
+		}
+	}
+	return nil
+}
+
+// lookup resolves sequence in ctx. A nil action with pending set means the
+// sequence is the start of a longer binding.
+func (k *Keymap) lookup(ctx KeyContext, sequence []string) (action *Action, pending bool) {
+	node := k.roots[ctx]
+	for _, key := range sequence {
+		if node == nil {
+			return nil, false
+		}
+		node = node.next[key]
+	}
+	if node == nil {
+		return nil, false
+	}
+	return node.action, node.action == nil && len(node.next) > 0
+}
+
+func (k *Keymap) keysFor(name string) []string {
+	return k.bindings[name]
+}
+
+// hint is the first key bound to an action, formatted for on-screen hints.
+func (k *Keymap) hint(name string) string {
+	keys := k.keysFor(name)
+	if len(keys) == 0 {
+		return "(unbound)"
+	}
+	sequence, _ := parseKeySpec(keys[0])
+	return formatKeys(sequence)
+}
+
+// statusHint is the idle status bar text.
+func (k *Keymap) statusHint(autocd bool) string {
+	if autocd {
+		return fmt.Sprintf("Ready - AutoCD | %s=Help %s:inherit directory %s:search %s:new file %s:open",
+			k.hint("app.help"), k.hint("app.quit"), k.hint("search.start"), k.hint("file.new"), k.hint("file.open"))
+	}
+	return fmt.Sprintf("Ready | %s=Help %s:quit %s:search %s:new file %s:open",
+		k.hint("app.help"), k.hint("app.quit"), k.hint("search.start"), k.hint("file.new"), k.hint("file.open"))
+}
+
+type helpSection struct {
+	title string
+	rows  [][2]string // keys, description
+}
+
+// helpSections lists every bound action, grouped in registration order.
+func (k *Keymap) helpSections() []helpSection {
+	var sections []helpSection
+	index := make(map[string]int)
+	for _, action := range k.actions {
+		keys := k.keysFor(action.Name)
+		if len(keys) == 0 {
+			continue
+		}
+		i, ok := index[action.Section]
+		if !ok {
+			i = len(sections)
+			index[action.Section] = i
+			sections = append(sections, helpSection{title: action.Section})
+			if action.Context == ContextSearch {
+				sections[i].rows = append(sections[i].rows, [2]string{"Type", "Filter files with fuzzy matching"})
+			}
+		}
+		var formatted []string
+		for _, spec := range keys {
+			sequence, _ := parseKeySpec(spec)
+			formatted = append(formatted, formatKeys(sequence))
+		}
+		sections[i].rows = append(sections[i].rows, [2]string{strings.Join(formatted, ", "), action.Description})
+	}
+	return sections
+}
+
+// helpLines is the in-app help screen text.
+func (k *Keymap) helpLines() []string {
+	lines := []string{"PowPow - Keyboard Shortcuts", ""}
+	for _, section := range k.helpSections() {
+		lines = append(lines, section.title+":")
+		for _, row := range section.rows {
+			lines = append(lines, fmt.Sprintf("  %-20s%s", row[0], row[1]))
+		}
+		lines = append(lines, "")
+	}
+	return append(lines, fmt.Sprintf("Press %s to return to file explorer", k.hint("help.close")))
+}
+
+// markdownHelp renders the key tables printed by --help.
+func (k *Keymap) markdownHelp() string {
+	var b strings.Builder
+	for _, section := range k.helpSections() {
+		keyWidth, descWidth := len("Key"), len("Action")
+		for _, row := range section.rows {
+			keyWidth = max(keyWidth, utf8.RuneCountInString(row[0]))
+			descWidth = max(descWidth, utf8.RuneCountInString(row[1]))
+		}
+		fmt.Fprintf(&b, "\n### %s\n", section.title)
+		fmt.Fprintf(&b, "| %-*s | %-*s |\n", keyWidth, "Key", descWidth, "Action")
+		fmt.Fprintf(&b, "|%s|%s|\n", strings.Repeat("-", keyWidth+2), strings.Repeat("-", descWidth+2))
+		for _, row := range section.rows {
+			fmt.Fprintf(&b, "| %-*s | %-*s |\n", keyWidth, row[0], descWidth, row[1])
+		}
+		if section.title == "Selection" {
+			b.WriteString("\nDelete, purge and open act on all marked items (or the selected one).\n")
+		}
+	}
+	return b.String()
+}
+
+// Key names as written in config files. Single characters stand for
+// themselves and are case-sensitive; everything else is case-insensitive.
+var keyAliases = map[string]string{
+	"enter": "enter", "return": "enter",
+	"esc": "esc", "escape": "esc",
+	"tab": "tab", "shift+tab": "shift+tab", "backtab": "shift+tab",
+	"backspace": "backspace", "bs": "backspace",
+	"delete": "delete", "del": "delete",
+	"insert": "insert", "ins": "insert",
+	"space": "space", "spc": "space",
+	"up": "up", "down": "down", "left": "left", "right": "right",
+	"home": "home", "end": "end",
+	"pgup": "pgup", "pageup": "pgup",
+	"pgdn": "pgdn", "pgdown": "pgdn", "pagedown": "pgdn",
+}
+
+var specialKeyNames = map[tcell.Key]string{
+	tcell.KeyUp: "up", tcell.KeyDown: "down", tcell.KeyLeft: "left", tcell.KeyRight: "right",
+	tcell.KeyHome: "home", tcell.KeyEnd: "end", tcell.KeyPgUp: "pgup", tcell.KeyPgDn: "pgdn",
+	tcell.KeyInsert: "insert", tcell.KeyDelete: "delete", tcell.KeyBacktab: "shift+tab",
+}
+
+// sharedCtrlKeys are Ctrl+letter combinations terminals send as the same
+// byte as another key, so they can never be told apart from it.
+var sharedCtrlKeys = map[string]string{"h": "backspace", "i": "tab", "m": "enter"}
+
+var keyDisplayNames = map[string]string{
+	"enter": "Enter", "esc": "ESC", "tab": "Tab", "shift+tab": "Shift+Tab",
+	"backspace": "Backspace", "delete": "Delete", "insert": "Insert", "space": "Space",
+	"up": "Up", "down": "Down", "left": "Left", "right": "Right",
+	"home": "Home", "end": "End", "pgup": "PgUp", "pgdn": "PgDn",
+}
+
+// parseKeySpec parses a space-separated key sequence such as "g g" or
+// "ctrl+x d" into canonical key names.
+func parseKeySpec(spec string) ([]string, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+	sequence := make([]string, len(fields))
+	for i, field := range fields {
+		key, err := parseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		sequence[i] = key
+	}
+	return sequence, nil
+}
+
+func parseKey(name string) (string, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		return name, nil
+	}
+	lower := strings.ToLower(name)
+	if key, ok := keyAliases[lower]; ok {
+		return key, nil
+	}
+	if n := strings.TrimPrefix(lower, "f"); n != lower {
+		var number int
+		if _, err := fmt.Sscanf(n, "%d", &number); err == nil && fmt.Sprint(number) == n && number >= 1 && number <= 12 {
+			return lower, nil
+		}
+	}
+	for _, prefix := range []string{"ctrl+", "ctrl-", "c-"} {
+		if rest := strings.TrimPrefix(lower, prefix); rest != lower {
+			if same, ok := sharedCtrlKeys[rest]; ok {
+				return "", fmt.Errorf("invalid key %q (terminals send it as %s; bind %q instead)", name, same, same)
+			}
+			if len(rest) == 1 && rest[0] >= 'a' && rest[0] <= 'z' {
+				return "ctrl+" + rest, nil
+			}
+			return "", fmt.Errorf("invalid key %q (ctrl combines with a letter)", name)
+		}
+	}
+	for _, prefix := range []string{"alt+", "alt-", "a-", "m-"} {
+		if strings.HasPrefix(lower, prefix) {
+			// The character after alt keeps its case
+			rest := name[len(prefix):]
+			if utf8.RuneCountInString(rest) == 1 {
+				return "alt+" + rest, nil
+			}
+			return "", fmt.Errorf("invalid key %q (alt combines with a single character)", name)
+		}
+	}
+	return "", fmt.Errorf("unknown key %q", name)
+}
+
+// keyName is the canonical name of a key event, or "" for keys that
+// cannot be bound.
+func keyName(ev *tcell.EventKey) string {
+	switch ev.Key() {
+	case tcell.KeyRune:
+		name := string(ev.Rune())
+		if ev.Rune() == ' ' {
+			name = "space"
+		}
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			return "alt+" + name
+		}
+		return name
+	case tcell.KeyEnter:
+		return "enter"
+	case tcell.KeyEscape:
+		return "esc"
+	case tcell.KeyTab:
+		return "tab"
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		return "backspace"
+	}
+	if name, ok := specialKeyNames[ev.Key()]; ok {
+		return name
+	}
+	if ev.Key() >= tcell.KeyF1 && ev.Key() <= tcell.KeyF12 {
+		return fmt.Sprintf("f%d", ev.Key()-tcell.KeyF1+1)
+	}
+	if ev.Key() >= tcell.KeyCtrlA && ev.Key() <= tcell.KeyCtrlZ {
+		return "ctrl+" + string(rune('a'+ev.Key()-tcell.KeyCtrlA))
+	}
+	return ""
+}
+
+// formatKeys renders a key sequence for help text: "gg", "Ctrl+N", "ESC".
+func formatKeys(sequence []string) string {
+	parts := make([]string, len(sequence))
+	allChars := true
+	for i, key := range sequence {
+		parts[i] = formatKey(key)
+		if utf8.RuneCountInString(key) != 1 {
+			allChars = false
+		}
+	}
+	if allChars {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatKey(key string) string {
+	if name, ok := keyDisplayNames[key]; ok {
+		return name
+	}
+	switch {
+	case strings.HasPrefix(key, "ctrl+"):
+		return "Ctrl+" + strings.ToUpper(key[len("ctrl+"):])
+	case strings.HasPrefix(key, "alt+"):
+		return "Alt+" + formatKey(key[len("alt+"):])
+	case len(key) > 1 && key[0] == 'f':
+		return strings.ToUpper(key)
+	}
+	return key
+}
+
+// keyContext is the context the next key event is resolved in.
+func (app *App) keyContext() KeyContext {
+	switch {
+	case app.helpMode:
+		return ContextHelp
+	case app.popup.active:
+		return ContextPopup
+	case app.picker != nil:
+		return ContextPicker
+	case app.pager != nil:
+		return ContextPager
+	case app.trashMode:
+		return ContextTrash
+	case app.finder != nil:
+		return ContextFind
+	case app.grep != nil:
+		return ContextGrep
+	case app.navigator.searchMode:
+		return ContextSearch
+	}
+	return ContextNormal
+}
+
+func (app *App) keys() *Keymap {
+	if app.keymap != nil {
+		return app.keymap
+	}
+	return defaultKeymap
+}
+
+// dispatchKey feeds key into the pending sequence. A key that neither
+// completes nor extends a binding drops the sequence and is retried alone.
+func (app *App) dispatchKey(ctx KeyContext, key string) {
+	sequence := append(append([]string(nil), app.pendingKeys...), key)
+	action, pending := app.keys().lookup(ctx, sequence)
+	switch {
+	case action != nil:
+		app.pendingKeys = nil
+		action.Run(app)
+	case pending:
+		app.pendingKeys = sequence
+	case len(app.pendingKeys) > 0:
+		app.pendingKeys = nil
+		app.dispatchKey(ctx, key)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+var errNotLink = errors.New("not a symbolic link")
+
+func brokenLinkMessage(item FileItem) string {
+	return "Broken link: " + item.Name + " -> " + item.Target
+}
+
+// followLink leaves the logical path for the physical one. On a symlink it
+// goes where the link really points, selecting the target when that is a
+// file; otherwise it moves from a directory reached through links to the
+// same directory under its real path.
+func (n *Navigator) followLink() error {
+	selected := n.getSelectedItem()
+	if selected != nil && selected.IsLink {
+		real, err := filepath.EvalSymlinks(selected.Path)
+		if err != nil {
+			return err
+		}
+		if selected.IsDir {
+			return n.changeDirectory(real)
+		}
+		return n.revealPath(real)
+	}
+
+	real, err := filepath.EvalSymlinks(n.currentPath)
+	if err != nil {
+		return err
+	}
+	if real == n.currentPath {
+		return errNotLink
+	}
+	var selectedPath string
+	if selected != nil {
+		selectedPath = filepath.Join(real, selected.Name)
+	}
+	if err := n.changeDirectory(real); err != nil {
+		return err
+	}
+	n.selectPath(selectedPath)
+	return nil
+}
+
+func (app *App) followLink() {
+	selected := app.navigator.getSelectedItem()
+	err := app.navigator.followLink()
+	switch {
+	case err == nil:
+	case selected != nil && selected.Broken:
+		app.statusBar.showError(brokenLinkMessage(*selected))
+	case errors.Is(err, errNotLink):
+		app.statusBar.showError("Neither the selection nor this directory is a symbolic link")
+	default:
+		app.statusBar.showError("Cannot follow link: " + err.Error())
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// loadBatchSize is how many entries are read and merged into the listing
+// at a time when loading in the background.
+const loadBatchSize = 1000
+
+// dirLoad is a directory being read in the background. Closing done stops
+// it; batches from a load that is no longer the navigator's are dropped.
+type dirLoad struct {
+	path    string
+	refresh bool       // reloading the listed directory: swap in the result at the end
+	items   []FileItem // collected so far when refreshing
+	hidden  int
+	stale   bool // the directory changed while being read: read it again when done
+	done    chan struct{}
+}
+
+// fileItemFromEntry builds an item from a directory entry. Entry.Info is a
+// single lstat; only symlinks cost more, to read and resolve the link.
+func fileItemFromEntry(dir string, entry os.DirEntry) (FileItem, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return FileItem{}, err
+	}
+	return fileItemFromLstat(filepath.Join(dir, entry.Name()), info)
+}
+
+// readItems turns entries into items, skipping those filter hides before
+// paying for a stat where it can.
+func readItems(dir string, entries []os.DirEntry, filter *itemFilter) (items []FileItem, hidden int) {
+	items = make([]FileItem, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		// Symlinks must be stat'ed to know whether they are directories
+		if entry.Type()&os.ModeSymlink == 0 {
+			probe := FileItem{Name: name, Path: filepath.Join(dir, name), IsDir: entry.IsDir(), IsHidden: strings.HasPrefix(name, ".")}
+			if filter.hides(probe) {
+				hidden++
+				continue
+			}
+		}
+		item, err := fileItemFromEntry(dir, entry)
+		if err != nil {
+			continue
+		}
+		if filter.hides(item) {
+			hidden++
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, hidden
+}
+
+// openDirectory opens path for listing, failing early for anything that
+// isn't a readable directory.
+func openDirectory(path string) (*os.File, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := dir.Stat()
+	if err != nil {
+		dir.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		dir.Close()
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return dir, nil
+}
+
+func (n *Navigator) cancelLoading() {
+	if n.load != nil {
+		close(n.load.done)
+		n.load = nil
+	}
+}
+
+// startLoading reads dir on a background goroutine. A new directory fills
+// in batch by batch; a refresh of the listed one keeps showing the old
+// items until the new listing is complete.
+func (n *Navigator) startLoading(dir *os.File, filter *itemFilter) {
+	refresh := n.listed == n.currentPath
+	load := &dirLoad{path: n.currentPath, refresh: refresh, done: make(chan struct{})}
+	n.load = load
+	if !refresh {
+		n.listed = ""
+		n.items = nil
+		n.hiddenCount = 0
+		n.updateFilteredItems()
+		n.clampSelection()
+	}
+
+	post := n.post
+	go func() {
+		defer dir.Close()
+		for {
+			// A read error other than EOF ends the listing with what was read
+			entries, err := dir.ReadDir(loadBatchSize)
+			select {
+			case <-load.done:
+				return
+			default:
+			}
+			items, hidden := readItems(load.path, entries, filter)
+			final := err != nil
+			post(func() {
+				if n.load == load {
+					n.addBatch(load, items, hidden, final)
+				}
+			})
+			if final {
+				return
+			}
+		}
+	}()
+}
+
+// addBatch merges a batch into the sorted listing. The cursor stays on the
+// item it is on unless it was left at the top of a new directory, and
+// moves to wantSelected once that item shows up. The scroll remembered for
+// the directory is restored when the last batch arrives.
+func (n *Navigator) addBatch(load *dirLoad, items []FileItem, hidden int, final bool) {
+	if load.refresh {
+		load.items = append(load.items, items...)
+		load.hidden += hidden
+		if !final {
+			return
+		}
+		items, hidden = load.items, load.hidden
+		n.items, n.hiddenCount = nil, 0
+	}
+	slices.SortStableFunc(items, n.compareItems)
+
+	var selectedPath string
+	if selected := n.getSelectedItem(); selected != nil && (load.refresh || n.selectedIdx > 0) {
+		selectedPath = selected.Path
+	}
+	wantScroll := n.wantScroll
+	if n.wantSelected != "" {
+		selectedPath = n.wantSelected
+	}
+	n.items = mergeSorted(n.items, items, n.compareItems)
+	n.hiddenCount += hidden
+	if final {
+		n.load = nil
+		n.listed = load.path
+		n.pruneMarks()
+	}
+	n.updateFilteredItems()
+	n.selectPath(selectedPath)
+	n.clampSelection()
+	// Later batches can still shift the rows, so a remembered scroll is
+	// applied once the listing is complete
+	if selected := n.getSelectedItem(); final && wantScroll > 0 && selected != nil && selected.Path == selectedPath {
+		n.scrollOffset = wantScroll
+	} else if !final {
+		n.wantScroll = wantScroll
+	}
+	if final && load.stale {
+		n.reload()
+	}
+}
+
+// compareItems is less as a three-way comparison for the slices package.
+func (n *Navigator) compareItems(a, b FileItem) int {
+	switch {
+	case n.less(a, b):
+		return -1
+	case n.less(b, a):
+		return 1
+	}
+	return 0
+}
+
+// mergeSorted merges two sorted listings, keeping a's items first on ties.
+func mergeSorted(a, b []FileItem, compare func(a, b FileItem) int) []FileItem {
+	if len(a) == 0 {
+		return b
+	}
+	merged := make([]FileItem, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if compare(b[j], a[i]) < 0 {
+			merged = append(merged, b[j])
+			j++
+		} else {
+			merged = append(merged, a[i])
+			i++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
+
+func (n *Navigator) loading() bool {
+	return n.load != nil
+}
+
+// refreshWhenLoaded asks for the directory being loaded to be read again
+// once the current load is complete. Restarting at once would throw away
+// what was read so far, and a directory that keeps changing would never
+// finish loading.
+func (n *Navigator) refreshWhenLoaded() {
+	n.load.stale = true
+}
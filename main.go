@@ -53,10 +53,13 @@ type FileItem struct {
 	Size     int64
 	ModTime  time.Time
 	Mode     os.FileMode
+	UID      int // -1 when the platform has no owner information
+	GID      int
+	IsLink   bool   // a symlink; the other fields describe what it points to
+	Target   string // the link's target as written
+	Broken   bool   // a symlink to nothing; the fields describe the link itself
 }
 
-// Removed PreviewContent - no preview functionality
-
 type Navigator struct {
 	currentPath   string
 	items         []FileItem
@@ -65,16 +68,30 @@ type Navigator struct {
 	searchMode    bool
 	searchQuery   string
 	scrollOffset  int
+	marked        map[string]bool
+	sort          SortOptions
+	sortMemory    *SortMemory // nil keeps sort fixed across directories
+	filter        FilterOptions
+	hiddenCount   int // entries of currentPath left out by filter
+	back          []string // directories to return to, most recent last
+	forward       []string // directories left by going back
+	positions     map[string]position
+	recent        *RecentDirs // nil when visits are not recorded
+	// Runs a function on the UI goroutine; nil loads directories synchronously
+	post          func(func())
+	load          *dirLoad // directory being read in the background
+	listed        string   // directory whose listing in items is complete
+	wantSelected  string   // path to select once the loading listing has it
+	wantScroll    int      // scroll offset to restore once the listing is complete
 }
 
-// Removed Previewer - no preview functionality
-
 type StatusBar struct {
 	message      string
 	isError      bool
 	messageTime  time.Time
 	hasMessage   bool
 	defaultMsg   string
+	timeout      time.Duration
 	// Removed animation fields for minimal design
 }
 
@@ -86,6 +103,13 @@ const (
 	PopupCreateFolder
 	PopupRename
 	PopupDelete
+	PopupPurge
+	PopupTrashPurge
+	PopupEmptyTrash
+	PopupSelectGlob
+	PopupBookmark
+	PopupGoTo
+	PopupShell
 )
 
 type PopupState struct {
@@ -96,6 +120,9 @@ type PopupState struct {
 	inputBuffer string
 	prefilledText string
 	targetItem  *FileItem
+	targetItems []FileItem
+	targetTrash *TrashEntry
+	completions []string // candidates from the last Tab in the go-to prompt
 }
 
 type App struct {
@@ -108,16 +135,64 @@ type App struct {
 	height    int
 	helpMode  bool
 	popup     PopupState
+	trash     *Trash
+	trashMode bool
+	trashView TrashView
+	clipboard *Clipboard
+	transfer  *Transfer
+	finder    *Finder // non-nil while finding across the tree
+	grep      *Grep   // non-nil while searching file contents
+	picker    *Picker // non-nil while a picker popup is open
+	pager     *Pager  // non-nil while text such as the command log is shown
+	commandLog *CommandLog
+	bookmarks *Bookmarks
+	watcher   *DirWatcher // nil when auto-refresh is off or unavailable
+	// Set by m or ' until the mark letter is typed
+	markPrompt MarkPrompt
+	journal   *Journal
+	previewer Previewer
+	config    *Config
+	keymap    *Keymap
+	// Keys typed so far of an unfinished multi-key binding such as "g g"
+	pendingKeys []string
+	helpScroll  int
+	details     bool // show the detail columns in the file list
+	// A save of the recent directories is waiting on its timer
+	recentSaveScheduled bool
+	recentSaveFailed    bool // reported once; later failures stay quiet
 }
 
 func NewFileItem(path string) (FileItem, error) {
-	info, err := os.Stat(path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return FileItem{}, err
+	}
+	return fileItemFromLstat(path, info)
+}
+
+// fileItemFromLstat builds an item from the link-level info of path,
+// resolving symlinks. A link whose target is missing is kept as broken.
+func fileItemFromLstat(path string, info os.FileInfo) (FileItem, error) {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fileItemFromInfo(path, info), nil
+	}
+	target, err := os.Readlink(path)
 	if err != nil {
 		return FileItem{}, err
 	}
+	item := fileItemFromInfo(path, info)
+	item.Broken = true
+	if resolved, err := os.Stat(path); err == nil {
+		item = fileItemFromInfo(path, resolved)
+	}
+	item.IsLink, item.Target = true, target
+	return item, nil
+}
 
+func fileItemFromInfo(path string, info os.FileInfo) FileItem {
 	name := filepath.Base(path)
 	isHidden := strings.HasPrefix(name, ".")
+	uid, gid := fileOwner(info)
 
 	return FileItem{
 		Name:     name,
@@ -127,48 +202,142 @@ func NewFileItem(path string) (FileItem, error) {
 		Size:     info.Size(),
 		ModTime:  info.ModTime(),
 		Mode:     info.Mode(),
-	}, nil
+		UID:      uid,
+		GID:      gid,
+	}
 }
 
 func NewNavigator(startPath string) *Navigator {
-	nav := &Navigator{
+	return NewNavigatorWithConfig(startPath, defaultConfig)
+}
+
+func NewNavigatorWithConfig(startPath string, config *Config) *Navigator {
+	nav := newNavigator(startPath, config)
+	nav.loadDirectory()
+	return nav
+}
+
+// newNavigator sets up a navigator without listing startPath yet.
+func newNavigator(startPath string, config *Config) *Navigator {
+	return &Navigator{
 		currentPath: startPath,
 		selectedIdx: 0,
 		searchMode:  false,
 		searchQuery: "",
+		sort:        config.Sort,
+		filter:      config.Filter,
 	}
-	nav.loadDirectory()
-	return nav
 }
 
+// loadDirectory lists the current directory. With post set the entries
+// are read in the background and merged in as they arrive; either way an
+// unreadable directory is reported right away.
 func (n *Navigator) loadDirectory() error {
-	entries, err := os.ReadDir(n.currentPath)
+	dir, err := openDirectory(n.currentPath)
 	if err != nil {
 		return err
 	}
-
-	n.items = make([]FileItem, 0, len(entries))
-	for _, entry := range entries {
-		fullPath := filepath.Join(n.currentPath, entry.Name())
-		item, err := NewFileItem(fullPath)
-		if err != nil {
-			continue
-		}
-		n.items = append(n.items, item)
+	n.cancelLoading()
+	if n.sortMemory != nil {
+		n.sort = n.sortMemory.get(n.currentPath)
+	}
+	filter := newItemFilter(n.currentPath, n.filter)
+	if n.post != nil {
+		n.startLoading(dir, filter)
+		return nil
 	}
 
-	sort.Slice(n.items, func(i, j int) bool {
-		if n.items[i].IsDir != n.items[j].IsDir {
-			return n.items[i].IsDir
-		}
-		return strings.ToLower(n.items[i].Name) < strings.ToLower(n.items[j].Name)
+	defer dir.Close()
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return err
+	}
+	n.items, n.hiddenCount = readItems(n.currentPath, entries, filter)
+	n.listed = n.currentPath
+	sort.SliceStable(n.items, func(i, j int) bool {
+		return n.less(n.items[i], n.items[j])
 	})
 
+	n.pruneMarks()
 	n.updateFilteredItems()
 	n.clampSelection()
 	return nil
 }
 
+// changeDirectory lists dir in place of the current directory, staying
+// where it was if dir cannot be read. The directory left is pushed on the
+// back stack.
+func (n *Navigator) changeDirectory(dir string) error {
+	previous := n.currentPath
+	if err := n.moveTo(dir); err != nil {
+		return err
+	}
+	if previous != dir {
+		n.back = pushHistory(n.back, previous)
+		n.forward = nil
+	}
+	return nil
+}
+
+// moveTo switches directories without touching the back/forward stacks,
+// remembering the selection in the directory left and restoring the one
+// saved for dir.
+func (n *Navigator) moveTo(dir string) error {
+	previous := n.currentPath
+	n.rememberPosition()
+	n.currentPath = dir
+	if err := n.loadDirectory(); err != nil {
+		n.currentPath = previous
+		n.loadDirectory()
+		n.restorePosition()
+		return err
+	}
+	n.searchMode = false
+	n.setSearch("")
+	n.clearMarks()
+	n.restorePosition()
+	if n.recent != nil {
+		n.recent.visit(dir, time.Now())
+	}
+	return nil
+}
+
+// revealPath opens the directory holding path and selects it.
+func (n *Navigator) revealPath(path string) error {
+	if err := n.changeDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+	n.selectPath(path)
+	return nil
+}
+
+// reload re-reads the current directory, keeping the selected item
+// selected when it is still listed.
+func (n *Navigator) reload() error {
+	var selectedPath string
+	if selected := n.getSelectedItem(); selected != nil {
+		selectedPath = selected.Path
+	}
+	err := n.loadDirectory()
+	n.selectPath(selectedPath)
+	return err
+}
+
+// selectPath selects the item at path. While the directory is still
+// loading, an item not listed yet is selected when it arrives.
+func (n *Navigator) selectPath(path string) {
+	n.wantSelected, n.wantScroll = "", 0
+	for i, item := range n.filteredItems {
+		if item.Path == path {
+			n.selectedIdx = i
+			return
+		}
+	}
+	if n.loading() && path != "" {
+		n.wantSelected = path
+	}
+}
+
 func (n *Navigator) updateFilteredItems() {
 	if n.searchQuery == "" {
 		n.filteredItems = n.items
@@ -201,6 +370,7 @@ func (n *Navigator) clampSelection() {
 }
 
 func (n *Navigator) moveSelection(delta int) {
+	n.wantSelected, n.wantScroll = "", 0
 	n.selectedIdx += delta
 	n.clampSelection()
 }
@@ -218,11 +388,7 @@ func (n *Navigator) enterDirectory() error {
 		return nil
 	}
 
-	newPath := selected.Path
-	n.currentPath = newPath
-	n.selectedIdx = 0
-	n.scrollOffset = 0
-	return n.loadDirectory()
+	return n.changeDirectory(selected.Path)
 }
 
 func (n *Navigator) goUp() error {
@@ -231,23 +397,11 @@ func (n *Navigator) goUp() error {
 		return nil
 	}
 
-	oldName := filepath.Base(n.currentPath)
-	n.currentPath = parent
-	n.selectedIdx = 0
-	n.scrollOffset = 0
-
-	err := n.loadDirectory()
-	if err != nil {
+	oldPath := n.currentPath
+	if err := n.changeDirectory(parent); err != nil {
 		return err
 	}
-
-	for i, item := range n.filteredItems {
-		if item.Name == oldName {
-			n.selectedIdx = i
-			break
-		}
-	}
-
+	n.selectPath(oldPath)
 	return nil
 }
 
@@ -261,12 +415,7 @@ func (n *Navigator) setSearch(query string) {
 // Simple text file detection for file opening
 func (app *App) isTextFile(item FileItem) bool {
 	ext := strings.ToLower(filepath.Ext(item.Name))
-	textExts := []string{
-		".txt", ".md", ".py", ".js", ".json", ".yaml", ".yml", ".html", ".css",
-		".sh", ".conf", ".cfg", ".ini", ".log", ".sql", ".xml", ".csv", ".toml",
-		".rs", ".go", ".c", ".cpp", ".h", ".hpp", ".java", ".php", ".rb", ".pl",
-		".ts", ".jsx", ".tsx", ".vue", ".svelte", ".scss", ".sass", ".less",
-	}
+	textExts := app.cfg().TextExtensions
 
 	for _, textExt := range textExts {
 		if ext == textExt {
@@ -290,7 +439,12 @@ func (app *App) detectTextContent(item FileItem) bool {
 		return false
 	}
 
-	buffer = buffer[:n]
+	return looksLikeText(buffer[:n])
+}
+
+// looksLikeText reports whether buffer, the start of a file, is mostly
+// printable UTF-8.
+func looksLikeText(buffer []byte) bool {
 	if !utf8.Valid(buffer) {
 		return false
 	}
@@ -307,18 +461,14 @@ func (app *App) detectTextContent(item FileItem) bool {
 }
 
 func NewStatusBar(autocd bool) *StatusBar {
-	var defaultMsg string
-	if autocd {
-		defaultMsg = "Ready - AutoCD | F1=Help q:inherit directory /:search Ctrl+n:new file Ctrl+o:open"
-	} else {
-		defaultMsg = "Ready | F1=Help q:quit /:search Ctrl+n:new file Ctrl+o:open"
-	}
+	defaultMsg := defaultKeymap.statusHint(autocd)
 	return &StatusBar{
 		message:     defaultMsg,
 		isError:     false,
 		messageTime: time.Time{},
 		hasMessage:  false,
 		defaultMsg:  defaultMsg,
+		timeout:     2 * time.Second,
 	}
 }
 
@@ -344,7 +494,7 @@ func (s *StatusBar) showError(msg string) {
 func (s *StatusBar) updateMessage() {
 	if s.hasMessage {
 		elapsed := time.Since(s.messageTime)
-		if elapsed >= 2*time.Second {
+		if elapsed >= s.timeout {
 			s.message = s.defaultMsg
 			s.hasMessage = false
 			s.isError = false
@@ -353,7 +503,7 @@ func (s *StatusBar) updateMessage() {
 	// Removed animation for minimal design
 }
 
-func NewApp(autocd bool) (*App, error) {
+func NewApp(autocd bool, config *Config) (*App, error) {
 	screen, err := tcell.NewScreen()
 	if err != nil {
 		return nil, err
@@ -374,15 +524,52 @@ func NewApp(autocd bool) (*App, error) {
 		wd = "."
 	}
 
+	keymap, err := NewKeymap(config.Keys, commandActions(config.Commands)...)
+	if err != nil {
+		return nil, err
+	}
+
+	statusBar := NewStatusBar(autocd)
+	statusBar.timeout = config.StatusTimeout
+	statusBar.defaultMsg = keymap.statusHint(autocd)
+	statusBar.message = statusBar.defaultMsg
+
+	navigator := newNavigator(wd, config)
+	navigator.useSortMemory(LoadSortMemory(filepath.Join(defaultStateDir(), "sort.json"), config.SortRemember, config.Sort))
+	navigator.recent = LoadRecentDirs(filepath.Join(defaultStateDir(), "recent.json"))
+	navigator.recent.visit(wd, time.Now())
+
+	trash := NewTrash(defaultTrashDir())
 	app := &App{
 		screen:    screen,
-		navigator: NewNavigator(wd),
-		statusBar: NewStatusBar(autocd),
+		navigator: navigator,
+		statusBar: statusBar,
+		trash:     trash,
+		journal:   LoadJournal(filepath.Join(defaultStateDir(), "journal.json"), trash),
+		bookmarks: LoadBookmarks(filepath.Join(defaultStateDir(), "bookmarks.json")),
 		running:   true,
 		autocd:    autocd,
 		width:     width,
 		height:    height,
+		config:    config,
+		keymap:    keymap,
+		details:   config.Details,
+		commandLog: &CommandLog{},
+	}
+	app.commandLog.changed = app.wake
+	navigator.recent.changed = app.scheduleRecentSave
+	if config.Watch {
+		// Without a watcher the listing still refreshes after our own operations
+		if watcher, err := NewDirWatcher(func(dir string) {
+			ev := &watchEvent{dir: dir}
+			ev.SetEventNow()
+			screen.PostEvent(ev)
+		}); err == nil {
+			app.watcher = watcher
+		}
 	}
+	navigator.post = app.postUI
+	navigator.loadDirectory()
 
 	return app, nil
 }
@@ -400,32 +587,47 @@ func (app *App) showPopup(popupType PopupType, title, prompt string, prefilled s
 	}
 }
 
+// isConfirmation reports whether the popup is a y/n prompt rather than text input
+func (p PopupState) isConfirmation() bool {
+	switch p.popupType {
+	case PopupDelete, PopupPurge, PopupTrashPurge, PopupEmptyTrash:
+		return true
+	}
+	return false
+}
+
 func (app *App) hidePopup() {
 	app.popup = PopupState{active: false}
 }
 
 func (app *App) addToPopupInput(ch rune) {
 	app.popup.inputBuffer += string(ch)
+	app.popup.completions = nil
 }
 
 func (app *App) backspacePopupInput() {
 	if len(app.popup.inputBuffer) > 0 {
 		app.popup.inputBuffer = app.popup.inputBuffer[:len(app.popup.inputBuffer)-1]
 	}
+	app.popup.completions = nil
 }
 
 func (app *App) getPopupInput() string {
 	return app.popup.inputBuffer
 }
 
-// Removed updatePreview - no preview functionality
-
 func (app *App) drawText(x, y int, text string, style tcell.Style) {
-	for i, r := range text {
-		if x+i >= app.width {
+	app.drawTextWithin(x, y, app.width, text, style)
+}
+
+// drawTextWithin draws text one cell per rune, clipped before column maxX.
+func (app *App) drawTextWithin(x, y, maxX int, text string, style tcell.Style) {
+	for _, r := range text {
+		if x >= maxX {
 			break
 		}
-		app.screen.SetContent(x+i, y, r, nil, style)
+		app.screen.SetContent(x, y, r, nil, style)
+		x++
 	}
 }
 
@@ -436,14 +638,29 @@ func (app *App) render() {
 
 	if app.helpMode {
 		app.drawHelp()
+	} else if app.pager != nil {
+		app.drawPager()
+		app.drawStatusBar()
+	} else if app.trashMode {
+		app.drawTrash()
+		app.drawStatusBar()
+		app.drawPopup()
+	} else if app.finder != nil {
+		app.drawFinder()
+		app.drawStatusBar()
+	} else if app.grep != nil {
+		app.drawGrep()
+		app.drawStatusBar()
 	} else {
 		// Simple minimal rendering - full width file list
 		app.drawBreadcrumbs()
 		app.drawFileList()
+		app.drawPreview()
 		app.drawStatusBar()
 		
 		// Draw popup on top if active
 		app.drawPopup()
+		app.drawPicker()
 	}
 
 	app.screen.Show()
@@ -451,10 +668,25 @@ func (app *App) render() {
 
 func (app *App) drawBreadcrumbs() {
 	// Simple breadcrumbs without decorations
-	style := tcell.StyleDefault.Background(tcell.ColorDarkGray).Foreground(tcell.ColorWhite)
+	theme := app.theme()
+	style := theme.Bar
+	sortLabel := " [" + app.navigator.sort.label() + "] "
+	var marks string
+	if count := len(app.navigator.marked); count > 0 {
+		marks = fmt.Sprintf(" [%d marked]", count)
+	}
+	var hidden string
+	if count := app.navigator.hiddenCount; count > 0 {
+		hidden = fmt.Sprintf(" [%d hidden]", count)
+	}
+	var loading string
+	if app.navigator.loading() {
+		loading = fmt.Sprintf(" [loading %d]", len(app.navigator.items))
+	}
+
 	breadcrumb := app.navigator.currentPath
-	if len(breadcrumb) > app.width-4 {
-		breadcrumb = "..." + breadcrumb[len(breadcrumb)-(app.width-7):]
+	if room := app.width - 4 - len(sortLabel) - len(marks) - len(hidden) - len(loading); len(breadcrumb) > room && room > 3 {
+		breadcrumb = "..." + breadcrumb[len(breadcrumb)-(room-3):]
 	}
 	
 	// Simple background fill
@@ -463,11 +695,24 @@ func (app *App) drawBreadcrumbs() {
 	}
 	
 	app.drawText(1, 0, breadcrumb, style)
+
+	app.drawText(app.width-len(sortLabel), 0, sortLabel, style)
+	if marks != "" {
+		app.drawText(app.width-len(sortLabel)-len(marks), 0, marks, overlay(style, theme.Marked))
+	}
+	if hidden != "" {
+		app.drawText(app.width-len(sortLabel)-len(marks)-len(hidden), 0, hidden, overlay(style, theme.Dim))
+	}
+	if loading != "" {
+		app.drawText(app.width-len(sortLabel)-len(marks)-len(hidden)-len(loading), 0, loading, overlay(style, theme.Dim))
+	}
 }
 
 func (app *App) drawFileList() {
 	startY := 1
 	maxItems := app.height - 2
+	width := app.listWidth()
+	theme := app.theme()
 
 	if app.navigator.selectedIdx >= app.navigator.scrollOffset+maxItems {
 		app.navigator.scrollOffset = app.navigator.selectedIdx - maxItems + 1
@@ -476,6 +721,14 @@ func (app *App) drawFileList() {
 		app.navigator.scrollOffset = app.navigator.selectedIdx
 	}
 
+	visible := app.navigator.filteredItems[app.navigator.scrollOffset:min(len(app.navigator.filteredItems), app.navigator.scrollOffset+max(maxItems, 0))]
+	columns, columnsWidth := app.detailColumns(visible, width)
+	nameWidth := width - columnsWidth
+	now := time.Now()
+	if len(app.navigator.filteredItems) == 0 && app.navigator.loading() {
+		app.drawText(2, startY, "Loading...", theme.Dim)
+	}
+
 	for i := 0; i < maxItems && i+app.navigator.scrollOffset < len(app.navigator.filteredItems); i++ {
 		itemIdx := i + app.navigator.scrollOffset
 		item := app.navigator.filteredItems[itemIdx]
@@ -483,20 +736,22 @@ func (app *App) drawFileList() {
 
 		var style tcell.Style
 		var prefix string
+		marked := app.navigator.isMarked(item)
 
 		if itemIdx == app.navigator.selectedIdx {
 			// Selected item - simple highlight
-			style = tcell.StyleDefault.Background(tcell.ColorDarkBlue).Foreground(tcell.ColorWhite)
+			style = theme.Selected
 			prefix = "> "
+			if marked {
+				style = overlay(style, theme.Marked)
+				prefix = ">*"
+			}
+		} else if marked {
+			style = theme.Marked
+			prefix = " *"
 		} else {
 			// Unselected item - minimal styling
-			if item.IsDir {
-				style = tcell.StyleDefault.Foreground(tcell.ColorBlue)
-			} else if item.IsHidden {
-				style = tcell.StyleDefault.Foreground(tcell.ColorGray)
-			} else {
-				style = tcell.StyleDefault.Foreground(tcell.ColorWhite)
-			}
+			style = theme.itemStyle(item)
 			prefix = "  "
 		}
 
@@ -505,59 +760,53 @@ func (app *App) drawFileList() {
 		if item.IsDir {
 			displayName += "/"
 		}
+		if item.IsLink {
+			displayName += " -> " + item.Target
+		}
 
 		text := prefix + displayName
-		if len(text) > app.width-1 {
-			text = text[:app.width-4] + "..."
+		if len(text) > nameWidth-1 {
+			text = text[:nameWidth-4] + "..."
 		}
 
 		// Fill background for selected items
 		if itemIdx == app.navigator.selectedIdx {
-			for j := 0; j < app.width; j++ {
+			for j := 0; j < width; j++ {
 				app.screen.SetContent(j, y, ' ', nil, style)
 			}
 		}
 		
 		// Draw the text
-		app.drawText(0, y, text, style)
+		app.drawTextWithin(0, y, nameWidth, text, style)
+
+		columnStyle := style
+		if itemIdx != app.navigator.selectedIdx && !marked {
+			columnStyle = theme.Dim
+		}
+		x := nameWidth
+		for _, column := range columns {
+			value := app.columnText(column.name, item, now)
+			if n := utf8.RuneCountInString(value); n > column.width {
+				value = string([]rune(value)[:column.width-1]) + "…"
+			}
+			x += 2
+			app.drawTextWithin(x+column.width-utf8.RuneCountInString(value), y, x+column.width, value, columnStyle)
+			x += column.width
+		}
 	}
 }
 
-// Removed drawPreview function - no preview functionality
-
 func (app *App) drawHelp() {
-	// Help content with clean, minimal styling
-	helpText := []string{
-		"PowPow - Keyboard Shortcuts",
-		"",
-		"Navigation:",
-		"  hjkl, arrow keys    Navigate file list",
-		"  Enter               Enter directory / Open file",
-		"  Backspace           Go to parent directory",
-		"  Home / End          Jump to first / last item",
-		"  Page Up / Down      Jump by page",
-		"",
-		"File Operations:",
-		"  Ctrl+N              Create new file",
-		"  Ctrl+F              Create new folder",
-		"  Ctrl+O              Open file in editor",
-		"  Ctrl+R              Rename file/folder",
-		"  Ctrl+D              Delete file/folder",
-		"",
-		"Search & General:",
-		"  /                   Start fuzzy search",
-		"  ESC                 Exit search mode",
-		"  q                   Quit application",
-		"  F1                  Show this help",
-		"",
-		"Press ESC to return to file explorer",
-	}
+	// Help content is generated from the live keymap so it never drifts
+	helpText := app.keys().helpLines()
+	footer := helpText[len(helpText)-1]
 
 	// Use a slightly different background for help mode
-	helpStyle := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorWhite)
-	headerStyle := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorYellow)
-	sectionStyle := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorBlue)
-	footerStyle := tcell.StyleDefault.Background(tcell.ColorBlack).Foreground(tcell.ColorGreen)
+	theme := app.theme()
+	helpStyle := theme.Help
+	headerStyle := theme.HelpTitle
+	sectionStyle := theme.HelpSection
+	footerStyle := theme.HelpFooter
 
 	// Clear the screen with help background
 	for y := 0; y < app.height; y++ {
@@ -566,15 +815,22 @@ func (app *App) drawHelp() {
 		}
 	}
 
-	// Center the help content
+	// Center the help content, scrolling when it is taller than the screen
 	startY := (app.height - len(helpText)) / 2
 	if startY < 0 {
 		startY = 0
+		maxScroll := len(helpText) - app.height
+		app.helpScroll = max(0, min(app.helpScroll, maxScroll))
+	} else {
+		app.helpScroll = 0
 	}
 
 	// Draw help text
 	for i, line := range helpText {
-		y := startY + i
+		y := startY + i - app.helpScroll
+		if y < 0 {
+			continue
+		}
 		if y >= app.height {
 			break
 		}
@@ -592,7 +848,7 @@ func (app *App) drawHelp() {
 			style = headerStyle
 		case strings.HasSuffix(line, ":") && !strings.HasPrefix(line, " "): // Section headers
 			style = sectionStyle
-		case strings.Contains(line, "Press ESC"): // Footer instruction
+		case line == footer: // Footer instruction
 			style = footerStyle
 		default:
 			style = helpStyle
@@ -618,7 +874,7 @@ func (app *App) drawPopup() {
 			"",
 			app.popup.prompt + app.popup.inputBuffer + "█",
 			"",
-			"ESC: Cancel  Enter: OK",
+			fmt.Sprintf("%s: Cancel  %s: OK", app.keys().hint("popup.cancel"), app.keys().hint("popup.confirm")),
 		}
 	case PopupCreateFolder:
 		lines = []string{
@@ -626,7 +882,7 @@ func (app *App) drawPopup() {
 			"",
 			app.popup.prompt + app.popup.inputBuffer + "█",
 			"",
-			"ESC: Cancel  Enter: OK",
+			fmt.Sprintf("%s: Cancel  %s: OK", app.keys().hint("popup.cancel"), app.keys().hint("popup.confirm")),
 		}
 	case PopupRename:
 		lines = []string{
@@ -634,19 +890,77 @@ func (app *App) drawPopup() {
 			"",
 			app.popup.prompt + app.popup.inputBuffer + "█",
 			"",
-			"ESC: Cancel  Enter: OK",
+			fmt.Sprintf("%s: Cancel  %s: OK", app.keys().hint("popup.cancel"), app.keys().hint("popup.confirm")),
+		}
+	case PopupGoTo:
+		lines = []string{
+			app.popup.title,
+			"",
+			app.popup.prompt + app.popup.inputBuffer + "█",
+		}
+		if completions := app.popup.completions; len(completions) > 0 {
+			shown := strings.Join(completions[:min(len(completions), maxCompletionsShown)], "/  ") + "/"
+			if len(completions) > maxCompletionsShown {
+				shown += fmt.Sprintf("  (+%d more)", len(completions)-maxCompletionsShown)
+			}
+			lines = append(lines, shown)
+		}
+		lines = append(lines,
+			"",
+			fmt.Sprintf("%s: Complete  %s: Cancel  %s: Go", app.keys().hint("popup.complete"), app.keys().hint("popup.cancel"), app.keys().hint("popup.confirm")),
+		)
+	case PopupSelectGlob, PopupBookmark, PopupShell:
+		lines = []string{
+			app.popup.title,
+			"",
+			app.popup.prompt + app.popup.inputBuffer + "█",
+			"",
+			fmt.Sprintf("%s: Cancel  %s: OK", app.keys().hint("popup.cancel"), app.keys().hint("popup.confirm")),
 		}
 	case PopupDelete:
+		lines = []string{
+			"Delete Confirmation",
+			"",
+			"Move " + describeItems(app.popup.targetItems) + " to trash?",
+		}
+		lines = append(lines, itemListLines(app.popup.targetItems, 8)...)
+		lines = append(lines,
+			"",
+			fmt.Sprintf("%s: Yes  %s: No  %s: Cancel", app.keys().hint("popup.yes"), app.keys().hint("popup.no"), app.keys().hint("popup.cancel")),
+		)
+	case PopupPurge:
+		lines = []string{
+			"Delete Forever",
+			"",
+			"Permanently delete " + describeItems(app.popup.targetItems) + "?",
+		}
+		lines = append(lines, itemListLines(app.popup.targetItems, 8)...)
+		lines = append(lines,
+			"This cannot be undone.",
+			"",
+			app.purgeHint("Delete forever"),
+		)
+	case PopupTrashPurge:
 		var filename string
-		if app.popup.targetItem != nil {
-			filename = app.popup.targetItem.Name
+		if app.popup.targetTrash != nil {
+			filename = filepath.Base(app.popup.targetTrash.OriginalPath)
 		}
 		lines = []string{
-			"Delete Confirmation",
+			"Delete Forever",
 			"",
-			"Delete '" + filename + "'?",
+			"Permanently delete '" + filename + "' from trash?",
+			"This cannot be undone.",
 			"",
-			"y: Yes  n: No  ESC: Cancel",
+			app.purgeHint("Delete forever"),
+		}
+	case PopupEmptyTrash:
+		lines = []string{
+			"Empty Trash",
+			"",
+			fmt.Sprintf("Permanently delete all %d items in trash?", len(app.trashView.entries)),
+			"This cannot be undone.",
+			"",
+			app.purgeHint("Empty trash"),
 		}
 	}
 
@@ -677,38 +991,9 @@ func (app *App) drawPopup() {
 		startY = 1
 	}
 
-	// Define popup style
-	borderStyle := tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlack)
-	contentStyle := tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlack)
-	titleStyle := tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlue)
-	
-	// Draw popup background
-	for y := startY; y < startY+popupHeight; y++ {
-		for x := startX; x < startX+popupWidth; x++ {
-			app.screen.SetContent(x, y, ' ', nil, contentStyle)
-		}
-	}
-
-	// Draw border
-	// Top border
-	app.screen.SetContent(startX, startY, '┌', nil, borderStyle)
-	for x := startX + 1; x < startX+popupWidth-1; x++ {
-		app.screen.SetContent(x, startY, '─', nil, borderStyle)
-	}
-	app.screen.SetContent(startX+popupWidth-1, startY, '┐', nil, borderStyle)
-
-	// Side borders and content
-	for y := startY + 1; y < startY+popupHeight-1; y++ {
-		app.screen.SetContent(startX, y, '│', nil, borderStyle)
-		app.screen.SetContent(startX+popupWidth-1, y, '│', nil, borderStyle)
-	}
-
-	// Bottom border
-	app.screen.SetContent(startX, startY+popupHeight-1, '└', nil, borderStyle)
-	for x := startX + 1; x < startX+popupWidth-1; x++ {
-		app.screen.SetContent(x, startY+popupHeight-1, '─', nil, borderStyle)
-	}
-	app.screen.SetContent(startX+popupWidth-1, startY+popupHeight-1, '┘', nil, borderStyle)
+	contentStyle := app.theme().Popup
+	titleStyle := app.theme().PopupTitle
+	app.drawPopupFrame(startX, startY, popupWidth, popupHeight)
 
 	// Draw content
 	for i, line := range lines {
@@ -734,19 +1019,76 @@ func (app *App) drawPopup() {
 	}
 }
 
+// drawPopupFrame clears a box and draws its border.
+func (app *App) drawPopupFrame(startX, startY, width, height int) {
+	borderStyle := app.theme().Popup
+
+	// Draw popup background
+	for y := startY; y < startY+height; y++ {
+		for x := startX; x < startX+width; x++ {
+			app.screen.SetContent(x, y, ' ', nil, borderStyle)
+		}
+	}
+
+	// Draw border
+	// Top border
+	app.screen.SetContent(startX, startY, '┌', nil, borderStyle)
+	for x := startX + 1; x < startX+width-1; x++ {
+		app.screen.SetContent(x, startY, '─', nil, borderStyle)
+	}
+	app.screen.SetContent(startX+width-1, startY, '┐', nil, borderStyle)
+
+	// Side borders
+	for y := startY + 1; y < startY+height-1; y++ {
+		app.screen.SetContent(startX, y, '│', nil, borderStyle)
+		app.screen.SetContent(startX+width-1, y, '│', nil, borderStyle)
+	}
+
+	// Bottom border
+	app.screen.SetContent(startX, startY+height-1, '└', nil, borderStyle)
+	for x := startX + 1; x < startX+width-1; x++ {
+		app.screen.SetContent(x, startY+height-1, '─', nil, borderStyle)
+	}
+	app.screen.SetContent(startX+width-1, startY+height-1, '┘', nil, borderStyle)
+}
+
 func (app *App) drawStatusBar() {
 	y := app.height - 1
 	var style tcell.Style
 	var text string
+	theme := app.theme()
 
 	if app.statusBar.isError {
-		style = tcell.StyleDefault.Background(tcell.ColorRed).Foreground(tcell.ColorWhite)
+		style = theme.Error
 		text = app.statusBar.message
+	} else if app.pager != nil {
+		style = theme.Bar
+		keys := app.keys()
+		text = fmt.Sprintf("%s/%s: scroll  %s/%s: top/bottom  %s: close", keys.hint("pager.down"), keys.hint("pager.up"),
+			keys.hint("pager.top"), keys.hint("pager.bottom"), keys.hint("pager.close"))
+		if app.pager.run != nil && app.pager.run.running() {
+			text += "  " + keys.hint("pager.stop") + ": stop"
+		}
+	} else if app.markPrompt != MarkNone {
+		style = theme.Search
+		text = "Set mark: type a letter"
+		if app.markPrompt == MarkJump {
+			text = "Go to mark: type a letter"
+		}
+	} else if app.finder != nil {
+		style = theme.Search
+		text = "Find: " + app.finder.query
+	} else if app.grep != nil {
+		style = theme.Search
+		text = app.grep.status()
 	} else if app.navigator.searchMode {
-		style = tcell.StyleDefault.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+		style = theme.Search
 		text = "Search: " + app.navigator.searchQuery
+	} else if app.transfer != nil {
+		style = theme.Transfer
+		text = app.transfer.status() + " - " + app.keys().hint("app.cancel") + " to cancel"
 	} else {
-		style = tcell.StyleDefault.Background(tcell.ColorDarkGray).Foreground(tcell.ColorWhite)
+		style = theme.Bar
 		text = app.statusBar.message
 	}
 
@@ -759,228 +1101,204 @@ func (app *App) drawStatusBar() {
 		text = text[:app.width-4] + "..."
 	}
 	app.drawText(0, y, text, style)
+
+	// Show an unfinished key sequence at the right, like vim's showcmd
+	if len(app.pendingKeys) > 0 {
+		pending := formatKeys(app.pendingKeys) + "-"
+		app.drawText(app.width-len(pending)-1, y, pending, style)
+	}
 }
 
-// Removed formatSize function - not needed in minimal design
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
 
 // Removed drawBackground function - minimal design
 
 // Removed getFileIcon function - no icons in minimal design
 
 func (app *App) handleKey(ev *tcell.EventKey) {
-	if app.helpMode {
-		app.handleHelpKey(ev)
+	if app.markPrompt != MarkNone {
+		var letter rune
+		if ev.Key() == tcell.KeyRune {
+			letter = ev.Rune()
+		}
+		app.handleMarkLetter(letter)
 		return
 	}
 
-	if app.popup.active {
-		app.handlePopupKey(ev)
-		return
-	}
+	ctx := app.keyContext()
 
-	if app.navigator.searchMode {
-		app.handleSearchKey(ev)
-		return
-	}
-
-	switch ev.Key() {
-	case tcell.KeyRune:
-		switch ev.Rune() {
-		case 'q':
-			if app.autocd {
-				app.exitWithDirectoryInheritance(app.navigator.currentPath)
-			} else {
-				app.running = false
-			}
-		case '/':
-			app.navigator.searchMode = true
-			app.navigator.searchQuery = ""
-			app.navigator.setSearch("")
-		case 'h':
-			err := app.navigator.goUp()
-			if err != nil {
-				app.statusBar.showError("Cannot access parent directory: " + err.Error())
-			}
-		case 'j':
-			app.navigator.moveSelection(1)
-		case 'k':
-			app.navigator.moveSelection(-1)
-		case 'l':
-			selected := app.navigator.getSelectedItem()
-			if selected != nil && selected.IsDir {
-				err := app.navigator.enterDirectory()
-				if err != nil {
-					app.statusBar.showError("Cannot read directory: " + err.Error())
-				}
-			} else if selected != nil {
-				// Open file with editor
-				app.openFile()
-			}
+	// Plain characters are text while typing a search or popup input
+	if ev.Key() == tcell.KeyRune && ev.Modifiers()&tcell.ModAlt == 0 {
+		switch {
+		case ctx == ContextSearch:
+			app.navigator.searchQuery += string(ev.Rune())
+			app.navigator.setSearch(app.navigator.searchQuery)
+			return
+		case ctx == ContextFind:
+			app.finder.setQuery(app.finder.query + string(ev.Rune()))
+			return
+		case ctx == ContextGrep:
+			app.setGrepQuery(app.grep.query + string(ev.Rune()))
+			return
+		case ctx == ContextPicker:
+			app.picker.setQuery(app.picker.query + string(ev.Rune()))
+			return
+		case ctx == ContextPopup && !app.popup.isConfirmation():
+			app.addToPopupInput(ev.Rune())
+			return
 		}
+	}
 
-	case tcell.KeyUp:
-		app.navigator.moveSelection(-1)
-
-	case tcell.KeyDown:
-		app.navigator.moveSelection(1)
+	if key := keyName(ev); key != "" {
+		app.dispatchKey(ctx, key)
+	}
+}
 
-	case tcell.KeyEnter:
-		selected := app.navigator.getSelectedItem()
-		if selected != nil && selected.IsDir {
-			err := app.navigator.enterDirectory()
-			if err != nil {
-				app.statusBar.showError("Cannot read directory: " + err.Error())
-			}
-		} else if selected != nil {
-			// Open file with editor
-			app.openFile()
-		}
+func (app *App) quit() {
+	if app.autocd {
+		app.exitWithDirectoryInheritance(app.navigator.currentPath)
+	} else {
+		app.running = false
+	}
+}
 
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		err := app.navigator.goUp()
+func (app *App) openSelected() {
+	selected := app.navigator.getSelectedItem()
+	if selected != nil && selected.Broken {
+		app.statusBar.showError(brokenLinkMessage(*selected))
+	} else if selected != nil && selected.IsDir {
+		err := app.navigator.enterDirectory()
 		if err != nil {
-			app.statusBar.showError("Cannot access parent directory: " + err.Error())
+			app.statusBar.showError("Cannot read directory: " + err.Error())
 		}
+	} else if selected != nil {
+		// Open file with editor
+		app.openFile()
+	}
+}
 
-	case tcell.KeyHome:
-		app.navigator.selectedIdx = 0
-
-	case tcell.KeyEnd:
-		app.navigator.selectedIdx = len(app.navigator.filteredItems) - 1
-		app.navigator.clampSelection()
-
-	case tcell.KeyPgUp:
-		app.navigator.moveSelection(-10)
-
-	case tcell.KeyPgDn:
-		app.navigator.moveSelection(10)
+func (app *App) goUp() {
+	err := app.navigator.goUp()
+	if err != nil {
+		app.statusBar.showError("Cannot access parent directory: " + err.Error())
+	}
+}
 
-	case tcell.KeyCtrlN:
-		app.showPopup(PopupCreateFile, "Create new file", "Name: ", "", nil)
+func (app *App) startRename() {
+	selected := app.navigator.getSelectedItem()
+	if selected != nil {
+		app.showPopup(PopupRename, "Rename item", "New name: ", selected.Name, selected)
+	}
+}
 
-	case tcell.KeyCtrlF:
-		app.showPopup(PopupCreateFolder, "Create new folder", "Name: ", "", nil)
+// confirmTargets asks before deleting or purging the marked/selected items.
+func (app *App) confirmTargets(popupType PopupType, title string) {
+	if targets := app.navigator.targetItems(); len(targets) > 0 {
+		app.showPopup(popupType, title, "", "", nil)
+		app.popup.targetItems = targets
+	}
+}
 
-	case tcell.KeyCtrlO:
-		app.openFile()
+func (app *App) cancelOrClear() {
+	if app.transfer != nil {
+		app.cancelTransfer()
+	} else {
+		app.navigator.clearMarks()
+	}
+}
 
-	case tcell.KeyCtrlR:
-		selected := app.navigator.getSelectedItem()
-		if selected != nil {
-			app.showPopup(PopupRename, "Rename item", "New name: ", selected.Name, selected)
-		}
+func (app *App) startSearch() {
+	app.navigator.searchMode = true
+	app.navigator.searchQuery = ""
+	app.navigator.setSearch("")
+}
 
-	case tcell.KeyCtrlD:
-		selected := app.navigator.getSelectedItem()
-		if selected != nil {
-			app.showPopup(PopupDelete, "Delete Confirmation", "", "", selected)
-		}
+func (app *App) exitSearch() {
+	app.navigator.searchMode = false
+	app.navigator.setSearch("")
+	app.statusBar.message = app.statusBar.defaultMsg
+	app.statusBar.hasMessage = false
+}
 
-	case tcell.KeyCtrlC:
-		if app.autocd {
-			app.exitWithDirectoryInheritance(app.navigator.currentPath)
+func (app *App) selectSearchResult() {
+	selected := app.navigator.getSelectedItem()
+	if selected != nil {
+		if selected.IsDir {
+			app.navigator.enterDirectory()
+			app.navigator.searchMode = false
+			app.navigator.setSearch("")
 		} else {
-			app.running = false
+			app.openFileWithEditor(selected.Path)
 		}
-
-	case tcell.KeyF1:
-		app.helpMode = true
 	}
 }
 
-func (app *App) handleSearchKey(ev *tcell.EventKey) {
-	switch ev.Key() {
-	case tcell.KeyEscape:
-		app.navigator.searchMode = false
-		app.navigator.setSearch("")
-		app.statusBar.message = app.statusBar.defaultMsg
-		app.statusBar.hasMessage = false
-
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if len(app.navigator.searchQuery) > 0 {
-			app.navigator.searchQuery = app.navigator.searchQuery[:len(app.navigator.searchQuery)-1]
-			app.navigator.setSearch(app.navigator.searchQuery)
-		}
-
-	case tcell.KeyUp:
-		app.navigator.selectedIdx--
-		app.navigator.clampSelection()
-
-	case tcell.KeyDown:
-		app.navigator.selectedIdx++
-		app.navigator.clampSelection()
-
-	case tcell.KeyEnter:
-		selected := app.navigator.getSelectedItem()
-		if selected != nil {
-			if selected.IsDir {
-				app.navigator.enterDirectory()
-				app.navigator.searchMode = false
-				app.navigator.setSearch("")
-			} else {
-				app.openFileWithEditor(selected.Path)
-			}
-		}
+// submitPopup accepts a text popup. For confirmations it only answers the
+// trash prompt: permanent deletes require an explicit yes.
+func (app *App) submitPopup() {
+	input := app.getPopupInput()
 
-	case tcell.KeyRune:
-			app.navigator.searchQuery += string(ev.Rune())
-			app.navigator.setSearch(app.navigator.searchQuery)
+	switch app.popup.popupType {
+	case PopupCreateFile:
+		app.hidePopup()
+		app.createFile(input)
+	case PopupCreateFolder:
+		app.hidePopup()
+		app.createFolder(input)
+	case PopupRename:
+		app.hidePopup()
+		app.renameItem(input)
+	case PopupSelectGlob:
+		app.hidePopup()
+		app.markByGlob(input)
+	case PopupBookmark:
+		app.hidePopup()
+		app.addBookmark(input)
+	case PopupGoTo:
+		app.hidePopup()
+		app.goTo(input)
+	case PopupShell:
+		app.hidePopup()
+		app.runShellCommand(input)
+	case PopupDelete:
+		app.answerPopup(true)
 	}
 }
 
-func (app *App) handleHelpKey(ev *tcell.EventKey) {
-	switch ev.Key() {
-	case tcell.KeyEscape:
-		app.helpMode = false
-	case tcell.KeyF1:
-		app.helpMode = false
+func (app *App) answerPopup(yes bool) {
+	if !app.popup.isConfirmation() {
+		return
+	}
+	popup := app.popup
+	app.hidePopup()
+	if !yes {
+		return
+	}
+	switch popup.popupType {
+	case PopupDelete:
+		app.deleteItems(popup.targetItems)
+	case PopupPurge:
+		app.purgeItems(popup.targetItems)
+	case PopupTrashPurge:
+		app.purgeTrashEntry(*popup.targetTrash)
+	case PopupEmptyTrash:
+		app.emptyTrash()
 	}
 }
 
-func (app *App) handlePopupKey(ev *tcell.EventKey) {
-	switch ev.Key() {
-	case tcell.KeyEscape:
-		app.hidePopup()
-
-	case tcell.KeyEnter:
-		input := app.getPopupInput()
-		
-		switch app.popup.popupType {
-		case PopupCreateFile:
-			app.hidePopup()
-			app.createFile(input)
-		case PopupCreateFolder:
-			app.hidePopup()
-			app.createFolder(input)
-		case PopupRename:
-			app.hidePopup()
-			app.renameItem(input)
-		case PopupDelete:
-			app.hidePopup()
-			// For delete confirmation, Enter means yes
-			app.deleteItem()
-		}
-
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if app.popup.popupType != PopupDelete {
-			app.backspacePopupInput()
-		}
-
-	case tcell.KeyRune:
-		switch app.popup.popupType {
-		case PopupDelete:
-			// Handle y/n for delete confirmation
-			if ev.Rune() == 'y' || ev.Rune() == 'Y' {
-				app.hidePopup()
-				app.deleteItem()
-			} else if ev.Rune() == 'n' || ev.Rune() == 'N' {
-				app.hidePopup()
-			}
-		default:
-			// For text input popups
-			app.addToPopupInput(ev.Rune())
-		}
-	}
+func (app *App) purgeHint(yes string) string {
+	return fmt.Sprintf("%s: %s  %s/%s: Cancel", app.keys().hint("popup.yes"), yes, app.keys().hint("popup.no"), app.keys().hint("popup.cancel"))
 }
 
 func (app *App) sanitizeFilename(name string) string {
@@ -1042,6 +1360,7 @@ func (app *App) createFile(name string) {
 		return
 	}
 	file.Close()
+	app.recordOp(OpCreate, []JournalItem{{To: filePath}})
 
 	app.navigator.loadDirectory()
 	finalName := filepath.Base(filePath)
@@ -1051,8 +1370,8 @@ func (app *App) createFile(name string) {
 		app.statusBar.showMessage("Created file: " + finalName)
 	}
 
-	editor := os.Getenv("EDITOR")
-	if editor != "" {
+	// Only in an editor the user chose; the vi fallback is no place to land unasked
+	if app.cfg().EditNewFiles && editorChosen(os.Getenv("VISUAL"), os.Getenv("EDITOR"), app.cfg().Editor) {
 		app.openFileWithEditor(filePath)
 	}
 }
@@ -1072,6 +1391,7 @@ func (app *App) createFolder(name string) {
 		app.statusBar.showError("Cannot create folder: " + err.Error())
 		return
 	}
+	app.recordOp(OpCreate, []JournalItem{{To: folderPath}})
 
 	app.navigator.loadDirectory()
 	finalName := filepath.Base(folderPath)
@@ -1082,32 +1402,74 @@ func (app *App) createFolder(name string) {
 	}
 }
 
+// openFile opens each target with the first opener rule matching it.
+// Text files without a rule go to the editor together; anything else
+// goes to the system's default application.
 func (app *App) openFile() {
-	selected := app.navigator.getSelectedItem()
-	if selected == nil || selected.IsDir {
-		return
+	rules := app.cfg().Openers
+	var paths []string
+	for _, item := range app.navigator.targetItems() {
+		if item.IsDir {
+			continue
+		}
+		if item.Broken {
+			app.statusBar.showError(brokenLinkMessage(item))
+			return
+		}
+		if len(rules) > 0 {
+			if matching := matchingOpeners(rules, item.Name, fileMIME(item.Path)); len(matching) > 0 {
+				app.runOpener(matching[0], item.Path)
+				continue
+			}
+		}
+		// Simple text file detection for opening
+		if !app.isTextFile(item) {
+			app.openWithSystem(item.Path)
+			continue
+		}
+		paths = append(paths, item.Path)
 	}
-
-	// Simple text file detection for opening
-	if !app.isTextFile(*selected) {
-		app.statusBar.showError("Cannot open non-text file")
+	if len(paths) == 0 {
 		return
 	}
 
-	app.openFileWithEditor(selected.Path)
+	app.openFileWithEditor(paths...)
 }
 
-func (app *App) openFileWithEditor(filePath string) {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		app.statusBar.showError("No editor configured. Set with: export EDITOR=nano")
+func (app *App) openFileWithEditor(filePaths ...string) {
+	app.launchEditor(0, filePaths...)
+}
+
+// openFileAtLine opens path with the cursor on line, for editors that take
+// a +line argument; others just open the file.
+func (app *App) openFileAtLine(path string, line int) {
+	app.launchEditor(line, path)
+}
+
+func (app *App) launchEditor(line int, filePaths ...string) {
+	editor, err := pickEditor(os.Getenv("VISUAL"), os.Getenv("EDITOR"), app.cfg().Editor)
+	if err != nil {
+		app.statusBar.showError(err.Error())
+		return
+	}
+	if len(editor) == 0 {
+		app.statusBar.showError("The editor command is empty")
+		return
+	}
+
+	cmd := editorCommand(editor, app.cfg().EditorLines, line, filePaths...)
+	if !app.cfg().ExitAfterEdit {
+		if err := app.runSuspended(cmd[0], cmd[1:]...); err != nil {
+			app.statusBar.showError("Editor failed: " + err.Error())
+		}
+		// The editor may have saved, created or deleted files
+		app.navigator.reload()
 		return
 	}
 
 	app.screen.Fini()
 	
-	cmd := []string{editor, filePath}
-	if err := execCommand(cmd[0], cmd[1:]...); err != nil {
+	if err := execCommand("", cmd[0], cmd[1:]...); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to launch editor: %v\n", err)
 		os.Exit(1)
 	}
@@ -1120,8 +1482,41 @@ func (app *App) openFileWithEditor(filePath string) {
 	}
 }
 
+// runSuspended hands the terminal to a command, run in the current
+// directory, and takes it back when the command exits, leaving powpow
+// where it was.
+func (app *App) runSuspended(name string, args ...string) error {
+	return app.suspended(func() error {
+		return execCommand(app.navigator.currentPath, name, args...)
+	})
+}
+
+// runPaused is runSuspended for commands whose output is the point: it
+// stays on the terminal until Enter is pressed.
+func (app *App) runPaused(name string, args ...string) error {
+	return app.suspended(func() error {
+		err := execCommand(app.navigator.currentPath, name, args...)
+		waitForEnter(os.Stdin, os.Stdout, err)
+		return err
+	})
+}
+
+func (app *App) suspended(run func() error) error {
+	if err := app.screen.Suspend(); err != nil {
+		return err
+	}
+	err := run()
+	if resumeErr := app.screen.Resume(); err == nil {
+		err = resumeErr
+	}
+	// The terminal may have been resized while the command ran
+	app.handleResize()
+	return err
+}
+
 // exitWithDirectoryInheritance uses the autocd-go library for directory inheritance.
 func (app *App) exitWithDirectoryInheritance(targetDir string) {
+	app.saveRecentDirs()
 	// Clean up tcell before process replacement
 	app.screen.Fini()
 	
@@ -1151,31 +1546,71 @@ func (app *App) renameItem(newName string) {
 		app.statusBar.showError("Cannot rename: " + err.Error())
 		return
 	}
+	app.recordOp(OpRename, []JournalItem{{From: oldPath, To: newPath}})
 
 	app.navigator.loadDirectory()
 	app.statusBar.showMessage("Renamed to: " + newName)
 }
 
-func (app *App) deleteItem() {
-	selected := app.navigator.getSelectedItem()
-	if selected == nil {
+func (app *App) markByGlob(pattern string) {
+	if pattern == "" {
+		app.statusBar.showError("Pattern cannot be empty")
 		return
 	}
+	count, err := app.navigator.markByGlob(pattern)
+	if err != nil {
+		app.statusBar.showError("Invalid pattern: " + err.Error())
+		return
+	}
+	app.statusBar.showMessage(fmt.Sprintf("Marked %d items matching %s", count, pattern))
+}
 
-	var err error
-	if selected.IsDir {
-		err = os.RemoveAll(selected.Path)
-	} else {
-		err = os.Remove(selected.Path)
+func (app *App) deleteItems(items []FileItem) {
+	if len(items) == 0 {
+		return
 	}
 
-	if err != nil {
-		app.statusBar.showError("Cannot delete: " + err.Error())
+	var journal []JournalItem
+	for i, item := range items {
+		trashed, err := app.trash.put(item.Path)
+		if err != nil {
+			app.recordOp(OpTrash, journal)
+			app.navigator.loadDirectory()
+			app.statusBar.showError(fmt.Sprintf("Cannot move %s to trash (%d of %d done): %v", item.Name, i, len(items), err))
+			return
+		}
+		journal = append(journal, JournalItem{From: trashed.OriginalPath, TrashName: trashed.Name, TrashDir: trashed.TrashDir})
+	}
+	app.recordOp(OpTrash, journal)
+
+	app.navigator.clearMarks()
+	app.navigator.loadDirectory()
+	app.statusBar.showMessage("Moved to trash: " + describeItems(items))
+}
+
+func (app *App) purgeItems(items []FileItem) {
+	if len(items) == 0 {
 		return
 	}
 
+	for i, item := range items {
+		var err error
+		if item.IsDir {
+			err = os.RemoveAll(item.Path)
+		} else {
+			err = os.Remove(item.Path)
+		}
+
+		if err != nil {
+			app.navigator.loadDirectory()
+			app.statusBar.showError(fmt.Sprintf("Cannot delete %s (%d of %d done): %v", item.Name, i, len(items), err))
+			return
+		}
+	}
+
+	app.navigator.clearMarks()
 	app.navigator.loadDirectory()
-	app.statusBar.showMessage("Deleted: " + selected.Name)
+	app.statusBar.showMessage("Permanently deleted: " + describeItems(items))
 }
 
 func (app *App) handleResize() {
@@ -1184,6 +1619,7 @@ func (app *App) handleResize() {
 
 func (app *App) run() {
 	for app.running {
+		app.syncWatcher()
 		app.render()
 
 		ev := app.screen.PollEvent()
@@ -1192,65 +1628,50 @@ func (app *App) run() {
 			app.handleKey(ev)
 		case *tcell.EventResize:
 			app.handleResize()
+		case *uiEvent:
+			if ev.fn != nil {
+				ev.fn()
+			}
+		case *watchEvent:
+			app.refreshFromDisk(ev.dir)
 		}
 	}
 
+	if app.watcher != nil {
+		app.watcher.close()
+	}
+	app.navigator.cancelLoading()
+	app.saveRecentDirs()
 	app.screen.Fini()
 }
 
 
-func printHelp() {
-	fmt.Println(`powpow - Minimal Terminal File Explorer
+func printHelp(keymap *Keymap) {
+	fmt.Print(`powpow - Minimal Terminal File Explorer
 
 USAGE:
     powpow [OPTIONS]
 
 OPTIONS:
     -a, --autocd       Enable directory inheritance (stay in final directory after quit)
+    -c, --config PATH  Use PATH instead of the default config file
     -h, --help         Show this help message
 
 ENVIRONMENT:
     POWPOW_AUTOCD=1   Enable autocd mode via environment variable
     EDITOR            Your preferred text editor (nano, vim, code, etc.)
+    XDG_DATA_HOME     Trash location (default ~/.local/share/Trash)
+    XDG_STATE_HOME    Undo journal location (default ~/.local/state/powpow)
+    XDG_CONFIG_HOME   Config location (default ~/.config/powpow/config.toml)
+    NO_COLOR          Use the colorless mono theme unless the config picks one
+    LS_COLORS         File list colors by type and extension (ui.ls_colors)
 
 ## Keyboard Controls
 
-### Navigation
-| Key         | Action                        |
-|-------------|-------------------------------|
-| ↑ ↓  j k    | Navigate file list            |
-| ← →  h l    | Go up / Enter directory       |
-| Enter       | Enter directory               |
-| Backspace   | Go to parent directory        |
-| Home/End    | Jump to first/last item       |
-| PgUp/PgDn   | Jump by page                  |
-
-### File Operations
-| Key      | Action                    |
-|----------|---------------------------|
-| Ctrl+N   | Create new file           |
-| Ctrl+F   | Create new folder         |
-| Ctrl+O   | Open file in editor       |
-| Ctrl+R   | Rename file/folder        |
-| Ctrl+D   | Delete file/folder        |
-
-### Search & Navigation
-| Key         | Action                          |
-|-------------|--------------------------------|
-| /           | Start fuzzy search             |
-| ESC         | Exit search mode               |
-| q           | Quit application               |
-| Ctrl+C      | Force quit                     |
-
-### Search Mode
-| Key         | Action                          |
-|-------------|--------------------------------|
-| Type        | Filter files with fuzzy matching |
-| ↑ ↓         | Navigate filtered results       |
-| Enter       | Select file/directory          |
-| ESC         | Exit search mode               |
-| Backspace   | Delete search characters       |
-
+Keys can be rebound in the [keys] table of config.toml.
+`)
+	fmt.Print(keymap.markdownHelp())
+	fmt.Println(`
 ## Features
 
 - Minimal, distraction-free interface
@@ -1263,16 +1684,28 @@ ENVIRONMENT:
 func main() {
 	// Parse command-line arguments
 	autocd := false
+	showHelp := false
+	configPath := ""
 	
 	for i := 1; i < len(os.Args); i++ {
 		arg := os.Args[i]
 		switch arg {
 		case "--help", "-h":
-			printHelp()
-			return
+			showHelp = true
 		case "--autocd", "-a":
 			autocd = true
+		case "--config", "-c":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "%s requires a file path\n", arg)
+				os.Exit(1)
+			}
+			i++
+			configPath = os.Args[i]
 		default:
+			if strings.HasPrefix(arg, "--config=") {
+				configPath = strings.TrimPrefix(arg, "--config=")
+				continue
+			}
 			if strings.HasPrefix(arg, "-") {
 				fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", arg)
 				fmt.Fprintf(os.Stderr, "Use --help for usage information\n")
@@ -1286,7 +1719,22 @@ func main() {
 		autocd = true
 	}
 
-	app, err := NewApp(autocd)
+	// Load config before starting the TUI so problems are printed readably
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "powpow: %v\n", err)
+		os.Exit(1)
+	}
+	config.registerLexers()
+
+	if showHelp {
+		// Help reflects the user's key bindings, which the config has validated
+		keymap, _ := NewKeymap(config.Keys, commandActions(config.Commands)...)
+		printHelp(keymap)
+		return
+	}
+
+	app, err := NewApp(autocd, config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing application: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// magicTypes recognize common binary formats by their first bytes.
+var magicTypes = []struct {
+	offset int
+	magic  string
+	mime   string
+}{
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "%PDF-", "application/pdf"},
+	{0, "PK\x03\x04", "application/zip"},
+	{0, "\x1f\x8b", "application/gzip"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "Rar!\x1a\x07", "application/vnd.rar"},
+	{257, "ustar", "application/x-tar"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "fLaC", "audio/flac"},
+	{0, "OggS", "audio/ogg"},
+	{4, "ftyp", "video/mp4"},
+	{0, "\x1a\x45\xdf\xa3", "video/webm"},
+	{0, "\x7fELF", "application/x-executable"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+}
+
+// riffTypes are told apart by the form type after the RIFF header.
+var riffTypes = map[string]string{"WEBP": "image/webp", "WAVE": "audio/wav", "AVI ": "video/x-msvideo"}
+
+// detectMIME guesses a file's type from its first bytes, falling back to
+// its extension. Text files are text/plain unless the extension is more
+// specific.
+func detectMIME(name string, head []byte) string {
+	if len(head) >= 12 && string(head[:4]) == "RIFF" {
+		if mimeType, ok := riffTypes[string(head[8:12])]; ok {
+			return mimeType
+		}
+	}
+	for _, t := range magicTypes {
+		if len(head) >= t.offset+len(t.magic) && string(head[t.offset:t.offset+len(t.magic)]) == t.magic {
+			return t.mime
+		}
+	}
+
+	byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	byExtension, _, _ = strings.Cut(byExtension, ";")
+	if looksLikeText(head) {
+		if byExtension != "" && !strings.HasPrefix(byExtension, "image/") && !strings.HasPrefix(byExtension, "audio/") && !strings.HasPrefix(byExtension, "video/") {
+			return byExtension
+		}
+		return "text/plain"
+	}
+	if byExtension != "" {
+		return byExtension
+	}
+	return "application/octet-stream"
+}
+
+// fileMIME reads the start of path to detect its type.
+func fileMIME(path string) string {
+	head := make([]byte, 512)
+	file, err := os.Open(path)
+	if err != nil {
+		return detectMIME(path, nil)
+	}
+	defer file.Close()
+	n, _ := io.ReadFull(file, head)
+	return detectMIME(path, head[:n])
+}
+
+// matches reports whether rule applies to a file called name of type mimeType.
+func (rule OpenerRule) matches(name, mimeType string) bool {
+	if slices.Contains(rule.Extensions, strings.ToLower(filepath.Ext(name))) {
+		return true
+	}
+	for _, glob := range rule.Globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	for _, pattern := range rule.MIME {
+		if ok, _ := path.Match(pattern, mimeType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingOpeners returns the rules for a file, in config order.
+func matchingOpeners(rules []OpenerRule, name, mimeType string) []OpenerRule {
+	var matching []OpenerRule
+	for _, rule := range rules {
+		if rule.matches(name, mimeType) {
+			matching = append(matching, rule)
+		}
+	}
+	return matching
+}
+
+// systemOpener is the desktop's own "open this file" command.
+func systemOpener() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"cmd", "/c", "start", ""}
+	}
+	return []string{"xdg-open"}
+}
+
+// runOpener opens path with an opener rule. Terminal programs take over
+// the screen until they exit; background ones are started and left to run.
+func (app *App) runOpener(rule OpenerRule, path string) {
+	words, err := shellWords(rule.Command)
+	if err != nil || len(words) == 0 {
+		app.statusBar.showError("Bad opener command: " + rule.Command)
+		return
+	}
+	cmd := fillArgs(words, map[string]string{"file": path}, "file")
+	if rule.Background {
+		if err := startDetached(cmd[0], cmd[1:]...); err != nil {
+			app.statusBar.showError("Cannot open " + filepath.Base(path) + ": " + err.Error())
+			return
+		}
+		app.statusBar.showMessage("Opened " + filepath.Base(path) + " with " + words[0])
+		return
+	}
+	if err := app.runSuspended(cmd[0], cmd[1:]...); err != nil {
+		app.statusBar.showError(words[0] + " failed: " + err.Error())
+	}
+	app.navigator.reload()
+}
+
+// openWithSystem hands path to the desktop's default application.
+func (app *App) openWithSystem(path string) {
+	cmd := append(systemOpener(), path)
+	if err := startDetached(cmd[0], cmd[1:]...); errors.Is(err, exec.ErrNotFound) {
+		app.statusBar.showError("No opener for " + filepath.Base(path) + " - add an [[opener]] rule or install " + cmd[0])
+	} else if err != nil {
+		app.statusBar.showError("Cannot open " + filepath.Base(path) + ": " + err.Error())
+	} else {
+		app.statusBar.showMessage("Opened " + filepath.Base(path) + " with " + cmd[0])
+	}
+}
+
+// openWith offers every way to open the selected file: the rules that
+// match it first, then the editor, the system default and the other rules.
+func (app *App) openWith() {
+	selected := app.navigator.getSelectedItem()
+	if selected == nil || selected.IsDir {
+		return
+	}
+	if selected.Broken {
+		app.statusBar.showError(brokenLinkMessage(*selected))
+		return
+	}
+	item := *selected
+	rules := app.cfg().Openers
+	mimeType := fileMIME(item.Path)
+	matching := matchingOpeners(rules, item.Name, mimeType)
+
+	var items []PickerItem
+	var runs []func(app *App)
+	add := func(label, detail string, run func(app *App)) {
+		items = append(items, PickerItem{Label: label, Detail: detail})
+		runs = append(runs, run)
+	}
+	for _, rule := range matching {
+		rule := rule
+		add(rule.Command, "matches", func(app *App) { app.runOpener(rule, item.Path) })
+	}
+	editor, err := pickEditor(os.Getenv("VISUAL"), os.Getenv("EDITOR"), app.cfg().Editor)
+	if err == nil {
+		add(strings.Join(editor, " "), "editor", func(app *App) { app.openFileWithEditor(item.Path) })
+	}
+	add(strings.Join(systemOpener(), " "), "system default", func(app *App) { app.openWithSystem(item.Path) })
+	for _, rule := range rules {
+		rule := rule
+		if !slices.ContainsFunc(matching, func(m OpenerRule) bool { return m.Command == rule.Command }) {
+			add(rule.Command, "", func(app *App) { app.runOpener(rule, item.Path) })
+		}
+	}
+
+	app.showPicker(&Picker{
+		title: "Open " + item.Name + " (" + mimeType + ") with",
+		items: items,
+		onSelect: func(app *App, _ PickerItem, index int) {
+			runs[index](app)
+		},
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileOwner returns -1 for both ids: ownership is not exposed here.
+func fileOwner(info os.FileInfo) (uid, gid int) {
+	return -1, -1
+}
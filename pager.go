@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pager shows lines of text full screen, such as the command log. The
+// lines are fetched on every draw, so a pager over a growing log stays
+// current.
+type Pager struct {
+	title  string
+	source func() []string
+	scroll int
+	follow bool        // keep the last line in view until scrolled up
+	run    *CommandRun // the command writing the lines, if it is still to be stopped
+}
+
+func (app *App) showPager(title string, source func() []string, follow bool) {
+	app.pager = &Pager{title: title, source: source, follow: follow}
+}
+
+// closePager closes the pager, stopping the command it shows: its output
+// would have nowhere left to go.
+func (app *App) closePager() {
+	app.stopPagerCommand()
+	app.pager = nil
+}
+
+func (app *App) stopPagerCommand() {
+	if run := app.pager.run; run != nil {
+		run.stop()
+	}
+}
+
+// pagerRows is how many lines fit between the title and the status bar.
+func (app *App) pagerRows() int {
+	return max(app.height-2, 1)
+}
+
+func (app *App) scrollPager(delta int) {
+	p := app.pager
+	p.scroll = max(0, min(p.scroll+delta, len(p.source())-app.pagerRows()))
+	p.follow = false
+}
+
+func (app *App) pagerBottom() {
+	app.pager.scroll = max(0, len(app.pager.source())-app.pagerRows())
+	app.pager.follow = true
+}
+
+// pagerText makes a line of program output safe to draw: tabs are
+// expanded and other control characters dropped.
+func pagerText(line string) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+func (app *App) drawPager() {
+	p := app.pager
+	theme := app.theme()
+	lines := p.source()
+	rows := app.pagerRows()
+	maxScroll := max(0, len(lines)-rows)
+	if p.follow {
+		p.scroll = maxScroll
+	}
+	p.scroll = min(p.scroll, maxScroll)
+
+	for x := 0; x < app.width; x++ {
+		app.screen.SetContent(x, 0, ' ', nil, theme.Bar)
+	}
+	app.drawText(1, 0, p.title, theme.Bar)
+	if len(lines) > rows {
+		position := fmt.Sprintf(" %d-%d of %d ", p.scroll+1, min(p.scroll+rows, len(lines)), len(lines))
+		app.drawText(app.width-len(position), 0, position, theme.Bar)
+	}
+
+	for i := 0; i < rows && p.scroll+i < len(lines); i++ {
+		app.drawText(1, 1+i, pagerText(lines[p.scroll+i]), theme.File)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/sahilm/fuzzy"
+)
+
+const maxPickerRows = 12
+
+// PickerItem is one choice in a picker; Detail is shown dimmed after
+// Label and is matched too.
+type PickerItem struct {
+	Label  string
+	Detail string
+}
+
+// Picker is a popup list narrowed with the fuzzy matcher as you type.
+type Picker struct {
+	title        string
+	items        []PickerItem
+	query        string
+	matches      []int // indexes into items, best match first
+	selectedIdx  int
+	scrollOffset int
+	empty        string // shown when there is nothing to pick
+	onSelect     func(app *App, item PickerItem, index int)
+	onDelete     func(app *App, item PickerItem) // nil when items can't be removed
+}
+
+// pickerSource matches label and detail together, so "work api" finds a
+// bookmark named work pointing at ~/src/api.
+type pickerSource []PickerItem
+
+func (s pickerSource) String(i int) string { return s[i].Label + " " + s[i].Detail }
+func (s pickerSource) Len() int            { return len(s) }
+
+func (app *App) showPicker(p *Picker) {
+	app.picker = p
+	p.setQuery("")
+}
+
+func (app *App) closePicker() {
+	app.picker = nil
+}
+
+func (p *Picker) setQuery(query string) {
+	p.query = query
+	p.selectedIdx = 0
+	p.matches = p.matches[:0]
+	if query == "" {
+		for i := range p.items {
+			p.matches = append(p.matches, i)
+		}
+		return
+	}
+	for _, match := range fuzzy.FindFrom(query, pickerSource(p.items)) {
+		p.matches = append(p.matches, match.Index)
+	}
+}
+
+// setItems replaces the choices, keeping the query.
+func (p *Picker) setItems(items []PickerItem) {
+	p.items = items
+	selectedIdx := p.selectedIdx
+	p.setQuery(p.query)
+	p.selectedIdx = selectedIdx
+	p.moveSelection(0)
+}
+
+func (p *Picker) moveSelection(delta int) {
+	p.selectedIdx = max(0, min(p.selectedIdx+delta, len(p.matches)-1))
+}
+
+func (p *Picker) selected() *PickerItem {
+	if p.selectedIdx < 0 || p.selectedIdx >= len(p.matches) {
+		return nil
+	}
+	return &p.items[p.matches[p.selectedIdx]]
+}
+
+func (app *App) selectPickerItem() {
+	p := app.picker
+	item := p.selected()
+	if item == nil {
+		return
+	}
+	app.closePicker()
+	p.onSelect(app, *item, p.matches[p.selectedIdx])
+}
+
+func (app *App) deletePickerItem() {
+	p := app.picker
+	if item := p.selected(); item != nil && p.onDelete != nil {
+		p.onDelete(app, *item)
+	}
+}
+
+func (app *App) drawPicker() {
+	p := app.picker
+	if p == nil {
+		return
+	}
+	theme := app.theme()
+	keys := app.keys()
+
+	footer := fmt.Sprintf("%s: Go  %s: Cancel", keys.hint("picker.select"), keys.hint("picker.cancel"))
+	if p.onDelete != nil {
+		footer += fmt.Sprintf("  %s: Remove", keys.hint("picker.delete"))
+	}
+	rows := min(maxPickerRows, max(len(p.matches), 1))
+	width := max(40, min(app.width-4, 80))
+	height := rows + 7 // borders, title, query, gaps, footer
+	startX := max((app.width-width)/2, 0)
+	startY := max((app.height-height)/2, 0)
+	app.drawPopupFrame(startX, startY, width, height)
+
+	app.drawTextWithin(max(startX+(width-len(p.title))/2, startX+2), startY+1, startX+width-1, p.title, theme.PopupTitle)
+	app.drawTextWithin(startX+2, startY+2, startX+width-2, "> "+p.query+"█", theme.Popup)
+
+	if p.selectedIdx >= p.scrollOffset+rows {
+		p.scrollOffset = p.selectedIdx - rows + 1
+	}
+	if p.selectedIdx < p.scrollOffset {
+		p.scrollOffset = p.selectedIdx
+	}
+	if len(p.matches) == 0 {
+		message := p.empty
+		if p.query != "" || message == "" {
+			message = "No matches"
+		}
+		app.drawTextWithin(startX+2, startY+4, startX+width-2, message, overlay(theme.Popup, theme.Dim))
+	}
+	labelWidth := 0
+	for _, idx := range p.matches {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(p.items[idx].Label))
+	}
+	labelWidth = min(labelWidth, width/3)
+	for i := 0; i < rows && i+p.scrollOffset < len(p.matches); i++ {
+		idx := i + p.scrollOffset
+		item := p.items[p.matches[idx]]
+		y := startY + 4 + i
+		labelStyle, detailStyle := theme.Popup, overlay(theme.Popup, theme.Dim)
+		if idx == p.selectedIdx {
+			labelStyle, detailStyle = theme.Selected, theme.Selected
+			for x := startX + 1; x < startX+width-1; x++ {
+				app.screen.SetContent(x, y, ' ', nil, theme.Selected)
+			}
+		}
+		app.drawTextWithin(startX+2, y, startX+width-2, item.Label, labelStyle)
+		if item.Detail != "" {
+			app.drawTextWithin(startX+4+labelWidth, y, startX+width-2, item.Detail, detailStyle)
+		}
+	}
+
+	app.drawTextWithin(max(startX+(width-len(footer))/2, startX+2), startY+height-2, startX+width-1, footer, theme.Popup)
+}
@@ -2,10 +2,20 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
 )
 
 // Test fixtures and helper functions
@@ -272,6 +282,80 @@ func TestNavigatorSearch(t *testing.T) {
 	}
 }
 
+// Tests for multi-item selection
+
+func TestNavigatorMarksSurviveSearch(t *testing.T) {
+	testDir := createTestStructure(t)
+	nav := NewNavigator(testDir)
+
+	nav.setSearch("simple")
+	nav.toggleMark()
+	nav.setSearch("")
+
+	marked := nav.markedItems()
+	if len(marked) != 1 || marked[0].Name != "simple.txt" {
+		t.Fatalf("markedItems() = %v, want simple.txt", marked)
+	}
+
+	nav.clearMarks()
+	nav.setSearch("main")
+	visible := len(nav.filteredItems)
+	nav.markAll()
+	nav.setSearch("")
+	if got := len(nav.markedItems()); got != visible {
+		t.Errorf("After marking search results, marked = %d, want %d", got, visible)
+	}
+
+	nav.invertMarks()
+	if got := len(nav.markedItems()); got != len(nav.items)-visible {
+		t.Errorf("After invert, marked = %d, want %d", got, len(nav.items)-visible)
+	}
+}
+
+func TestNavigatorMarkByGlob(t *testing.T) {
+	testDir := createTestStructure(t)
+	nav := NewNavigator(testDir)
+
+	count, err := nav.markByGlob("*.txt")
+	if err != nil {
+		t.Fatalf("markByGlob() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("markByGlob(*.txt) = %d, want 3", count)
+	}
+
+	if _, err := nav.markByGlob("[unclosed"); err == nil {
+		t.Error("Invalid pattern should return an error")
+	}
+}
+
+func TestNavigatorTargetItems(t *testing.T) {
+	testDir := createTestStructure(t)
+	nav := NewNavigator(testDir)
+
+	targets := nav.targetItems()
+	if len(targets) != 1 || targets[0].Name != nav.getSelectedItem().Name {
+		t.Errorf("Without marks, targetItems() should be the selection, got %v", targets)
+	}
+
+	nav.markByGlob("main.*")
+	if got := len(nav.targetItems()); got != 3 {
+		t.Errorf("With marks, targetItems() = %d items, want 3", got)
+	}
+
+	os.Remove(filepath.Join(testDir, "main.go"))
+	nav.loadDirectory()
+	if got := len(nav.targetItems()); got != 2 {
+		t.Errorf("Marks for removed files should be pruned, got %d", got)
+	}
+
+	nav.selectedIdx = 0
+	nav.enterDirectory()
+	if len(nav.marked) != 0 {
+		t.Error("Changing directory should clear marks")
+	}
+}
+
 // Tests for text file detection (simplified without Previewer)
 
 func TestTextFileDetection(t *testing.T) {
@@ -378,6 +462,2910 @@ func TestGetUniqueFilePath(t *testing.T) {
 	}
 }
 
+// Tests for the trash
+
+func TestTrashPutListRestore(t *testing.T) {
+	testDir := createTestStructure(t)
+	trash := NewTrash(filepath.Join(t.TempDir(), "Trash"))
+
+	original := filepath.Join(testDir, "simple.txt")
+	entry, err := trash.put(original)
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	if _, err := os.Stat(original); !os.IsNotExist(err) {
+		t.Error("Trashed file should no longer exist at its original path")
+	}
+	if _, err := os.Stat(trash.filePath(entry.Name)); err != nil {
+		t.Errorf("Trashed file missing from trash: %v", err)
+	}
+
+	entries, err := trash.list()
+	if err != nil {
+		t.Fatalf("list() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].OriginalPath != original {
+		t.Fatalf("list() = %+v, want one entry for %s", entries, original)
+	}
+
+	if err := trash.restoreTo(entries[0], original); err != nil {
+		t.Fatalf("restoreTo() error = %v", err)
+	}
+	content, err := os.ReadFile(original)
+	if err != nil || string(content) != "Hello World" {
+		t.Errorf("Restored content = %q, %v", content, err)
+	}
+	if entries, _ := trash.list(); len(entries) != 0 {
+		t.Errorf("Trash should be empty after restore, got %d entries", len(entries))
+	}
+}
+
+func TestVolumeTrash(t *testing.T) {
+	topdir := t.TempDir()
+	os.Mkdir(filepath.Join(topdir, "sub"), 0755)
+	original := createTestFile(t, filepath.Join(topdir, "sub"), "a.txt", "x")
+	home := NewTrash(filepath.Join(t.TempDir(), "Trash"))
+	home.mounts = func() []string { return []string{topdir} }
+
+	volume, err := volumeTrash(topdir)
+	if err != nil {
+		t.Fatalf("volumeTrash() error = %v", err)
+	}
+	if want := filepath.Join(topdir, fmt.Sprintf(".Trash-%d", os.Getuid())); volume.dir != want {
+		t.Errorf("volume trash = %s, want %s", volume.dir, want)
+	}
+	entry, err := volume.put(original)
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	info, _ := os.ReadFile(volume.infoPath(entry.Name))
+	if !strings.Contains(string(info), "\nPath=sub/a.txt\n") {
+		t.Errorf("trashinfo = %q, want a path relative to the volume", info)
+	}
+
+	// The home trash lists and restores what the volume trash holds
+	entries, err := home.list()
+	if err != nil || len(entries) != 1 || entries[0].OriginalPath != original || entries[0].TrashDir != volume.dir {
+		t.Fatalf("list() = %+v, %v; want the volume entry", entries, err)
+	}
+	journal := LoadJournal(filepath.Join(t.TempDir(), "journal.json"), home)
+	journal.record(OpTrash, []JournalItem{{From: original, TrashName: entry.Name, TrashDir: entry.TrashDir}})
+	if _, err := journal.undo(); err != nil {
+		t.Fatalf("undo() error = %v", err)
+	}
+	if _, err := os.Stat(original); err != nil {
+		t.Errorf("undo should restore from the volume trash: %v", err)
+	}
+	if entries, _ := home.list(); len(entries) != 0 {
+		t.Errorf("trash should be empty after the undo, got %+v", entries)
+	}
+
+	// A shared .Trash with the sticky bit takes precedence
+	shared := t.TempDir()
+	os.Mkdir(filepath.Join(shared, ".Trash"), 0777|os.ModeSticky)
+	os.Chmod(filepath.Join(shared, ".Trash"), 0777|os.ModeSticky)
+	if volume, err := volumeTrash(shared); err != nil || volume.dir != filepath.Join(shared, ".Trash", strconv.Itoa(os.Getuid())) {
+		t.Errorf("volumeTrash() with a shared .Trash = %v, %v", volume, err)
+	}
+}
+
+func TestTrashNameCollision(t *testing.T) {
+	trash := NewTrash(filepath.Join(t.TempDir(), "Trash"))
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		dir := t.TempDir()
+		path := createTestFile(t, dir, "same.txt", fmt.Sprintf("copy %d", i))
+		entry, err := trash.put(path)
+		if err != nil {
+			t.Fatalf("put() error = %v", err)
+		}
+		names = append(names, entry.Name)
+	}
+
+	expected := []string{"same.txt", "same.1.txt", "same.2.txt"}
+	for i, name := range names {
+		if name != expected[i] {
+			t.Errorf("entry %d name = %s, want %s", i, name, expected[i])
+		}
+	}
+}
+
+func TestTrashPurgeDirectory(t *testing.T) {
+	testDir := createTestStructure(t)
+	trash := NewTrash(filepath.Join(t.TempDir(), "Trash"))
+
+	entry, err := trash.put(filepath.Join(testDir, "subdir1"))
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	if !entry.IsDir {
+		t.Error("Trashed directory should be recorded as a directory")
+	}
+
+	if err := trash.purge(entry); err != nil {
+		t.Fatalf("purge() error = %v", err)
+	}
+	if _, err := os.Stat(trash.filePath(entry.Name)); !os.IsNotExist(err) {
+		t.Error("Purged entry should be gone from trash files")
+	}
+	if _, err := os.Stat(trash.infoPath(entry.Name)); !os.IsNotExist(err) {
+		t.Error("Purged entry should have no trashinfo")
+	}
+}
+
+func TestCopyTreePreservesModeAndMtime(t *testing.T) {
+	testDir := createTestStructure(t)
+	script := filepath.Join(testDir, "script.sh")
+	os.Chmod(script, 0750)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(script, mtime, mtime)
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := copyTree(testDir, dst); err != nil {
+		t.Fatalf("copyTree() error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "script.sh"))
+	if err != nil {
+		t.Fatalf("Copied file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("Mode = %v, want 0750", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("ModTime = %v, want %v", info.ModTime(), mtime)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "subdir1", "nested", "deep.txt")); err != nil {
+		t.Errorf("Nested file not copied: %v", err)
+	}
+}
+
+// Tests for the transfer engine
+
+func TestTransferCopyWithConflicts(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+
+	sources := []string{filepath.Join(testDir, "simple.txt"), filepath.Join(testDir, "subdir1")}
+	transfer := NewTransfer(ClipCopy, sources, testDir)
+	if err := transfer.run(app.getUniqueFilePath); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	for _, name := range []string{"simple.txt", "simple-1.txt", "subdir1-1/nested/deep.txt"} {
+		if _, err := os.Stat(filepath.Join(testDir, name)); err != nil {
+			t.Errorf("Expected %s after copy: %v", name, err)
+		}
+	}
+	if transfer.done != transfer.total {
+		t.Errorf("Progress = %d/%d, want complete", transfer.done, transfer.total)
+	}
+	if len(transfer.results) != 2 {
+		t.Errorf("results = %v, want 2 entries", transfer.results)
+	}
+}
+
+func TestTransferCut(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+	dest := filepath.Join(testDir, "subdir2")
+
+	sources := []string{filepath.Join(testDir, "simple.txt"), filepath.Join(testDir, "data.json")}
+	transfer := NewTransfer(ClipCut, sources, dest)
+	if err := transfer.run(app.getUniqueFilePath); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	for _, src := range sources {
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("%s should have been moved", src)
+		}
+		if _, err := os.Stat(filepath.Join(dest, filepath.Base(src))); err != nil {
+			t.Errorf("%s missing from destination: %v", filepath.Base(src), err)
+		}
+	}
+}
+
+func TestFailedCutPasteKeepsClipboard(t *testing.T) {
+	testDir := createTestStructure(t)
+	dest := filepath.Join(testDir, "subdir1", "nested")
+	os.MkdirAll(dest, 0755)
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dest), statusBar: &StatusBar{}}
+
+	// simple.txt moves, then subdir1 can't go into itself and stops the paste
+	sources := []string{filepath.Join(testDir, "simple.txt"), filepath.Join(testDir, "subdir1"), filepath.Join(testDir, "data.json")}
+	app.clipboard = &Clipboard{mode: ClipCut, paths: sources}
+	app.pasteItems()
+	for app.transfer != nil {
+		if ev, ok := screen.PollEvent().(*uiEvent); ok && ev.fn != nil {
+			ev.fn()
+		}
+	}
+	if !app.statusBar.isError {
+		t.Errorf("status = %q, want the failure reported", app.statusBar.message)
+	}
+	if app.clipboard == nil || app.clipboard.mode != ClipCut || !slices.Equal(app.clipboard.paths, sources[1:]) {
+		t.Errorf("clipboard = %+v, want the items that did not move", app.clipboard)
+	}
+}
+
+func TestTransferRefusesSpecialFiles(t *testing.T) {
+	testDir := t.TempDir()
+	src := filepath.Join(testDir, "dir")
+	os.Mkdir(src, 0755)
+	createTestFile(t, src, "a.txt", "x")
+	makeFIFO(t, filepath.Join(src, "pipe"))
+	dest := t.TempDir()
+
+	finished := make(chan error)
+	go func() {
+		finished <- NewTransfer(ClipCopy, []string{src}, dest).run((&App{}).getUniqueFilePath)
+	}()
+	select {
+	case err := <-finished:
+		if err == nil || !strings.Contains(err.Error(), "unsupported file type") {
+			t.Errorf("run() error = %v, want the pipe refused", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("copying a named pipe blocked")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "dir")); !os.IsNotExist(err) {
+		t.Error("a failed copy should not leave a partial tree")
+	}
+}
+
+func TestTransferIntoItself(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+	src := filepath.Join(testDir, "subdir1")
+
+	transfer := NewTransfer(ClipCopy, []string{src}, filepath.Join(src, "nested"))
+	if err := transfer.run(app.getUniqueFilePath); err == nil {
+		t.Error("Pasting a directory into itself should fail")
+	}
+}
+
+func TestTransferCancel(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+	dest := t.TempDir()
+
+	transfer := NewTransfer(ClipCopy, []string{filepath.Join(testDir, "large_file.txt")}, dest)
+	transfer.cancel()
+	if err := transfer.run(app.getUniqueFilePath); err != errTransferCanceled {
+		t.Errorf("run() error = %v, want errTransferCanceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "large_file.txt")); !os.IsNotExist(err) {
+		t.Error("Canceled copy should not leave a partial file")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KB",
+		1536:            "1.5 KB",
+		11 * 1024 * 1024: "11.0 MB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
+
+// Tests for the undo journal
+
+func newTestJournal(t *testing.T) *Journal {
+	stateDir := t.TempDir()
+	trash := NewTrash(filepath.Join(stateDir, "Trash"))
+	return LoadJournal(filepath.Join(stateDir, "journal.json"), trash)
+}
+
+func TestJournalUndoRedoRename(t *testing.T) {
+	testDir := createTestStructure(t)
+	journal := newTestJournal(t)
+
+	from := filepath.Join(testDir, "simple.txt")
+	to := filepath.Join(testDir, "renamed.txt")
+	os.Rename(from, to)
+	journal.record(OpRename, []JournalItem{{From: from, To: to}})
+
+	if _, err := journal.undo(); err != nil {
+		t.Fatalf("undo() error = %v", err)
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Errorf("Undo should restore %s: %v", from, err)
+	}
+
+	if _, err := journal.redo(); err != nil {
+		t.Fatalf("redo() error = %v", err)
+	}
+	if _, err := os.Stat(to); err != nil {
+		t.Errorf("Redo should recreate %s: %v", to, err)
+	}
+}
+
+func TestJournalUndoTrashAfterReload(t *testing.T) {
+	testDir := createTestStructure(t)
+	journal := newTestJournal(t)
+
+	path := filepath.Join(testDir, "subdir1")
+	trashed, err := journal.trash.put(path)
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	journal.record(OpTrash, []JournalItem{{From: path, TrashName: trashed.Name}})
+
+	// Simulate a restart
+	reloaded := LoadJournal(journal.path, journal.trash)
+	if len(reloaded.Undo) != 1 {
+		t.Fatalf("Reloaded journal has %d entries, want 1", len(reloaded.Undo))
+	}
+	if _, err := reloaded.undo(); err != nil {
+		t.Fatalf("undo() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "nested", "deep.txt")); err != nil {
+		t.Errorf("Undo should restore trashed directory: %v", err)
+	}
+}
+
+func TestJournalUndoCreate(t *testing.T) {
+	testDir := t.TempDir()
+	journal := newTestJournal(t)
+
+	path := createTestFile(t, testDir, "new.txt", "")
+	journal.record(OpCreate, []JournalItem{{To: path}})
+
+	if _, err := journal.undo(); err != nil {
+		t.Fatalf("undo() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Undo of create should remove the file")
+	}
+	if _, err := journal.redo(); err != nil {
+		t.Fatalf("redo() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Redo of create should bring the file back: %v", err)
+	}
+}
+
+func TestJournalNeverOverwrites(t *testing.T) {
+	testDir := createTestStructure(t)
+	journal := newTestJournal(t)
+
+	from := filepath.Join(testDir, "simple.txt")
+	to := filepath.Join(testDir, "renamed.txt")
+	os.Rename(from, to)
+	journal.record(OpRename, []JournalItem{{From: from, To: to}})
+	createTestFile(t, testDir, "simple.txt", "new content")
+
+	if _, err := journal.undo(); err == nil {
+		t.Error("Undo should refuse to overwrite an existing file")
+	}
+	if content, _ := os.ReadFile(from); string(content) != "new content" {
+		t.Errorf("Existing file was modified: %q", content)
+	}
+	if len(journal.Undo) != 1 {
+		t.Error("Failed undo should stay on the undo stack")
+	}
+}
+
+func TestJournalPartialUndo(t *testing.T) {
+	testDir := t.TempDir()
+	dest := filepath.Join(testDir, "dest")
+	os.Mkdir(dest, 0755)
+	journal := newTestJournal(t)
+	var items []JournalItem
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		createTestFile(t, dest, name, name)
+		items = append(items, JournalItem{From: filepath.Join(testDir, name), To: filepath.Join(dest, name)})
+	}
+	journal.record(OpMove, items)
+	// Something new took b.txt's old place, so moving it back must fail
+	createTestFile(t, testDir, "b.txt", "new")
+
+	undone, err := journal.undo()
+	if err == nil {
+		t.Fatal("undo should report the item it could not move back")
+	}
+	if len(undone.Items) != 1 || undone.Items[0] != items[2] {
+		t.Errorf("undone = %+v, want only c.txt", undone.Items)
+	}
+	if len(journal.Undo) != 1 || !slices.Equal(journal.Undo[0].Items, items[:2]) {
+		t.Errorf("undo stack = %+v, want a.txt and b.txt left to undo", journal.Undo)
+	}
+	if len(journal.Redo) != 1 || !slices.Equal(journal.Redo[0].Items, items[2:]) {
+		t.Errorf("redo stack = %+v, want c.txt", journal.Redo)
+	}
+	if _, err := os.Stat(items[0].To); err != nil {
+		t.Errorf("a.txt should not have moved yet: %v", err)
+	}
+
+	// Once the way is clear, retrying finishes the job
+	os.Remove(filepath.Join(testDir, "b.txt"))
+	if _, err := journal.undo(); err != nil {
+		t.Fatalf("retried undo() error = %v", err)
+	}
+	for _, item := range items {
+		if _, err := os.Stat(item.From); err != nil {
+			t.Errorf("%s should be back: %v", item.From, err)
+		}
+	}
+	reloaded := LoadJournal(journal.path, journal.trash)
+	if len(reloaded.Undo) != 0 || len(reloaded.Redo) != 2 {
+		t.Errorf("saved journal has %d undo and %d redo entries, want 0 and 2", len(reloaded.Undo), len(reloaded.Redo))
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := journal.redo(); err != nil {
+			t.Fatalf("redo() error = %v", err)
+		}
+	}
+	for _, item := range items {
+		if _, err := os.Stat(item.To); err != nil {
+			t.Errorf("%s should be moved again: %v", item.To, err)
+		}
+	}
+}
+
+// Tests for the preview pane
+
+func TestLoadPreview(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+
+	tests := []struct {
+		filename  string
+		wantKind  PreviewKind
+		wantFirst string
+	}{
+		{"readme.md", PreviewText, "# Test Project"},
+		{"subdir1", PreviewDir, "2 items"},
+		{"empty_dir", PreviewDir, "(empty directory)"},
+		{"binary_file", PreviewBinary, "Binary file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			item, err := NewFileItem(filepath.Join(testDir, tt.filename))
+			if err != nil {
+				t.Fatalf("NewFileItem() error = %v", err)
+			}
+			content := loadPreview(item, 20, app.isTextFile)
+			if content.kind != tt.wantKind {
+				t.Errorf("kind = %v, want %v", content.kind, tt.wantKind)
+			}
+			if len(content.lines) == 0 || content.lines[0] != tt.wantFirst {
+				t.Errorf("first line = %v, want %q", content.lines, tt.wantFirst)
+			}
+			if len(content.lines) > 21 {
+				t.Errorf("Preview has %d lines, want at most 21", len(content.lines))
+			}
+		})
+	}
+}
+
+func TestPreviewLargeFileIsBounded(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{}
+	item, _ := NewFileItem(filepath.Join(testDir, "large_file.txt"))
+
+	start := time.Now()
+	content := loadPreview(item, 50, app.isTextFile)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Previewing an 11MB file took %v", elapsed)
+	}
+	if content.kind != PreviewText || len(content.lines) != 1 {
+		t.Errorf("Large single-line file preview = kind %v, %d lines", content.kind, len(content.lines))
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	lines := hexDump([]byte("Hello, World!\x00\x01\x02\x03"))
+	want := []string{
+		"00000000  48 65 6c 6c 6f 2c 20 57  6f 72 6c 64 21 00 01 02  |Hello, World!...|",
+		"00000010  03                                                |.|",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("hexDump() = %d lines, want %d", len(lines), len(want))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+// Tests for syntax highlighting
+
+func tokenKinds(tokens []Token) map[string]TokenKind {
+	kinds := make(map[string]TokenKind)
+	for _, token := range tokens {
+		kinds[token.Text] = token.Kind
+	}
+	return kinds
+}
+
+func TestHighlightGo(t *testing.T) {
+	lines := []string{
+		`func main() { x := "hi" // note`,
+		`/* start`,
+		`end */ return 42`,
+	}
+	tokens := highlightLines(lexerFor("main.go"), lines)
+
+	first := tokenKinds(tokens[0])
+	if first["func"] != TokenKeyword {
+		t.Error("func should be a keyword")
+	}
+	if first[`"hi"`] != TokenString {
+		t.Error(`"hi" should be a string`)
+	}
+	if first["// note"] != TokenComment {
+		t.Error("// note should be a comment")
+	}
+
+	if len(tokens[1]) != 1 || tokens[1][0].Kind != TokenComment {
+		t.Errorf("Open block comment line = %v", tokens[1])
+	}
+	third := tokenKinds(tokens[2])
+	if third["end */"] != TokenComment || third["return"] != TokenKeyword || third["42"] != TokenNumber {
+		t.Errorf("Block comment should close mid-line, got %v", tokens[2])
+	}
+}
+
+func TestHighlightConfigFormats(t *testing.T) {
+	json := tokenKinds(lexerFor("data.json").Tokenize(`{"key": "value", "n": true}`, &LexState{}))
+	if json[`"key"`] != TokenKey || json[`"value"`] != TokenString || json["true"] != TokenKeyword {
+		t.Errorf("JSON tokens = %v", json)
+	}
+
+	yaml := tokenKinds(lexerFor("ci.yml").Tokenize(`  name: "build" # comment`, &LexState{}))
+	if yaml["name"] != TokenKey || yaml[`"build"`] != TokenString || yaml["# comment"] != TokenComment {
+		t.Errorf("YAML tokens = %v", yaml)
+	}
+
+	tokens := highlightLines(lexerFor("readme.md"), []string{"# Title", "```", "# not a heading", "```"})
+	if tokens[0][0].Kind != TokenHeading || tokens[2][0].Kind != TokenString {
+		t.Errorf("Markdown tokens = %v", tokens)
+	}
+}
+
+func TestRegisterCustomLexer(t *testing.T) {
+	registerLexer(&GenericLexer{Keywords: []string{"widget"}, LineComments: []string{"%"}}, ".widgetconf")
+	defer delete(lexers, ".widgetconf")
+
+	lexer := lexerFor("factory.WIDGETCONF")
+	if lexer == nil {
+		t.Fatal("Custom lexer should be found case-insensitively")
+	}
+	kinds := tokenKinds(lexer.Tokenize("widget gear % spin", &LexState{}))
+	if kinds["widget"] != TokenKeyword || kinds["% spin"] != TokenComment {
+		t.Errorf("Custom lexer tokens = %v", kinds)
+	}
+
+	if lexerFor("notes.unknownext") != nil {
+		t.Error("Unknown extensions should have no lexer")
+	}
+}
+
+// Tests for configuration
+
+func TestParseTOML(t *testing.T) {
+	data := `
+# comment
+top = "value" # trailing comment
+[ui]
+page_size = 20
+ratio = 0.5
+enabled = true
+list = [
+  "a", 'b#c',   # comment inside array
+  "d\"e",
+]
+[[syntax]]
+extensions = [".x"]
+[[syntax]]
+extensions = [".y"]
+`
+	root, err := parseTOML(data)
+	if err != nil {
+		t.Fatalf("parseTOML() error = %v", err)
+	}
+	if root["top"] != "value" {
+		t.Errorf("top = %v", root["top"])
+	}
+	ui := root["ui"].(map[string]any)
+	if ui["page_size"] != int64(20) || ui["ratio"] != 0.5 || ui["enabled"] != true {
+		t.Errorf("ui = %v", ui)
+	}
+	list := ui["list"].([]any)
+	if len(list) != 3 || list[1] != "b#c" || list[2] != `d"e` {
+		t.Errorf("list = %v", list)
+	}
+	if syntax := root["syntax"].([]any); len(syntax) != 2 {
+		t.Errorf("syntax = %v, want 2 tables", syntax)
+	}
+
+	for _, bad := range []string{"key = unquoted", "[unterminated", "a = 1\na = 2", "= 3", "syntax = []\n[syntax.x]"} {
+		if _, err := parseTOML(bad); err == nil {
+			t.Errorf("parseTOML(%q) should fail", bad)
+		}
+	}
+	if _, err := parseConfig("test.toml", "syntax = []\n[syntax.x]\n"); err == nil || !strings.Contains(err.Error(), "syntax is not a table") {
+		t.Errorf("an empty array used as a table should be a config error, got %v", err)
+	}
+}
+
+func TestParseTOMLRejectsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"inline table", "[ui]\ncolors = { fg = \"red\" }", "line 2: inline tables are not supported"},
+		{"inline table in array", "a = [\n  1,\n]\nb = [{ x = 1 }]", "line 4: inline tables are not supported"},
+		{"multi-line basic string", "# header\ntext = \"\"\"\nhello\n\"\"\"", "line 2: multi-line strings are not supported"},
+		{"multi-line literal string", "text = '''hello'''", "line 1: multi-line strings are not supported"},
+		{"date", "\n\nwhen = 1979-05-27", "line 3: dates and times are not supported"},
+		{"date time", "when = 1979-05-27T07:32:00Z", "line 1: dates and times are not supported"},
+		{"time", "when = 07:32:00", "line 1: dates and times are not supported"},
+		{"table array after scalar", "a = 1\n[[a]]", "line 2: a is not an array of tables"},
+		{"table array after array", "a = [\"x\"]\n[[a]]", "line 2: a is not an array of tables"},
+		{"table array after empty array", "a = []\n[[a]]", "line 2: a is not an array of tables"},
+		{"table array after table", "[a]\nx = 1\n[[a]]", "line 3: a is not an array of tables"},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: parseTOML() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[files]
+extra_text_extensions = ["nix", ".HCL"]
+[ui]
+status_timeout = "500ms"
+page_size = 25
+[sort]
+dirs_first = false
+[colors]
+directory = "#ff8800"
+[[syntax]]
+extensions = [".nix"]
+line_comments = ["#"]
+`)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if config.StatusTimeout != 500*time.Millisecond || config.PageSize != 25 {
+		t.Errorf("ui = %v, %v", config.StatusTimeout, config.PageSize)
+	}
+	if config.Sort.DirsFirst {
+		t.Error("sort.dirs_first should be false")
+	}
+	if config.Colors.Directory != tcell.NewHexColor(0xff8800) {
+		t.Errorf("colors.directory = %v", config.Colors.Directory)
+	}
+
+	app := &App{config: config}
+	for _, name := range []string{"flake.nix", "main.hcl", "main.go"} {
+		if !app.isTextFile(FileItem{Name: name, Path: "/nonexistent/" + name}) {
+			t.Errorf("%s should be a text file with this config", name)
+		}
+	}
+	if len(config.Syntax) != 1 || config.Syntax[0].Extensions[0] != ".nix" {
+		t.Errorf("syntax = %v", config.Syntax)
+	}
+}
+
+func TestParseConfigColumns(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[ui]
+details = true
+columns = ["owner", "size"]
+time_format = "absolute"
+`)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if !config.Details || !slices.Equal(config.Columns, []string{ColumnOwner, ColumnSize}) || config.TimeFormat != TimeAbsolute {
+		t.Errorf("ui = %v, %v, %q", config.Details, config.Columns, config.TimeFormat)
+	}
+
+	if _, err := parseConfig("bad.toml", "[ui]\ncolumns = [\"size\", \"inode\"]\n"); err == nil || !strings.Contains(err.Error(), "inode") {
+		t.Errorf("unknown column error = %v", err)
+	}
+}
+
+func TestParseConfigFilter(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[files]
+show_hidden = false
+gitignore = true
+ignore = ["*.pyc", "node_modules"]
+`)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if config.Filter.ShowHidden || !config.Filter.GitIgnore || len(config.Filter.Ignore) != 2 {
+		t.Errorf("filter = %+v", config.Filter)
+	}
+	if _, err := parseConfig("bad.toml", "[files]\nignore = [\"[z-a\"]\n"); err == nil {
+		t.Error("a malformed ignore glob should be rejected")
+	}
+}
+
+func TestParseConfigReportsAllProblems(t *testing.T) {
+	_, err := parseConfig("bad.toml", `
+[ui]
+page_size = 0
+status_timeout = "soon"
+[colors]
+directory = "blurple"
+[typo]
+x = 1
+`)
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("parseConfig() error = %v, want *ConfigError", err)
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("Problems = %v, want 4", configErr.Problems)
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	style, err := parseStyle("white on #1e3a5f bold")
+	if err != nil {
+		t.Fatalf("parseStyle error: %v", err)
+	}
+	fg, bg, attrs := style.Decompose()
+	if fg != tcell.ColorWhite || bg != tcell.NewHexColor(0x1e3a5f) || attrs&tcell.AttrBold == 0 {
+		t.Errorf("parseStyle = %v %v %v", fg, bg, attrs)
+	}
+
+	for _, spec := range []string{"", "blue red", "white on", "blurple"} {
+		if _, err := parseStyle(spec); err == nil {
+			t.Errorf("parseStyle(%q) should fail", spec)
+		}
+	}
+}
+
+func TestConfigThemes(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[ui]
+theme = "paper"
+[themes.paper]
+inherit = "light"
+directory = "#005f87 bold"
+[colors]
+marked = "red"
+`)
+	if err != nil {
+		t.Fatalf("parseConfig error: %v", err)
+	}
+	light := builtinTheme("light")
+	if config.Theme.Directory != tcell.StyleDefault.Foreground(tcell.NewHexColor(0x005f87)).Bold(true) {
+		t.Errorf("directory = %v", config.Theme.Directory)
+	}
+	if config.Theme.Selected != light.Selected {
+		t.Error("paper should inherit selected from light")
+	}
+	if fg, _, _ := config.Theme.Marked.Decompose(); fg != tcell.ColorRed {
+		t.Errorf("[colors] marked should override the theme, got %v", fg)
+	}
+
+	_, err = parseConfig("test.toml", `
+[ui]
+theme = "loop"
+[themes.loop]
+inherit = "loop"
+[themes.broken]
+inherit = "nope"
+sparkle = "red"
+file = "blurple"
+`)
+	if err == nil {
+		t.Fatal("expected theme errors")
+	}
+	for _, want := range []string{"themes.loop: inherits from itself", "themes.broken.sparkle: unknown setting", "themes.broken.file: unknown color"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %q", err, want)
+		}
+	}
+}
+
+func TestConfigEnvironmentColors(t *testing.T) {
+	config := DefaultConfig()
+	config.applyEnvironment("1", "di=01;34")
+	if *config.Theme != *builtinTheme("mono") {
+		t.Error("NO_COLOR should select the mono theme")
+	}
+
+	config = DefaultConfig()
+	config.ThemeName = "dark"
+	config.applyEnvironment("1", "di=01;34")
+	if config.Theme.LSColors == nil {
+		t.Error("an explicit theme should win over NO_COLOR")
+	}
+
+	config = DefaultConfig()
+	config.UseLSColors = false
+	config.applyEnvironment("", "di=01;34")
+	if config.Theme.LSColors != nil {
+		t.Error("ui.ls_colors = false should ignore LS_COLORS")
+	}
+}
+
+func TestLSColors(t *testing.T) {
+	ls := parseLSColors("di=01;34:ex=01;32:fi=0:*.tar=31:*.TAR.GZ=38;5;208:*README=38;2;255;128;0:bad=x")
+	tests := []struct {
+		item FileItem
+		want tcell.Style
+	}{
+		{FileItem{Name: "src", IsDir: true, Mode: os.ModeDir}, tcell.StyleDefault.Foreground(tcell.ColorNavy).Bold(true)},
+		{FileItem{Name: "run.sh", Mode: 0755}, tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)},
+		{FileItem{Name: "a.tar", Mode: 0644}, tcell.StyleDefault.Foreground(tcell.ColorMaroon)},
+		{FileItem{Name: "b.tar.gz", Mode: 0644}, tcell.StyleDefault.Foreground(tcell.PaletteColor(208))},
+		{FileItem{Name: "README", Mode: 0644}, tcell.StyleDefault.Foreground(tcell.NewRGBColor(255, 128, 0))},
+		{FileItem{Name: "notes.txt", Mode: 0644}, tcell.StyleDefault},
+	}
+	for _, tt := range tests {
+		got, ok := ls.styleFor(tt.item)
+		if !ok || got != tt.want {
+			t.Errorf("styleFor(%s) = %v, %v; want %v", tt.item.Name, got, ok, tt.want)
+		}
+	}
+
+	if parseLSColors("") != nil {
+		t.Error("empty LS_COLORS should parse to nil")
+	}
+}
+
+func TestLoadConfigMissingFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_DIRS", t.TempDir())
+
+	config, err := LoadConfig("")
+	if err != nil || config.PageSize != 10 {
+		t.Errorf("Missing default config should give defaults, got %v, %v", config, err)
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "nope.toml")); err == nil {
+		t.Error("Missing explicit config should be an error")
+	}
+}
+
+func TestNavigatorSortOptions(t *testing.T) {
+	testDir := t.TempDir()
+	createTestFile(t, testDir, "b.txt", "")
+	createTestFile(t, testDir, "A.txt", "")
+	createTestDir(t, testDir, "c")
+
+	config := DefaultConfig()
+	config.Sort = SortOptions{DirsFirst: false, CaseSensitive: true, Reverse: true}
+	nav := NewNavigatorWithConfig(testDir, config)
+
+	var names []string
+	for _, item := range nav.items {
+		names = append(names, item.Name)
+	}
+	want := []string{"c", "b.txt", "A.txt"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("sorted = %v, want %v", names, want)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	sorted := []string{"file1", "file2", "file02", "file10", "file10a", "file10b", "filea"}
+	for i := 0; i+1 < len(sorted); i++ {
+		if naturalCompare(sorted[i], sorted[i+1]) >= 0 {
+			t.Errorf("%s should sort before %s", sorted[i], sorted[i+1])
+		}
+		if naturalCompare(sorted[i+1], sorted[i]) <= 0 {
+			t.Errorf("%s should sort after %s", sorted[i+1], sorted[i])
+		}
+	}
+}
+
+func TestNavigatorSortModes(t *testing.T) {
+	testDir := t.TempDir()
+	createTestFile(t, testDir, "v10.txt", "xx")
+	createTestFile(t, testDir, "v9.md", "xxxx")
+	createTestFile(t, testDir, "v1.go", "x")
+	createTestDir(t, testDir, "sub")
+	now := time.Now()
+	for i, name := range []string{"v9.md", "v10.txt", "v1.go", "sub"} {
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(filepath.Join(testDir, name), mtime, mtime)
+	}
+
+	nav := NewNavigator(testDir)
+	names := func() string {
+		var names []string
+		for _, item := range nav.items {
+			names = append(names, item.Name)
+		}
+		return fmt.Sprint(names)
+	}
+
+	tests := []struct {
+		options SortOptions
+		want    string
+	}{
+		{SortOptions{By: SortName, DirsFirst: true}, "[sub v1.go v10.txt v9.md]"},
+		{SortOptions{By: SortNatural, DirsFirst: true}, "[sub v1.go v9.md v10.txt]"},
+		{SortOptions{By: SortSize, DirsFirst: true}, "[sub v9.md v10.txt v1.go]"},
+		{SortOptions{By: SortSize, DirsFirst: true, Reverse: true}, "[sub v1.go v10.txt v9.md]"},
+		{SortOptions{By: SortTime, DirsFirst: false}, "[sub v1.go v10.txt v9.md]"},
+		{SortOptions{By: SortExtension, DirsFirst: true}, "[sub v1.go v9.md v10.txt]"},
+		{SortOptions{By: SortType}, "[sub v1.go v10.txt v9.md]"},
+	}
+	for _, tt := range tests {
+		nav.setSort(tt.options)
+		if got := names(); got != tt.want {
+			t.Errorf("sort %+v = %s, want %s", tt.options, got, tt.want)
+		}
+	}
+
+	// Re-sorting keeps the same item selected
+	nav.setSort(SortOptions{By: SortName})
+	nav.selectedIdx = 3
+	selected := nav.getSelectedItem().Name
+	nav.setSort(SortOptions{By: SortName, Reverse: true})
+	if nav.getSelectedItem().Name != selected {
+		t.Errorf("selection moved from %s to %s", selected, nav.getSelectedItem().Name)
+	}
+}
+
+func TestSortMemory(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sort.json")
+	fallback := SortOptions{By: SortName, DirsFirst: true}
+	size := SortOptions{By: SortSize, DirsFirst: true}
+
+	memory := LoadSortMemory(statePath, RememberDirectory, fallback)
+	if err := memory.set("/a", size); err != nil {
+		t.Fatalf("set error: %v", err)
+	}
+	reloaded := LoadSortMemory(statePath, RememberDirectory, fallback)
+	if reloaded.get("/a") != size || reloaded.get("/b") != fallback {
+		t.Errorf("per-directory sorts = %+v, %+v", reloaded.get("/a"), reloaded.get("/b"))
+	}
+
+	global := LoadSortMemory(statePath, RememberGlobal, fallback)
+	global.set("/a", size)
+	if LoadSortMemory(statePath, RememberGlobal, fallback).get("/b") != size {
+		t.Error("a global sort should apply to every directory after reload")
+	}
+
+	none := LoadSortMemory(filepath.Join(t.TempDir(), "none.json"), RememberNone, fallback)
+	none.set("/a", size)
+	if _, err := os.Stat(none.path); !os.IsNotExist(err) {
+		t.Error("remember = none should not write state")
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		10 * time.Second:      "just now",
+		5 * time.Minute:       "5m ago",
+		3 * time.Hour:         "3h ago",
+		49 * time.Hour:        "2d ago",
+		100 * 24 * time.Hour:  "3mo ago",
+		800 * 24 * time.Hour:  "2y ago",
+	}
+	for age, want := range tests {
+		if got := formatAge(now.Add(-age), now); got != want {
+			t.Errorf("formatAge(-%v) = %q, want %q", age, got, want)
+		}
+	}
+}
+
+func TestDetailColumns(t *testing.T) {
+	items := []FileItem{{Name: "a.txt", Size: 2048, Mode: 0640, UID: -1}}
+	app := &App{details: true}
+
+	columns, total := app.detailColumns(items, 100)
+	if len(columns) != 4 {
+		t.Fatalf("wide terminal columns = %v, want all 4", columns)
+	}
+	if 100-total < minNameWidth {
+		t.Errorf("columns take %d of 100, leaving too little for names", total)
+	}
+
+	columns, _ = app.detailColumns(items, 50)
+	if len(columns) != 2 || columns[0].name != ColumnSize || columns[1].name != ColumnTime {
+		t.Errorf("narrow terminal columns = %v, want size and mtime", columns)
+	}
+	if columns, _ := app.detailColumns(items, 25); len(columns) != 0 {
+		t.Errorf("very narrow terminal columns = %v, want none", columns)
+	}
+
+	if got := app.columnText(ColumnPermissions, items[0], time.Now()); got != "-rw-r-----" {
+		t.Errorf("permissions = %q", got)
+	}
+	if got := app.columnText(ColumnSize, items[0], time.Now()); got != "2.0 KB" {
+		t.Errorf("size = %q", got)
+	}
+	if got := app.columnText(ColumnOwner, items[0], time.Now()); got != "-" {
+		t.Errorf("unknown owner = %q", got)
+	}
+
+	app.details = false
+	if columns, _ := app.detailColumns(items, 100); columns != nil {
+		t.Error("details off should show no columns")
+	}
+}
+
+func TestDrawFileListDetails(t *testing.T) {
+	testDir := t.TempDir()
+	createTestFile(t, testDir, "notes.txt", "hello")
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(80, 5)
+
+	app := &App{screen: screen, navigator: NewNavigator(testDir), details: true, width: 80, height: 5}
+	app.drawFileList()
+	screen.Show()
+
+	cells, width, _ := screen.GetContents()
+	var row []rune
+	for x := 0; x < width; x++ {
+		row = append(row, cells[width+x].Runes...)
+	}
+	line := string(row)
+	if !strings.Contains(line, "notes.txt") || !strings.Contains(line, "5 B") || !strings.Contains(line, "-rw") {
+		t.Errorf("row = %q, want name, size and permissions", line)
+	}
+	if !strings.HasSuffix(strings.TrimRight(line, " "), ownerText(app.navigator.items[0])) {
+		t.Errorf("row = %q should end with the right-aligned owner", line)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "/repo/sub/debug.log", false, true},
+		{"build/", "/repo/build", true, true},
+		{"build/", "/repo/build", false, false},
+		{"/out", "/repo/out", true, true},
+		{"/out", "/repo/sub/out", true, false},
+		{"docs/*.md", "/repo/docs/a.md", false, true},
+		{"**/gen", "/repo/a/b/gen", true, true},
+		{"a/**/b.txt", "/repo/a/b.txt", false, true},
+		{"a/**/b.txt", "/repo/a/x/y/b.txt", false, true},
+	}
+	for _, tt := range tests {
+		rule, ok := parseIgnoreRule("/repo", tt.pattern)
+		if !ok {
+			t.Fatalf("parseIgnoreRule(%q) skipped the rule", tt.pattern)
+		}
+		item := FileItem{Name: filepath.Base(tt.path), Path: tt.path, IsDir: tt.isDir}
+		if got := rule.matches(item); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment"} {
+		if _, ok := parseIgnoreRule("/repo", line); ok {
+			t.Errorf("parseIgnoreRule(%q) should be skipped", line)
+		}
+	}
+}
+
+func TestLoadDirectoryFilters(t *testing.T) {
+	repo := t.TempDir()
+	os.Mkdir(filepath.Join(repo, ".git"), 0755)
+	createTestFile(t, repo, ".gitignore", "*.log\n!keep.log\nsub/vendor/\n")
+	sub := filepath.Join(repo, "sub")
+	os.Mkdir(sub, 0755)
+	os.Mkdir(filepath.Join(sub, "vendor"), 0755)
+	createTestFile(t, sub, ".ignore", "*.tmp\n")
+	for _, name := range []string{"main.go", "debug.log", "keep.log", "scratch.tmp", ".env", "notes.bak"} {
+		createTestFile(t, sub, name, "x")
+	}
+
+	config := DefaultConfig()
+	nav := NewNavigatorWithConfig(sub, config)
+	if len(nav.items) != 8 || nav.hiddenCount != 0 {
+		t.Errorf("default listing has %d items, %d hidden; want everything", len(nav.items), nav.hiddenCount)
+	}
+
+	config.Filter = FilterOptions{GitIgnore: true, Ignore: []string{"*.bak"}}
+	nav = NewNavigatorWithConfig(sub, config)
+	var names []string
+	for _, item := range nav.items {
+		names = append(names, item.Name)
+	}
+	if want := []string{"keep.log", "main.go"}; !slices.Equal(names, want) {
+		t.Errorf("filtered listing = %v, want %v", names, want)
+	}
+	if nav.hiddenCount != 6 {
+		t.Errorf("hiddenCount = %d, want 6", nav.hiddenCount)
+	}
+
+	// Outside a repository .gitignore does not apply, .ignore still does
+	os.Remove(filepath.Join(repo, ".git"))
+	nav = NewNavigatorWithConfig(sub, config)
+	if len(nav.items) != 4 {
+		t.Errorf("without a repository got %d items, want debug.log, keep.log, main.go and vendor", len(nav.items))
+	}
+}
+
+func TestToggleHiddenKeepsSelection(t *testing.T) {
+	testDir := t.TempDir()
+	createTestFile(t, testDir, ".a", "x")
+	createTestFile(t, testDir, "b", "x")
+	createTestFile(t, testDir, "c", "x")
+
+	app := &App{navigator: NewNavigator(testDir), statusBar: &StatusBar{}}
+	app.navigator.selectedIdx = 2
+	app.toggleHidden()
+	if len(app.navigator.filteredItems) != 2 || app.navigator.getSelectedItem().Name != "c" {
+		t.Errorf("after hiding: %d items, selected %v", len(app.navigator.filteredItems), app.navigator.getSelectedItem())
+	}
+	app.toggleHidden()
+	if len(app.navigator.filteredItems) != 3 || app.navigator.getSelectedItem().Name != "c" {
+		t.Errorf("after showing: %d items, selected %v", len(app.navigator.filteredItems), app.navigator.getSelectedItem())
+	}
+}
+
+func TestWalkTree(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src/app", "src/app/deep", "build", ".git/objects"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	createTestFile(t, root, ".ignore", "build/\n")
+	createTestFile(t, filepath.Join(root, "src"), "main.go", "x")
+	createTestFile(t, filepath.Join(root, "src/app"), "app.go", "x")
+	createTestFile(t, filepath.Join(root, "src/app/deep"), "deep.go", "x")
+	createTestFile(t, filepath.Join(root, "build"), "out.bin", "x")
+
+	walk := func(options FilterOptions, maxDepth, maxFiles int) ([]string, bool) {
+		var mu sync.Mutex
+		var found []string
+		truncated := walkTree(root, newItemFilter(root, options), maxDepth, maxFiles, make(chan struct{}), func(batch []findEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, entry := range batch {
+				found = append(found, entry.rel)
+			}
+		})
+		slices.Sort(found)
+		return found, truncated
+	}
+
+	found, truncated := walk(FilterOptions{GitIgnore: true}, 20, 1000)
+	want := []string{"src", "src/app", "src/app/app.go", "src/app/deep", "src/app/deep/deep.go", "src/main.go"}
+	if !slices.Equal(found, want) || truncated {
+		t.Errorf("walk = %v (truncated %v), want %v", found, truncated, want)
+	}
+
+	found, _ = walk(FilterOptions{ShowHidden: true}, 2, 1000)
+	want = []string{".ignore", "build", "build/out.bin", "src", "src/app", "src/main.go"}
+	if !slices.Equal(found, want) {
+		t.Errorf("depth-limited walk = %v, want %v", found, want)
+	}
+
+	if found, truncated := walk(FilterOptions{}, 20, 3); len(found) != 3 || !truncated {
+		t.Errorf("capped walk = %v (truncated %v), want 3 entries and truncated", found, truncated)
+	}
+
+	// Many more directories than workers are all read
+	wide := t.TempDir()
+	for i := 0; i < 5*findWorkers; i++ {
+		createTestFile(t, createTestDir(t, wide, fmt.Sprintf("d%d", i)), "f", "x")
+	}
+	var count atomic.Int64
+	walkTree(wide, newItemFilter(wide, FilterOptions{}), 20, 1000, make(chan struct{}), func(batch []findEntry) {
+		count.Add(int64(len(batch)))
+	})
+	if count.Load() != 10*findWorkers {
+		t.Errorf("wide walk found %d entries, want %d", count.Load(), 10*findWorkers)
+	}
+
+	// A stopped walk still returns
+	stopped := make(chan struct{})
+	close(stopped)
+	walkTree(wide, newItemFilter(wide, FilterOptions{}), 20, 1000, stopped, func(batch []findEntry) {
+		t.Errorf("stopped walk emitted %v", batch)
+	})
+}
+
+func TestFinderSelection(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "docs/guide"), 0755)
+	createTestFile(t, filepath.Join(root, "docs/guide"), "install.md", "x")
+
+	f := &Finder{root: root, done: make(chan struct{})}
+	f.add([]findEntry{{rel: "docs", isDir: true}, {rel: "readme.md"}})
+	f.setQuery("inst")
+	if len(f.matches) != 0 {
+		t.Errorf("matches = %v before install.md was found", f.matches)
+	}
+	f.add([]findEntry{{rel: "docs/guide", isDir: true}, {rel: "docs/guide/install.md"}})
+	if len(f.matches) != 1 || f.selected().rel != "docs/guide/install.md" {
+		t.Fatalf("matches = %v, want docs/guide/install.md", f.matches)
+	}
+
+	app := &App{navigator: NewNavigator(root), statusBar: &StatusBar{}, finder: f}
+	app.selectFindResult()
+	if app.finder != nil {
+		t.Error("finder should close after selecting a result")
+	}
+	if app.navigator.currentPath != filepath.Join(root, "docs/guide") || app.navigator.getSelectedItem().Name != "install.md" {
+		t.Errorf("navigator at %s with %v selected", app.navigator.currentPath, app.navigator.getSelectedItem())
+	}
+}
+
+func TestFinderMatchesBatches(t *testing.T) {
+	var entries []findEntry
+	for i := 0; i < 300; i++ {
+		entries = append(entries, findEntry{rel: fmt.Sprintf("src/pkg%d/main_%d.go", i%7, i)})
+	}
+	whole := &Finder{}
+	whole.add(entries)
+	whole.setQuery("mn1")
+
+	// Streaming in batches with the query already typed ranks the same
+	streamed := &Finder{}
+	streamed.setQuery("mn1")
+	for start := 0; start < len(entries); start += 64 {
+		streamed.add(entries[start:min(start+64, len(entries))])
+	}
+	if len(streamed.matches) != len(whole.matches) || len(streamed.matches) == 0 {
+		t.Fatalf("streamed %d matches, whole %d", len(streamed.matches), len(whole.matches))
+	}
+	if !slices.IsSortedFunc(streamed.scores, func(a, b int) int { return b - a }) {
+		t.Errorf("streamed scores are not ranked best first: %v", streamed.scores)
+	}
+	if !slices.Equal(streamed.scores, whole.scores) {
+		t.Errorf("streamed scores %v, whole %v", streamed.scores, whole.scores)
+	}
+	rels := func(matches findEntries) []string {
+		var out []string
+		for _, entry := range matches {
+			out = append(out, entry.rel)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if !slices.Equal(rels(streamed.matches), rels(whole.matches)) {
+		t.Error("streamed and whole matching found different entries")
+	}
+}
+
+func TestGrepMatcher(t *testing.T) {
+	tests := []struct {
+		query string
+		regex bool
+		line  string
+		want  bool
+	}{
+		{"todo", false, "// TODO: fix", true}, // lowercase ignores case
+		{"TODO", false, "// todo: fix", false},
+		{"a.c", false, "abc", false},
+		{"a.c", true, "abc", true},
+		{`^func \w+\(`, true, "func main() {", true},
+	}
+	for _, tt := range tests {
+		match, err := grepMatcher(tt.query, tt.regex)
+		if err != nil {
+			t.Fatalf("grepMatcher(%q) error = %v", tt.query, err)
+		}
+		if got := match(tt.line); got != tt.want {
+			t.Errorf("%q (regex %v) on %q = %v, want %v", tt.query, tt.regex, tt.line, got, tt.want)
+		}
+	}
+	if _, err := grepMatcher("(unclosed", true); err == nil {
+		t.Error("a malformed regex should fail")
+	}
+}
+
+func TestGrepTree(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "src"), 0755)
+	createTestFile(t, root, "notes.txt", "alpha\nneedle one\nbeta\n")
+	createTestFile(t, filepath.Join(root, "src"), "main.go", "package main\n\n// needle two\n")
+	createTestFile(t, root, "image.bin", "needle\x00\x01\x02\xff\xfe")
+
+	app := &App{}
+	grep := func(maxMatches int) ([]grepMatch, bool) {
+		match, _ := grepMatcher("needle", false)
+		var mu sync.Mutex
+		var found []grepMatch
+		truncated := grepTree(root, newItemFilter(root, FilterOptions{}), 20, 1000, maxMatches, app.detectTextContent, match, make(chan struct{}), func(batch []grepMatch) {
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, batch...)
+		})
+		slices.SortFunc(found, compareGrepMatches)
+		return found, truncated
+	}
+
+	found, truncated := grep(100)
+	want := []grepMatch{{rel: "notes.txt", line: 2, text: "needle one"}, {rel: "src/main.go", line: 3, text: "// needle two"}}
+	if !slices.Equal(found, want) || truncated {
+		t.Errorf("grep = %v (truncated %v), want %v", found, truncated, want)
+	}
+	if found, truncated := grep(1); len(found) != 1 || !truncated {
+		t.Errorf("capped grep = %v (truncated %v), want 1 match and truncated", found, truncated)
+	}
+}
+
+// makeFIFO creates a named pipe at path, skipping the test where that
+// isn't possible.
+func makeFIFO(t *testing.T, path string) {
+	t.Helper()
+	if err := exec.Command("mkfifo", path).Run(); err != nil {
+		t.Skipf("cannot create a named pipe: %v", err)
+	}
+}
+
+func TestGrepSkipsSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, root, "notes.txt", "needle\n")
+	makeFIFO(t, filepath.Join(root, "pipe"))
+	os.Symlink("pipe", filepath.Join(root, "pipe-link"))
+	os.Symlink("notes.txt", filepath.Join(root, "notes-link"))
+
+	app := &App{}
+	match, _ := grepMatcher("needle", false)
+	finished := make(chan []grepMatch)
+	go func() {
+		var mu sync.Mutex
+		var found []grepMatch
+		grepTree(root, newItemFilter(root, FilterOptions{}), 20, 1000, 100, app.detectTextContent, match, make(chan struct{}), func(batch []grepMatch) {
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, batch...)
+		})
+		finished <- found
+	}()
+	select {
+	case found := <-finished:
+		slices.SortFunc(found, compareGrepMatches)
+		if len(found) != 2 || found[0].rel != "notes-link" || found[1].rel != "notes.txt" {
+			t.Errorf("grep = %v, want the file and the link to it", found)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("grep blocked on a named pipe")
+	}
+}
+
+func TestGrepSnippetsKeepWholeRunes(t *testing.T) {
+	if got := truncateRunes("héllo", 2); got != "hé" {
+		t.Errorf("truncateRunes = %q, want %q", got, "hé")
+	}
+	root := t.TempDir()
+	line := "needle " + strings.Repeat("é", maxSnippetLength)
+	createTestFile(t, root, "accents.txt", line+"\n")
+	match, _ := grepMatcher("needle", false)
+	found := grepFile(filepath.Join(root, "accents.txt"), "accents.txt", match, make(chan struct{}))
+	if len(found) != 1 || !utf8.ValidString(found[0].text) || utf8.RuneCountInString(found[0].text) != maxSnippetLength {
+		t.Fatalf("snippet = %q, want %d whole runes", found, maxSnippetLength)
+	}
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(30, 5)
+	app := &App{screen: screen, width: 30, height: 5, grep: &Grep{root: root, query: "needle", matches: found}}
+	app.drawGrep()
+	screen.Show()
+	cells, width, _ := screen.GetContents()
+	var row []rune
+	for x := 0; x < width; x++ {
+		row = append(row, cells[width+x].Runes...)
+	}
+	if got := string(row); !strings.HasPrefix(got, "> accents.txt:1: needle éé") || strings.ContainsRune(got, utf8.RuneError) {
+		t.Errorf("row = %q, want the snippet cut between runes", got)
+	}
+}
+
+func TestGrepKeepsSelectionWhileStreaming(t *testing.T) {
+	g := &Grep{}
+	g.add([]grepMatch{{rel: "b.go", line: 4}})
+	g.add([]grepMatch{{rel: "a.go", line: 9}, {rel: "b.go", line: 1}})
+	if g.selected().rel != "b.go" || g.selected().line != 4 {
+		t.Errorf("selected = %v, want b.go:4", g.selected())
+	}
+	if g.matches[0].rel != "a.go" || g.matches[1].line != 1 {
+		t.Errorf("matches = %v, want path and line order", g.matches)
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	if got := editorCommand([]string{"/usr/bin/nvim"}, nil, 12, "a.go"); !slices.Equal(got, []string{"/usr/bin/nvim", "+12", "a.go"}) {
+		t.Errorf("nvim command = %v", got)
+	}
+	if got := editorCommand([]string{"gedit"}, nil, 12, "a.go"); !slices.Equal(got, []string{"gedit", "a.go"}) {
+		t.Errorf("gedit command = %v", got)
+	}
+	if got := editorCommand([]string{"vim"}, nil, 0, "a.go", "b.go"); !slices.Equal(got, []string{"vim", "a.go", "b.go"}) {
+		t.Errorf("vim command without line = %v", got)
+	}
+	if got := editorCommand([]string{"code", "--wait"}, nil, 7, "a.go"); !slices.Equal(got, []string{"code", "--wait", "--goto", "a.go:7"}) {
+		t.Errorf("code command = %v", got)
+	}
+	// Configured templates win over the defaults and reach unknown editors
+	templates := map[string]string{"vim": "-c {line}", "ed": "-l{line} -- {file}"}
+	if got := editorCommand([]string{"vim"}, templates, 3, "a.go"); !slices.Equal(got, []string{"vim", "-c", "3", "a.go"}) {
+		t.Errorf("templated vim command = %v", got)
+	}
+	if got := editorCommand([]string{"ed"}, templates, 3, "a b.go"); !slices.Equal(got, []string{"ed", "-l3", "--", "a b.go"}) {
+		t.Errorf("templated ed command = %v", got)
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"vim", []string{"vim"}},
+		{"  code   --wait ", []string{"code", "--wait"}},
+		{"emacsclient -t -a ''", []string{"emacsclient", "-t", "-a", ""}},
+		{`"/Applications/Sublime Text.app/bin/subl" -w`, []string{"/Applications/Sublime Text.app/bin/subl", "-w"}},
+		{`my\ editor --title="a \"b\"" 'it''s'`, []string{"my editor", `--title=a "b"`, "its"}},
+	}
+	for _, tt := range tests {
+		got, err := shellWords(tt.input)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("shellWords(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{`vim "unclosed`, "vim 'unclosed"} {
+		if _, err := shellWords(bad); err == nil {
+			t.Errorf("shellWords(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPickEditor(t *testing.T) {
+	tests := []struct {
+		visual, editor, configured string
+		want                       []string
+	}{
+		{"nvim", "nano", "micro", []string{"nvim"}},
+		{"", "code --wait", "micro", []string{"code", "--wait"}},
+		{" ", "", "micro -autosave 1", []string{"micro", "-autosave", "1"}},
+		{"", "", "", []string{"vi"}},
+	}
+	for _, tt := range tests {
+		if got, err := pickEditor(tt.visual, tt.editor, tt.configured); err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("pickEditor(%q, %q, %q) = %q, %v; want %q", tt.visual, tt.editor, tt.configured, got, err, tt.want)
+		}
+	}
+	if _, err := pickEditor("", `vim "x`, ""); err == nil || !strings.Contains(err.Error(), "$EDITOR") {
+		t.Errorf("a malformed $EDITOR should name the variable, got %v", err)
+	}
+
+	config, err := parseConfig("test.toml", "[editor]\ncommand = \"hx\"\n[editor.line_args]\nmyed = \"--at {line}\"\n")
+	if err != nil || config.Editor != "hx" || config.EditorLines["myed"] != "--at {line}" {
+		t.Errorf("editor config = %q, %v (err %v)", config.Editor, config.EditorLines, err)
+	}
+	if _, err := parseConfig("bad.toml", "[editor.line_args]\nmyed = \"--at\"\n"); err == nil {
+		t.Error("a line template without {line} should be rejected")
+	}
+}
+
+func TestBookmarksPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookmarks.json")
+	b := LoadBookmarks(path)
+	if err := b.add(" api ", "/src/api"); err != nil {
+		t.Fatal(err)
+	}
+	b.add("web", "/src/web")
+	b.setMark('a', "/tmp")
+	if err := b.add("  ", "/x"); err == nil {
+		t.Error("an empty bookmark name should be rejected")
+	}
+
+	loaded := LoadBookmarks(path)
+	if dir, ok := loaded.mark('a'); !ok || dir != "/tmp" {
+		t.Errorf("mark a = %q, %v", dir, ok)
+	}
+	items := loaded.pickerItems()
+	if len(items) != 2 || items[0] != (PickerItem{Label: "api", Detail: "/src/api"}) {
+		t.Errorf("pickerItems = %v", items)
+	}
+	loaded.remove("api")
+	if items := LoadBookmarks(path).pickerItems(); len(items) != 1 || items[0].Label != "web" {
+		t.Errorf("after remove: %v", items)
+	}
+}
+
+func TestMarkKeys(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	os.Mkdir(sub, 0755)
+	app := &App{navigator: NewNavigator(sub), statusBar: &StatusBar{}, bookmarks: LoadBookmarks(filepath.Join(root, "bookmarks.json"))}
+	press := func(r rune) { app.handleKey(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)) }
+
+	press('m')
+	press('w')
+	app.navigator.changeDirectory(root)
+	press('\'')
+	press('w')
+	if app.navigator.currentPath != sub {
+		t.Errorf("after 'w at %s, want %s", app.navigator.currentPath, sub)
+	}
+
+	press('\'')
+	press('q')
+	if !app.statusBar.isError || app.markPrompt != MarkNone {
+		t.Error("jumping to an unset mark should report an error and end the prompt")
+	}
+	app.handleKey(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if app.keyContext() != ContextNormal || app.navigator.currentPath != sub {
+		t.Error("escape should not be swallowed or move anywhere")
+	}
+}
+
+func TestBookmarkPicker(t *testing.T) {
+	root := t.TempDir()
+	api := filepath.Join(root, "api")
+	os.Mkdir(api, 0755)
+	app := &App{navigator: NewNavigator(root), statusBar: &StatusBar{}, bookmarks: LoadBookmarks(filepath.Join(root, "bookmarks.json"))}
+	app.bookmarks.add("backend", api)
+	app.bookmarks.add("home", root)
+
+	app.showBookmarks()
+	for _, r := range "bkend" {
+		app.handleKey(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	if len(app.picker.matches) != 1 || app.picker.selected().Label != "backend" {
+		t.Fatalf("matches for bkend = %v", app.picker.matches)
+	}
+	app.dispatchKey(ContextPicker, "enter")
+	if app.picker != nil || app.navigator.currentPath != api {
+		t.Errorf("picker open %v, at %s; want closed at %s", app.picker != nil, app.navigator.currentPath, api)
+	}
+
+	app.showBookmarks()
+	app.dispatchKey(ContextPicker, "ctrl+d")
+	if len(app.picker.items) != 1 || app.picker.selected().Label != "home" {
+		t.Errorf("after removing: %v", app.picker.items)
+	}
+}
+
+func TestBackForward(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		os.Mkdir(filepath.Join(root, dir), 0755)
+	}
+	for _, name := range []string{"1", "2", "3"} {
+		createTestFile(t, filepath.Join(root, "a"), name, "x")
+	}
+	nav := NewNavigator(root)
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+
+	nav.changeDirectory(a)
+	nav.selectedIdx = 2
+	nav.changeDirectory(b)
+	if moved, err := nav.goBack(); !moved || err != nil || nav.currentPath != a {
+		t.Fatalf("back = %v, %v, at %s; want %s", moved, err, nav.currentPath, a)
+	}
+	if nav.getSelectedItem().Name != "3" {
+		t.Errorf("back should restore the selection, got %v", nav.getSelectedItem())
+	}
+	nav.goBack()
+	if nav.currentPath != root {
+		t.Errorf("second back at %s, want %s", nav.currentPath, root)
+	}
+	if moved, _ := nav.goBack(); moved {
+		t.Error("back with an empty stack should not move")
+	}
+	nav.goForward()
+	nav.goForward()
+	if nav.currentPath != b {
+		t.Errorf("forward twice at %s, want %s", nav.currentPath, b)
+	}
+
+	// A new jump drops the forward stack
+	nav.goBack()
+	nav.changeDirectory(root)
+	if moved, _ := nav.goForward(); moved {
+		t.Error("forward should be empty after navigating elsewhere")
+	}
+
+	// Directories that disappeared are skipped with an error
+	nav.changeDirectory(b)
+	nav.changeDirectory(a)
+	os.Remove(b)
+	if _, err := nav.goBack(); err == nil || nav.currentPath != a {
+		t.Errorf("back to a removed directory: err %v, at %s", err, nav.currentPath)
+	}
+	if len(nav.back) == 0 || nav.back[len(nav.back)-1] == b {
+		t.Errorf("removed directory should be dropped from %v", nav.back)
+	}
+}
+
+func TestGoUpSelectsChildAndEnterRestores(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	os.Mkdir(sub, 0755)
+	createTestFile(t, root, "a", "x")
+	createTestFile(t, sub, "x", "x")
+	createTestFile(t, sub, "y", "x")
+
+	nav := NewNavigator(root)
+	nav.selectPath(sub)
+	nav.enterDirectory()
+	nav.selectedIdx = 1
+	nav.goUp()
+	if nav.getSelectedItem().Path != sub {
+		t.Errorf("goUp selected %v, want sub", nav.getSelectedItem())
+	}
+	nav.enterDirectory()
+	if nav.getSelectedItem().Name != "y" {
+		t.Errorf("re-entering selected %v, want y", nav.getSelectedItem())
+	}
+}
+
+func TestRecentDirsFrecency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recent.json")
+	now := time.Now()
+	recent := LoadRecentDirs(path)
+	for i := 0; i < 10; i++ {
+		recent.visit("/often-but-old", now.Add(-30*24*time.Hour))
+	}
+	recent.visit("/just-now", now)
+	recent.visit("/yesterday", now.Add(-20*time.Hour))
+	recent.visit("/yesterday", now.Add(-20*time.Hour))
+	// Visits are only written when saved
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("visits were written before saving (%v)", err)
+	}
+	if err := recent.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 old visits score 2.5, two from yesterday 4, one just now 4 but newer
+	want := []string{"/just-now", "/yesterday", "/often-but-old"}
+	if got := LoadRecentDirs(path).ranked(now); !slices.Equal(got, want) {
+		t.Errorf("ranked = %v, want %v", got, want)
+	}
+
+	recent.forget("/yesterday")
+	if got := LoadRecentDirs(path).ranked(now); len(got) != 2 {
+		t.Errorf("after forget: %v", got)
+	}
+}
+
+func TestSaveRecentDirs(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, root, "file", "x")
+	app := &App{navigator: NewNavigator(root), statusBar: &StatusBar{}}
+	// The state directory cannot be created under a file
+	app.navigator.recent = LoadRecentDirs(filepath.Join(root, "file", "recent.json"))
+	scheduled := 0
+	app.navigator.recent.changed = func() { scheduled++ }
+
+	app.navigator.changeDirectory(t.TempDir())
+	app.navigator.changeDirectory(root)
+	if scheduled != 2 {
+		t.Errorf("save scheduled %d times, want once per visit", scheduled)
+	}
+	app.saveRecentDirs()
+	if !app.statusBar.isError || !strings.Contains(app.statusBar.message, "Cannot save recent directories") {
+		t.Errorf("failed save reported as %q", app.statusBar.message)
+	}
+	app.statusBar.message, app.statusBar.isError = "", false
+	app.navigator.changeDirectory(t.TempDir())
+	app.saveRecentDirs()
+	if app.statusBar.isError {
+		t.Errorf("second failure reported again: %q", app.statusBar.message)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	t.Setenv("POWPOW_TEST_DIR", "/srv/data")
+	tests := map[string]string{
+		"~":                    home,
+		"~/src":                filepath.Join(home, "src"),
+		"$POWPOW_TEST_DIR/x":   "/srv/data/x",
+		"${POWPOW_TEST_DIR}/..": "/srv",
+		"sub/../other":         "/work/other",
+		"/etc/":                "/etc",
+	}
+	for input, want := range tests {
+		if got, err := expandPath(input, "/work"); err != nil || got != want {
+			t.Errorf("expandPath(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := expandPath("  ", "/work"); err == nil {
+		t.Error("an empty path should be an error")
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"projects", "photos", "music", ".config", "projects/powpow"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	createTestFile(t, root, "physics.txt", "x")
+	os.Symlink(filepath.Join(root, "music"), filepath.Join(root, "tunes"))
+
+	tests := []struct {
+		input      string
+		want       string
+		candidates int
+	}{
+		{"mu", "music/", 0},
+		{"p", "p", 2},         // projects and photos share only "p"; the file is skipped
+		{"pro", "projects/", 0},
+		{"projects/p", "projects/powpow/", 0},
+		{"tu", "tunes/", 0},   // symlinks to directories complete
+		{".c", ".config/", 0}, // hidden only when asked for
+		{"zzz", "zzz", 0},
+		{root + "/mus", root + "/music/", 0},
+	}
+	for _, tt := range tests {
+		got, candidates := completePath(tt.input, root)
+		if got != tt.want || len(candidates) != tt.candidates {
+			t.Errorf("completePath(%q) = %q, %v; want %q with %d candidates", tt.input, got, candidates, tt.want, tt.candidates)
+		}
+	}
+}
+
+func TestGoTo(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a/b"), 0755)
+	createTestFile(t, filepath.Join(root, "a"), "file.txt", "x")
+	app := &App{navigator: NewNavigator(root), statusBar: &StatusBar{}}
+
+	app.startGoTo()
+	for _, r := range "a/" {
+		app.handleKey(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	app.dispatchKey(ContextPopup, "tab")
+	if app.popup.inputBuffer != "a/b/" {
+		t.Errorf("completed input = %q, want a/b/", app.popup.inputBuffer)
+	}
+	app.dispatchKey(ContextPopup, "enter")
+	if app.navigator.currentPath != filepath.Join(root, "a/b") {
+		t.Errorf("at %s after go-to", app.navigator.currentPath)
+	}
+
+	app.goTo("../file.txt")
+	if app.navigator.currentPath != filepath.Join(root, "a") || app.navigator.getSelectedItem().Name != "file.txt" {
+		t.Errorf("go-to a file should select it in its directory, at %s", app.navigator.currentPath)
+	}
+
+	app.goTo("missing")
+	if !app.statusBar.isError || !strings.Contains(app.statusBar.message, "No such file") {
+		t.Errorf("missing path message = %q", app.statusBar.message)
+	}
+	if app.navigator.currentPath != filepath.Join(root, "a") {
+		t.Error("a failed go-to should stay put")
+	}
+}
+
+func TestDirWatcher(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	changes := make(chan string, 10)
+	watcher, err := NewDirWatcher(func(dir string) { changes <- dir })
+	if err != nil {
+		t.Skipf("no watcher on this system: %v", err)
+	}
+	defer watcher.close()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case dir := <-changes:
+			if dir != want {
+				t.Errorf("change reported for %s, want %s", dir, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no change reported for %s", want)
+		}
+	}
+
+	watcher.watch(first)
+	// A burst of changes is reported once
+	for i := 0; i < 5; i++ {
+		createTestFile(t, first, fmt.Sprintf("f%d", i), "x")
+	}
+	expect(first)
+	select {
+	case dir := <-changes:
+		t.Errorf("burst reported twice (%s)", dir)
+	case <-time.After(2 * watchDebounce):
+	}
+
+	watcher.watch(second)
+	createTestFile(t, first, "ignored", "x")
+	os.Mkdir(filepath.Join(second, "new"), 0755)
+	expect(second)
+
+	// A change arriving while a notification for the old directory is
+	// pending is still reported for the new one
+	watcher.watch(first)
+	watcher.changed(first)
+	watcher.watch(second)
+	watcher.changed(second)
+	expect(second)
+}
+
+func TestRefreshFromDisk(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	os.Mkdir(sub, 0755)
+	createTestFile(t, sub, "b", "x")
+	createTestFile(t, sub, "c", "x")
+	app := &App{navigator: NewNavigator(sub), statusBar: &StatusBar{}}
+	app.navigator.selectPath(filepath.Join(sub, "c"))
+
+	createTestFile(t, sub, "a", "x")
+	app.refreshFromDisk(sub)
+	if len(app.navigator.items) != 3 || app.navigator.getSelectedItem().Name != "c" {
+		t.Errorf("after refresh: %d items, selected %v", len(app.navigator.items), app.navigator.getSelectedItem())
+	}
+
+	os.RemoveAll(sub)
+	app.refreshFromDisk(sub)
+	if app.navigator.currentPath != root || !app.statusBar.isError {
+		t.Errorf("after removal at %s, want %s with an error", app.navigator.currentPath, root)
+	}
+}
+
+// backgroundLoading makes nav load in the background, queueing the results
+// for the test to apply. The returned function applies them until the
+// current load is done.
+func backgroundLoading(t *testing.T, nav *Navigator) (queue chan func(), settle func()) {
+	queue = make(chan func(), 64)
+	nav.post = func(fn func()) { queue <- fn }
+	settle = func() {
+		t.Helper()
+		for nav.loading() {
+			select {
+			case fn := <-queue:
+				fn()
+			case <-time.After(5 * time.Second):
+				t.Fatal("directory never finished loading")
+			}
+		}
+	}
+	return queue, settle
+}
+
+func TestLoadDirectoryInBackground(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	os.Mkdir(big, 0755)
+	count := 2*loadBatchSize + 500
+	for i := 0; i < count; i++ {
+		createTestFile(t, big, fmt.Sprintf("f%05d", count-i), "x")
+	}
+	nav := NewNavigator(root)
+	queue, settle := backgroundLoading(t, nav)
+
+	if err := nav.changeDirectory(big); err != nil {
+		t.Fatal(err)
+	}
+	if !nav.loading() || len(nav.items) != 0 {
+		t.Fatalf("loading = %v with %d items, want an empty listing still loading", nav.loading(), len(nav.items))
+	}
+	(<-queue)()
+	if len(nav.items) != loadBatchSize {
+		t.Errorf("after one batch %d items, want %d", len(nav.items), loadBatchSize)
+	}
+	// The file is selected once it is read, unless the cursor moved first
+	nav.selectPath(filepath.Join(big, "f00001"))
+	settle()
+	if len(nav.items) != count || nav.listed != big {
+		t.Errorf("loaded %d items of %d", len(nav.items), count)
+	}
+	if !slices.IsSortedFunc(nav.items, nav.compareItems) {
+		t.Error("items are not sorted after merging batches")
+	}
+	if selected := nav.getSelectedItem(); selected == nil || selected.Name != "f00001" {
+		t.Errorf("selected %v, want f00001", selected)
+	}
+
+	// Refreshing keeps the old listing until the new one is complete
+	createTestFile(t, big, "new", "x")
+	nav.reload()
+	(<-queue)()
+	if len(nav.items) != count {
+		t.Errorf("mid-refresh listing has %d items, want the old %d", len(nav.items), count)
+	}
+	settle()
+	if len(nav.items) != count+1 || nav.getSelectedItem().Name != "f00001" {
+		t.Errorf("after refresh %d items, selected %s", len(nav.items), nav.getSelectedItem().Name)
+	}
+}
+
+func TestRestorePositionWhileLoading(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	os.Mkdir(big, 0755)
+	count := 2*loadBatchSize + 500
+	for i := 0; i < count; i++ {
+		createTestFile(t, big, fmt.Sprintf("f%05d", i), "x")
+	}
+	nav := NewNavigator(root)
+	queue, settle := backgroundLoading(t, nav)
+	nav.changeDirectory(big)
+	settle()
+	nav.selectedIdx, nav.scrollOffset = count-10, count-15
+	nav.changeDirectory(root)
+	settle()
+
+	// Keep the cursor on screen after every batch, as drawing does
+	const rows = 20
+	nav.goBack()
+	for nav.loading() {
+		(<-queue)()
+		if nav.selectedIdx >= nav.scrollOffset+rows {
+			nav.scrollOffset = nav.selectedIdx - rows + 1
+		}
+		if nav.selectedIdx < nav.scrollOffset {
+			nav.scrollOffset = nav.selectedIdx
+		}
+	}
+	if selected := nav.getSelectedItem(); selected == nil || selected.Name != fmt.Sprintf("f%05d", count-10) {
+		t.Errorf("selected %v, want f%05d", selected, count-10)
+	}
+	if nav.scrollOffset != count-15 {
+		t.Errorf("scroll offset %d, want %d", nav.scrollOffset, count-15)
+	}
+}
+
+func TestChangesWhileLoading(t *testing.T) {
+	big := t.TempDir()
+	count := 2*loadBatchSize + 1
+	for i := 0; i < count; i++ {
+		createTestFile(t, big, fmt.Sprintf("f%d", i), "x")
+	}
+	app := &App{navigator: NewNavigator(t.TempDir()), statusBar: &StatusBar{}}
+	nav := app.navigator
+	queue, settle := backgroundLoading(t, nav)
+	nav.changeDirectory(big)
+	load := nav.load
+	(<-queue)()
+
+	// A change on disk must not restart the first load and lose its progress
+	createTestFile(t, big, "new", "x")
+	app.refreshFromDisk(big)
+	if nav.load != load || len(nav.items) != loadBatchSize {
+		t.Fatalf("after a change mid-load: %d items, same load %v; want the load to carry on", len(nav.items), nav.load == load)
+	}
+	settle()
+	if len(nav.items) != count+1 || nav.listed != big {
+		t.Errorf("listing has %d items, want %d once read again", len(nav.items), count+1)
+	}
+}
+
+func TestLoadDirectoryCancelled(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	small := filepath.Join(root, "small")
+	os.Mkdir(big, 0755)
+	os.Mkdir(small, 0755)
+	for i := 0; i < 3*loadBatchSize; i++ {
+		createTestFile(t, big, fmt.Sprintf("f%d", i), "x")
+	}
+	createTestFile(t, small, "only", "x")
+	nav := NewNavigator(root)
+	queue, settle := backgroundLoading(t, nav)
+
+	nav.changeDirectory(big)
+	nav.changeDirectory(small)
+	settle()
+	// Whatever the abandoned load still posts must be ignored
+	for {
+		select {
+		case fn := <-queue:
+			fn()
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if len(nav.items) != 1 || nav.items[0].Name != "only" || nav.currentPath != small {
+		t.Errorf("listing of %s has %d items, want just small/only", nav.currentPath, len(nav.items))
+	}
+
+	// An unreadable directory is reported right away, keeping the listing
+	if err := nav.changeDirectory(filepath.Join(root, "missing")); err == nil {
+		t.Error("changing to a missing directory succeeded")
+	}
+	if nav.currentPath != small || len(nav.items) != 1 {
+		t.Errorf("after failed move at %s with %d items", nav.currentPath, len(nav.items))
+	}
+}
+
+func TestReadItemsSkipsHiddenWithoutStat(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "shown", "x")
+	createTestFile(t, dir, ".hidden", "x")
+	os.Symlink(filepath.Join(dir, "shown"), filepath.Join(dir, "link"))
+	os.Symlink(filepath.Join(dir, "gone"), filepath.Join(dir, "broken"))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove the hidden file after listing: filtering it must not need a stat
+	os.Remove(filepath.Join(dir, ".hidden"))
+
+	items, hidden := readItems(dir, entries, newItemFilter(dir, FilterOptions{}))
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"broken", "link", "shown"}; !slices.Equal(names, want) || hidden != 1 {
+		t.Errorf("items = %v with %d hidden, want %v with 1", names, hidden, want)
+	}
+}
+
+func TestSymlinkItems(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "real.txt", "x")
+	os.Mkdir(filepath.Join(dir, "realdir"), 0755)
+	os.Symlink("real.txt", filepath.Join(dir, "file-link"))
+	os.Symlink("realdir", filepath.Join(dir, "dir-link"))
+	os.Symlink("nowhere", filepath.Join(dir, "broken"))
+
+	nav := NewNavigator(dir)
+	items := make(map[string]FileItem)
+	for _, item := range nav.items {
+		items[item.Name] = item
+	}
+	tests := []struct {
+		name                  string
+		isLink, isDir, broken bool
+		target                string
+	}{
+		{"real.txt", false, false, false, ""},
+		{"file-link", true, false, false, "real.txt"},
+		{"dir-link", true, true, false, "realdir"},
+		{"broken", true, false, true, "nowhere"},
+	}
+	for _, tt := range tests {
+		item, ok := items[tt.name]
+		if !ok {
+			t.Errorf("%s is not listed", tt.name)
+			continue
+		}
+		if item.IsLink != tt.isLink || item.IsDir != tt.isDir || item.Broken != tt.broken || item.Target != tt.target {
+			t.Errorf("%s: link %v dir %v broken %v target %q, want %v %v %v %q", tt.name,
+				item.IsLink, item.IsDir, item.Broken, item.Target, tt.isLink, tt.isDir, tt.broken, tt.target)
+		}
+	}
+
+	theme := builtinTheme("dark")
+	if theme.itemStyle(items["broken"]) != theme.BrokenLink || theme.itemStyle(items["dir-link"]) != theme.Link {
+		t.Error("links should use the link styles")
+	}
+	ls := parseLSColors("ln=36:or=31")
+	orphan, _ := ls.styleFor(items["broken"])
+	link, _ := ls.styleFor(items["file-link"])
+	if orphan == link {
+		t.Error("LS_COLORS or= should color broken links differently from ln=")
+	}
+}
+
+func TestFollowLink(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "real")
+	os.Mkdir(real, 0755)
+	createTestFile(t, real, "file.txt", "x")
+	os.Symlink(real, filepath.Join(root, "alias"))
+	os.Symlink(filepath.Join(real, "file.txt"), filepath.Join(root, "shortcut"))
+	os.Symlink("nowhere", filepath.Join(root, "broken"))
+	// t.TempDir may itself sit behind a link, as on macOS
+	root, _ = filepath.EvalSymlinks(root)
+	real = filepath.Join(root, "real")
+
+	nav := NewNavigator(root)
+	// Entering a link stays on the logical path
+	nav.selectPath(filepath.Join(root, "alias"))
+	nav.enterDirectory()
+	if want := filepath.Join(root, "alias"); nav.currentPath != want {
+		t.Fatalf("entered %s, want %s", nav.currentPath, want)
+	}
+	// Following from inside a linked directory moves to the real one
+	if err := nav.followLink(); err != nil || nav.currentPath != real || nav.getSelectedItem().Name != "file.txt" {
+		t.Errorf("follow from alias: at %s (err %v), want %s with file.txt selected", nav.currentPath, err, real)
+	}
+	if err := nav.followLink(); err != errNotLink {
+		t.Errorf("following on a real path = %v, want errNotLink", err)
+	}
+
+	nav.changeDirectory(root)
+	nav.selectPath(filepath.Join(root, "shortcut"))
+	if err := nav.followLink(); err != nil || nav.currentPath != real || nav.getSelectedItem().Name != "file.txt" {
+		t.Errorf("follow file link: at %s (err %v)", nav.currentPath, err)
+	}
+
+	nav.changeDirectory(root)
+	nav.selectPath(filepath.Join(root, "broken"))
+	app := &App{navigator: nav, statusBar: &StatusBar{}}
+	app.followLink()
+	if !app.statusBar.isError || !strings.Contains(app.statusBar.message, "Broken link: broken -> nowhere") || nav.currentPath != root {
+		t.Errorf("following a broken link: %q at %s", app.statusBar.message, nav.currentPath)
+	}
+}
+
+func TestEditorReturnsToListing(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "a.txt", "x")
+	createTestFile(t, dir, "b.txt", "x")
+	// The "editor" records its arguments and saves a new file next to them
+	editor := filepath.Join(t.TempDir(), "editor")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args.log") + "\n"
+	if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", editor)
+	t.Setenv("VISUAL", "")
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, running: true}
+	app.navigator.selectPath(filepath.Join(dir, "b.txt"))
+
+	app.openFile()
+	if !app.running || app.statusBar.isError {
+		t.Fatalf("after editing: running %v, status %q", app.running, app.statusBar.message)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "args.log"))
+	if got := strings.TrimSpace(string(data)); got != filepath.Join(dir, "b.txt") {
+		t.Errorf("editor got %q", got)
+	}
+	if len(app.navigator.items) != 3 || app.navigator.getSelectedItem().Name != "b.txt" {
+		t.Errorf("after editing: %d items, selected %s; want the refreshed listing on b.txt",
+			len(app.navigator.items), app.navigator.getSelectedItem().Name)
+	}
+
+	os.WriteFile(editor, []byte("#!/bin/sh\nexit 3\n"), 0755)
+	app.openFile()
+	if !app.statusBar.isError || !strings.Contains(app.statusBar.message, "exit status 3") {
+		t.Errorf("a failing editor should be reported, got %q", app.statusBar.message)
+	}
+}
+
+func TestParseConfigEditor(t *testing.T) {
+	if DefaultConfig().ExitAfterEdit {
+		t.Error("exit after edit should be opt-in")
+	}
+	config, err := parseConfig("test.toml", "[editor]\nexit_after_edit = true\nedit_new_files = false\n")
+	if err != nil || !config.ExitAfterEdit || config.EditNewFiles {
+		t.Errorf("config = %+v (err %v), want exit after edit and new files left alone", config, err)
+	}
+}
+
+func TestCreateFileOpensEditor(t *testing.T) {
+	dir := t.TempDir()
+	// Only editor.command is set; the new file must still reach it
+	editor := filepath.Join(t.TempDir(), "editor")
+	os.WriteFile(editor, []byte("#!/bin/sh\necho \"$@\" > "+filepath.Join(dir, "args.log")+"\n"), 0755)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	config := DefaultConfig()
+	config.Editor = editor
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, config: config, running: true}
+	app.createFile("new.txt")
+	data, _ := os.ReadFile(filepath.Join(dir, "args.log"))
+	if got := strings.TrimSpace(string(data)); got != filepath.Join(dir, "new.txt") {
+		t.Errorf("editor got %q, want the new file", got)
+	}
+
+	os.Remove(filepath.Join(dir, "args.log"))
+	config.EditNewFiles = false
+	app.createFile("other.txt")
+	if _, err := os.Stat(filepath.Join(dir, "args.log")); err == nil {
+		t.Error("edit_new_files = false should leave the editor closed")
+	}
+}
+
+func TestCreateFileWithoutEditor(t *testing.T) {
+	dir := t.TempDir()
+	// A vi on the PATH that would record being run
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "vi"), []byte("#!/bin/sh\necho \"$@\" > "+filepath.Join(dir, "args.log")+"\n"), 0755)
+	t.Setenv("PATH", bin)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", " ")
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, config: DefaultConfig(), running: true}
+	app.createFile("new.txt")
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err != nil {
+		t.Fatalf("file not created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "args.log")); err == nil {
+		t.Error("with no editor set, a new file should not open in vi")
+	}
+}
+
+func TestDetectMIME(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"photo", "\x89PNG\r\n\x1a\n....", "image/png"},
+		{"scan.dat", "%PDF-1.7\n", "application/pdf"},
+		{"book.epub", "PK\x03\x04rest", "application/zip"},
+		{"backup", string(tar), "application/x-tar"},
+		{"img", "RIFF\x00\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"data.json", "{\"a\": 1}\n", "application/json"},
+		{"README", "plain words\n", "text/plain"},
+		{"fake.png", "not really an image\n", "text/plain"},
+		{"blob", "\x00\x01\x02\x03\xfe", "application/octet-stream"},
+		{"damaged.pdf", "\x00\x01\x02\x03\xfe", "application/pdf"},
+	}
+	for _, tt := range tests {
+		if got := detectMIME(tt.name, []byte(tt.head)); got != tt.want {
+			t.Errorf("detectMIME(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseConfigOpeners(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[[opener]]
+mime = ["image/*"]
+command = "feh --scale-down {file}"
+background = true
+
+[[opener]]
+extensions = ["tgz"]
+globs = ["*.tar.*"]
+command = "sh -c 'tar tf \"$1\" | less' sh"
+`)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if len(config.Openers) != 2 || !config.Openers[0].Background || config.Openers[1].Extensions[0] != ".tgz" {
+		t.Fatalf("openers = %+v", config.Openers)
+	}
+	tests := []struct {
+		name, mimeType string
+		want           []string
+	}{
+		{"a.png", "image/png", []string{"feh --scale-down {file}"}},
+		{"src.TGZ", "application/gzip", []string{config.Openers[1].Command}},
+		{"src.tar.xz", "application/x-xz", []string{config.Openers[1].Command}},
+		{"notes.txt", "text/plain", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, rule := range matchingOpeners(config.Openers, tt.name, tt.mimeType) {
+			got = append(got, rule.Command)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("openers for %s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"[[opener]]\ncommand = \"feh\"\n",                        // nothing to match
+		"[[opener]]\nmime = [\"image\"]\ncommand = \"feh\"\n",     // not a MIME pattern
+		"[[opener]]\nglobs = [\"*.png\"]\n",                       // no command
+		"[[opener]]\nglobs = [\"*.png\"]\ncommand = \"feh '\"\n", // unterminated quote
+	} {
+		if _, err := parseConfig("bad.toml", bad); err == nil {
+			t.Errorf("config should be rejected:\n%s", bad)
+		}
+	}
+}
+
+func TestOpenFileWithOpeners(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "image.png", "\x89PNG\r\n\x1a\nbinary")
+	createTestFile(t, dir, "notes.txt", "hello")
+	log := filepath.Join(t.TempDir(), "opened.log")
+	viewer := filepath.Join(t.TempDir(), "viewer")
+	os.WriteFile(viewer, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n"), 0755)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", viewer+" --edit")
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	config := DefaultConfig()
+	config.Openers = []OpenerRule{
+		{MIME: []string{"image/*"}, Command: viewer + " --view {file} --fit"},
+		{MIME: []string{"image/*"}, Command: viewer + " --second"},
+		{Globs: []string{"*.mp4"}, Command: viewer + " --mpv"},
+		{Extensions: []string{".zip"}, Command: viewer + " --zip"},
+	}
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, config: config, running: true}
+
+	// Each file goes to its rule, text without one to the editor
+	app.navigator.markAll()
+	app.openFile()
+	data, _ := os.ReadFile(log)
+	want := "--view " + filepath.Join(dir, "image.png") + " --fit\n--edit " + filepath.Join(dir, "notes.txt") + "\n"
+	if string(data) != want {
+		t.Errorf("opened:\n%s\nwant:\n%s", data, want)
+	}
+
+	app.navigator.clearMarks()
+	app.navigator.selectPath(filepath.Join(dir, "image.png"))
+	app.openWith()
+	if app.picker == nil {
+		t.Fatal("open with should show a picker")
+	}
+	var labels []string
+	for _, item := range app.picker.items {
+		labels = append(labels, item.Detail)
+	}
+	if want := []string{"matches", "matches", "editor", "system default", "", ""}; !slices.Equal(labels, want) {
+		t.Errorf("open with choices = %q, want %q", labels, want)
+	}
+	if !strings.Contains(app.picker.title, "image/png") {
+		t.Errorf("title %q should show the detected type", app.picker.title)
+	}
+
+	// Choices other than the last of their group run their own rule
+	for _, choice := range []struct {
+		index int
+		want  string
+	}{{0, "--view"}, {4, "--mpv"}} {
+		os.Remove(log)
+		app.openWith()
+		app.picker.selectedIdx = choice.index
+		app.selectPickerItem()
+		data, _ = os.ReadFile(log)
+		if !strings.HasPrefix(string(data), choice.want+" ") {
+			t.Errorf("choice %d ran %q, want %s", choice.index, data, choice.want)
+		}
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "it's here.txt", "x")
+	createTestFile(t, dir, "b.txt", "x")
+	nav := NewNavigator(dir)
+	nav.selectPath(filepath.Join(dir, "it's here.txt"))
+
+	got, err := expandCommand("wc -l {file} > {dir}/out; echo {name} {unknown}", nav)
+	want := "wc -l '" + dir + "/it'\\''s here.txt' > '" + dir + "'/out; echo 'it'\\''s here.txt' {unknown}"
+	if err != nil || got != want {
+		t.Errorf("expandCommand = %q, %v\nwant %q", got, err, want)
+	}
+	// The shell reads the quoted name back as it was
+	out, err := exec.Command("sh", "-c", "printf %s "+shellQuote("it's $HOME")).Output()
+	if err != nil || string(out) != "it's $HOME" {
+		t.Errorf("shellQuote round trip = %q, %v", out, err)
+	}
+
+	nav.markAll()
+	if got, _ := expandCommand("tar czf x.tgz {files}", nav); strings.Count(got, dir) != 2 {
+		t.Errorf("{files} with two marked = %q", got)
+	}
+	empty := NewNavigator(t.TempDir())
+	if _, err := expandCommand("rm {file}", empty); err == nil {
+		t.Error("{file} with nothing selected should fail")
+	}
+	if got, err := expandCommand("ls {dir}", empty); err != nil || !strings.HasPrefix(got, "ls '") {
+		t.Errorf("{dir} in an empty directory = %q, %v", got, err)
+	}
+}
+
+func TestWaitForEnter(t *testing.T) {
+	in := strings.NewReader("typed\nleft for powpow")
+	var out strings.Builder
+	waitForEnter(in, &out, nil)
+	if rest, _ := io.ReadAll(in); string(rest) != "left for powpow" {
+		t.Errorf("input after Enter = %q, want it left unread", rest)
+	}
+	if !strings.Contains(out.String(), "Press Enter") {
+		t.Errorf("prompt = %q", out.String())
+	}
+
+	out.Reset()
+	waitForEnter(strings.NewReader(""), &out, fmt.Errorf("exit status 2"))
+	if !strings.Contains(out.String(), "exit status 2") {
+		t.Errorf("prompt after a failure = %q, want the error", out.String())
+	}
+}
+
+func TestParseConfigCommands(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[[command]]
+name = "fetch"
+description = "Fetch all remotes"
+run = "git fetch --all"
+keys = ["g f"]
+background = true
+
+[[command]]
+name = "tig"
+run = "tig"
+pause = false
+
+[keys]
+command.tig = "ctrl+t"
+`)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if len(config.Commands) != 2 || !config.Commands[0].Background || config.Commands[1].Description != "" ||
+		!config.Commands[0].Pause || config.Commands[1].Pause {
+		t.Fatalf("commands = %+v", config.Commands)
+	}
+	keymap, err := NewKeymap(config.Keys, commandActions(config.Commands)...)
+	if err != nil {
+		t.Fatalf("NewKeymap() error = %v", err)
+	}
+	if action, _ := keymap.lookup(ContextNormal, []string{"g", "f"}); action == nil || action.Name != "command.fetch" {
+		t.Errorf("g f = %v, want command.fetch", action)
+	}
+	if action, _ := keymap.lookup(ContextNormal, []string{"ctrl+t"}); action == nil || action.Description != "tig" {
+		t.Errorf("ctrl+t = %v, want command.tig described by its script", action)
+	}
+	if !strings.Contains(keymap.markdownHelp(), "### Commands") {
+		t.Error("help should list the configured commands")
+	}
+
+	// A command may not take a key the defaults already use
+	if _, err := NewKeymap(nil, commandActions([]CommandConfig{{Name: "x", Run: "x", Keys: []string{"j"}}})...); err == nil {
+		t.Error("binding a command to j should conflict with nav.down")
+	}
+	for _, bad := range []string{
+		"[[command]]\nname = \"has space\"\nrun = \"x\"\n",
+		"[[command]]\nname = \"x\"\n",
+		"[[command]]\nname = \"x\"\nrun = \"a\"\n[[command]]\nname = \"x\"\nrun = \"b\"\n",
+		"[keys]\ncommand.missing = \"x\"\n",
+		"[[command]]\nname = \"x\"\nrun = \"a\"\nkeys = [\"j\"]\n",
+	} {
+		if _, err := parseConfig("bad.toml", bad); err == nil {
+			t.Errorf("config should be rejected:\n%s", bad)
+		}
+	}
+}
+
+func TestUserCommands(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "a.txt", "x")
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(60, 10)
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, commandLog: &CommandLog{},
+		running: true, width: 60, height: 10}
+
+	// Foreground commands run in the current directory and refresh the listing
+	app.runUserCommand(CommandConfig{Name: "touch", Run: "touch made-here"})
+	if len(app.navigator.items) != 2 || app.statusBar.isError {
+		t.Errorf("after foreground command: %d items, status %q", len(app.navigator.items), app.statusBar.message)
+	}
+
+	app.runUserCommand(CommandConfig{Name: "count", Run: "printf 'one\\ttab\\ntwo\\n'; echo oops >&2; exit 2", Background: true})
+	for done := false; !done; {
+		if ev, ok := screen.PollEvent().(*uiEvent); ok && ev.fn != nil {
+			ev.fn()
+			done = true
+		}
+	}
+	if !app.statusBar.isError || !strings.Contains(app.statusBar.message, "count failed: exit status 2") {
+		t.Errorf("status = %q, want the failure reported", app.statusBar.message)
+	}
+	lines := app.commandLog.lines()
+	if len(lines) != 5 || !strings.HasSuffix(lines[0], "count - failed: exit status 2") || lines[2] != "one\ttab" || lines[4] != "oops" {
+		t.Errorf("log = %q", lines)
+	}
+
+	app.showCommandLog()
+	app.render()
+	screen.Show()
+	cells, width, _ := screen.GetContents()
+	var row []rune
+	for x := 0; x < width; x++ {
+		row = append(row, cells[3*width+x].Runes...)
+	}
+	if got := string(row); !strings.HasPrefix(got, " one    tab") {
+		t.Errorf("pager line = %q, want tabs expanded", got)
+	}
+	app.dispatchKey(app.keyContext(), "q")
+	if app.pager != nil {
+		t.Error("q should close the pager")
+	}
+}
+
+func TestCommandActionsRunTheirOwnCommand(t *testing.T) {
+	dir := t.TempDir()
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, commandLog: &CommandLog{}, running: true}
+	actions := commandActions([]CommandConfig{
+		{Name: "first", Run: "touch first.txt"},
+		{Name: "second", Run: "touch second.txt"},
+	})
+	actions[0].Run(app)
+	if _, err := os.Stat(filepath.Join(dir, "first.txt")); err != nil {
+		t.Errorf("command.first should run its own script: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "second.txt")); err == nil {
+		t.Error("command.first ran the second command's script")
+	}
+}
+
+func TestCommandPalette(t *testing.T) {
+	keymap, err := NewKeymap(nil, commandActions([]CommandConfig{{Name: "hello", Description: "Say hello", Run: "true"}})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{navigator: NewNavigator(t.TempDir()), statusBar: &StatusBar{}, keymap: keymap}
+	app.showPalette()
+	app.picker.setQuery("hidden files")
+	if item := app.picker.selected(); item == nil || item.Label != "Show or hide hidden files" || item.Detail != "." {
+		t.Fatalf("palette match = %+v", item)
+	}
+	app.selectPickerItem()
+	if app.navigator.filter.ShowHidden != !defaultConfig.Filter.ShowHidden {
+		t.Error("choosing from the palette should run the action")
+	}
+	app.showPalette()
+	app.picker.setQuery("say hello")
+	if item := app.picker.selected(); item == nil || item.Detail != "command.hello" {
+		t.Errorf("unbound command in palette = %+v", item)
+	}
+}
+
+func TestShellEscape(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "a.txt", "x")
+	t.Setenv("SHELL", "/bin/sh")
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, commandLog: &CommandLog{},
+		running: true, width: 80, height: 24}
+
+	app.dispatchKey(app.keyContext(), "!")
+	if !app.popup.active || app.popup.popupType != PopupShell {
+		t.Fatal("! should prompt for a command")
+	}
+	app.popup.inputBuffer = "echo {name}; pwd; read line || echo no input; touch made.txt; exit 3"
+	app.submitPopup()
+	if app.pager == nil || !strings.HasPrefix(app.pager.title, "$ echo {name};") {
+		t.Fatal("the output should be shown in a pager")
+	}
+	for done := false; !done; {
+		if ev, ok := screen.PollEvent().(*uiEvent); ok && ev.fn != nil {
+			ev.fn()
+			done = true
+		}
+	}
+	want := []string{"a.txt", dir, "no input", "", "-- failed: exit status 3 --"}
+	if got := app.pager.source(); !slices.Equal(got, want) {
+		t.Errorf("pager = %q, want %q", got, want)
+	}
+	if len(app.navigator.items) != 2 {
+		t.Errorf("listing has %d items, want it refreshed with made.txt", len(app.navigator.items))
+	}
+	if lines := app.commandLog.lines(); len(lines) < 2 || !strings.HasSuffix(lines[0], "! - failed: exit status 3") {
+		t.Errorf("log = %q, want the command recorded", lines)
+	}
+
+	// Blank input runs nothing
+	app.closePager()
+	app.runShellCommand("  ")
+	if app.pager != nil || len(app.commandLog.runs) != 1 {
+		t.Error("a blank command should do nothing")
+	}
+}
+
+func TestStopShellCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(t.TempDir()), statusBar: &StatusBar{}, commandLog: &CommandLog{},
+		running: true, width: 80, height: 24}
+	// Waits for the command to exit: a part left running would hang here
+	finished := func() {
+		for {
+			if ev, ok := screen.PollEvent().(*uiEvent); ok && ev.fn != nil {
+				ev.fn()
+				return
+			}
+		}
+	}
+
+	// A pipeline that would run forever: every part of it must stop
+	for _, key := range []string{"ctrl+c", "q"} {
+		app.runShellCommand("while :; do echo tick; sleep 0.01; done | cat")
+		run := app.pager.run
+		for deadline := time.Now().Add(5 * time.Second); len(run.outputLines()) == 0; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("no output from the command")
+			}
+		}
+		app.dispatchKey(app.keyContext(), key)
+		finished()
+		if lines := run.outputLines(); lines[len(lines)-1] != "-- stopped --" {
+			t.Errorf("%s: output ends %q, want the command stopped", key, lines[len(lines)-1])
+		}
+		if app.statusBar.message != "Command stopped" {
+			t.Errorf("%s: status = %q", key, app.statusBar.message)
+		}
+		if app.pager != nil {
+			app.closePager()
+		}
+	}
+	if lines := app.commandLog.lines(); !strings.HasSuffix(lines[0], "! - stopped") {
+		t.Errorf("log = %q, want the run marked stopped", lines[0])
+	}
+}
+
+func TestOpenShell(t *testing.T) {
+	dir := t.TempDir()
+	// The "shell" records where it started and leaves a file behind
+	shell := filepath.Join(t.TempDir(), "shell")
+	script := "#!/bin/sh\npwd > " + filepath.Join(dir, "pwd.log") + "\nexit 1\n"
+	if err := os.WriteFile(shell, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", shell)
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	app := &App{screen: screen, navigator: NewNavigator(dir), statusBar: &StatusBar{}, running: true}
+	app.dispatchKey(app.keyContext(), "$")
+	data, _ := os.ReadFile(filepath.Join(dir, "pwd.log"))
+	if got := strings.TrimSpace(string(data)); got != dir {
+		t.Errorf("shell started in %q, want %q", got, dir)
+	}
+	if app.statusBar.isError || len(app.navigator.items) != 1 {
+		t.Errorf("after the shell: status %q, %d items; want no error and a refreshed listing",
+			app.statusBar.message, len(app.navigator.items))
+	}
+
+	t.Setenv("SHELL", filepath.Join(dir, "missing"))
+	app.openShell()
+	if !app.statusBar.isError {
+		t.Error("a missing shell should be reported")
+	}
+}
+
+// Tests for key bindings
+
+func TestParseKeySpec(t *testing.T) {
+	tests := map[string]string{
+		"j":          "[j]",
+		"G":          "[G]",
+		"Ctrl+N":     "[ctrl+n]",
+		"C-x d":      "[ctrl+x d]",
+		"g g":        "[g g]",
+		"Escape":     "[esc]",
+		"PageDown":   "[pgdn]",
+		"F5":         "[f5]",
+		"alt+X":      "[alt+X]",
+		"space":      "[space]",
+	}
+	for spec, want := range tests {
+		sequence, err := parseKeySpec(spec)
+		if err != nil {
+			t.Errorf("parseKeySpec(%q) error: %v", spec, err)
+			continue
+		}
+		if fmt.Sprint(sequence) != want {
+			t.Errorf("parseKeySpec(%q) = %v, want %s", spec, sequence, want)
+		}
+	}
+
+	for _, spec := range []string{"", "ctrl+1", "hyper", "f13"} {
+		if _, err := parseKeySpec(spec); err == nil {
+			t.Errorf("parseKeySpec(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCtrlKeysRoundTrip(t *testing.T) {
+	// Every Ctrl+letter that parses must come back from its key event
+	for letter := 'a'; letter <= 'z'; letter++ {
+		spec := "ctrl+" + string(letter)
+		key, err := parseKey(spec)
+		ev := tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(letter-'a'), 0, tcell.ModCtrl)
+		if err != nil {
+			if !strings.Contains(err.Error(), keyName(ev)) {
+				t.Errorf("parseKey(%q) error %q should name %s", spec, err, keyName(ev))
+			}
+			continue
+		}
+		if got := keyName(ev); got != key {
+			t.Errorf("%s parses as %q but arrives as %q", spec, key, got)
+		}
+	}
+	for _, spec := range []string{"ctrl+h", "C-i", "ctrl+M"} {
+		if _, err := parseKey(spec); err == nil {
+			t.Errorf("parseKey(%q) should fail", spec)
+		}
+	}
+}
+
+func TestKeyNameFromEvents(t *testing.T) {
+	tests := []struct {
+		ev   *tcell.EventKey
+		want string
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone), "j"},
+		{tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), "space"},
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt), "alt+x"},
+		{tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl), "ctrl+f"},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), "enter"},
+		{tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone), "backspace"},
+		{tcell.NewEventKey(tcell.KeyF1, 0, tcell.ModNone), "f1"},
+		{tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModNone), "pgdn"},
+	}
+	for _, tt := range tests {
+		if got := keyName(tt.ev); got != tt.want {
+			t.Errorf("keyName(%v) = %q, want %q", tt.ev.Name(), got, tt.want)
+		}
+	}
+}
+
+func TestKeySequences(t *testing.T) {
+	testDir := createTestStructure(t)
+	app := &App{navigator: NewNavigator(testDir)}
+	press := func(r rune) {
+		app.handleKey(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	last := len(app.navigator.filteredItems) - 1
+
+	press('G')
+	if app.navigator.selectedIdx != last {
+		t.Fatalf("G selected %d, want %d", app.navigator.selectedIdx, last)
+	}
+
+	press('g')
+	if app.navigator.selectedIdx != last || len(app.pendingKeys) != 1 {
+		t.Fatalf("a single g should wait for the rest of the sequence")
+	}
+	press('g')
+	if app.navigator.selectedIdx != 0 || app.pendingKeys != nil {
+		t.Errorf("gg selected %d with pending %v, want 0 and none", app.navigator.selectedIdx, app.pendingKeys)
+	}
+
+	// A key that breaks a sequence is handled on its own
+	press('g')
+	press('j')
+	if app.navigator.selectedIdx != 1 || app.pendingKeys != nil {
+		t.Errorf("g then j selected %d with pending %v, want 1 and none", app.navigator.selectedIdx, app.pendingKeys)
+	}
+}
+
+func TestKeymapOverrides(t *testing.T) {
+	keymap, err := NewKeymap(map[string][]string{"file.new_folder": {"ctrl+k"}, "nav.top": {}, "nav.follow": {}})
+	if err != nil {
+		t.Fatalf("NewKeymap error: %v", err)
+	}
+	if action, _ := keymap.lookup(ContextNormal, []string{"ctrl+k"}); action == nil || action.Name != "file.new_folder" {
+		t.Errorf("ctrl+k = %v, want file.new_folder", action)
+	}
+	if action, _ := keymap.lookup(ContextNormal, []string{"ctrl+f"}); action != nil {
+		t.Errorf("ctrl+f should be unbound after override, got %s", action.Name)
+	}
+	if _, pending := keymap.lookup(ContextNormal, []string{"g"}); pending {
+		t.Error("unbinding nav.top and nav.follow should remove the g sequences")
+	}
+	// The same key may mean different things in different modes
+	if action, _ := keymap.lookup(ContextTrash, []string{"j"}); action == nil || action.Name != "trash.down" {
+		t.Errorf("trash j = %v, want trash.down", action)
+	}
+
+	conflicts := []map[string][]string{
+		{"file.new_folder": {"ctrl+n"}}, // same key as file.new
+		{"select.all": {"g"}},           // prefix of g g
+		{"select.all": {"D x"}},         // extends D
+		{"no.such_action": {"z"}},
+	}
+	for _, overrides := range conflicts {
+		if _, err := NewKeymap(overrides); err == nil {
+			t.Errorf("NewKeymap(%v) should fail", overrides)
+		}
+	}
+}
+
+func TestParseConfigKeys(t *testing.T) {
+	config, err := parseConfig("test.toml", `
+[keys]
+file.new_folder = "ctrl+k"
+"search.start" = ["/", "ctrl+s"]
+`)
+	if err != nil {
+		t.Fatalf("parseConfig error: %v", err)
+	}
+	if fmt.Sprint(config.Keys["file.new_folder"]) != "[ctrl+k]" || fmt.Sprint(config.Keys["search.start"]) != "[/ ctrl+s]" {
+		t.Errorf("Keys = %v", config.Keys)
+	}
+
+	_, err = parseConfig("test.toml", `
+[keys]
+nav.sideways = "z"
+nav.up = "hyper"
+file.new_folder = "ctrl+n"
+`)
+	if err == nil {
+		t.Fatal("expected errors for bad key bindings")
+	}
+	for _, want := range []string{"keys.nav.sideways: unknown action", `keys.nav.up: unknown key "hyper"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %q", err, want)
+		}
+	}
+}
+
+func TestHelpFollowsKeymap(t *testing.T) {
+	keymap, err := NewKeymap(map[string][]string{"file.new_folder": {"ctrl+k"}})
+	if err != nil {
+		t.Fatalf("NewKeymap error: %v", err)
+	}
+	help := strings.Join(keymap.helpLines(), "\n")
+	if !strings.Contains(help, "Ctrl+K") || strings.Contains(help, "Ctrl+F") {
+		t.Errorf("help should list Ctrl+K and not Ctrl+F:\n%s", help)
+	}
+	if !strings.Contains(keymap.markdownHelp(), "| Home, gg ") {
+		t.Error("--help tables should list the gg sequence")
+	}
+	if !strings.Contains(keymap.statusHint(false), "/:search") {
+		t.Errorf("statusHint = %q", keymap.statusHint(false))
+	}
+}
+
 // Tests for StatusBar functionality
 
 func TestStatusBarMessages(t *testing.T) {
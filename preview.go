@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type PreviewKind int
+
+const (
+	PreviewText PreviewKind = iota
+	PreviewDir
+	PreviewBinary
+	PreviewError
+)
+
+// maxPreviewBytes bounds how much of a file a preview ever reads.
+const maxPreviewBytes = 64 * 1024
+
+type PreviewContent struct {
+	path   string
+	kind   PreviewKind
+	lines  []string
+	tokens [][]Token // syntax-highlighted lines, nil when no lexer matches
+}
+
+// Previewer tracks which item the pane shows. Content is loaded on a
+// background goroutine; generation discards results for stale selections.
+type Previewer struct {
+	enabled    bool
+	path       string
+	modTime    time.Time
+	content    *PreviewContent
+	generation int
+}
+
+// loadPreview builds the preview for item, reading at most maxLines lines.
+func loadPreview(item FileItem, maxLines int, isText func(FileItem) bool) PreviewContent {
+	content := PreviewContent{path: item.Path}
+	var err error
+
+	switch {
+	case item.IsDir:
+		content.kind = PreviewDir
+		content.lines, err = previewDirectory(item.Path, maxLines)
+	case isText(item):
+		content.kind = PreviewText
+		content.lines, err = previewText(item.Path, maxLines)
+		if lexer := lexerFor(item.Name); lexer != nil && err == nil {
+			content.tokens = highlightLines(lexer, content.lines)
+		}
+	default:
+		content.kind = PreviewBinary
+		content.lines, err = previewBinary(item, maxLines)
+	}
+
+	if err != nil {
+		content.kind = PreviewError
+		content.lines = []string{"Cannot preview: " + err.Error()}
+	}
+	return content
+}
+
+func previewDirectory(path string, maxLines int) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return []string{"(empty directory)"}, nil
+	}
+
+	lines := []string{fmt.Sprintf("%d items", len(entries)), ""}
+	for _, entry := range entries {
+		if len(lines) >= maxLines {
+			lines = append(lines, "...")
+			break
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, name)
+	}
+	return lines, nil
+}
+
+func previewText(path string, maxLines int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(io.LimitReader(file, maxPreviewBytes))
+	scanner.Buffer(make([]byte, 4096), maxPreviewBytes+1)
+	for scanner.Scan() && len(lines) < maxLines {
+		line := strings.ReplaceAll(scanner.Text(), "\t", "    ")
+		if !utf8.ValidString(line) {
+			line = strings.ToValidUTF8(line, "?")
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func previewBinary(item FileItem, maxLines int) ([]string, error) {
+	lines := []string{
+		"Binary file",
+		"",
+		"Size:     " + formatSize(item.Size),
+		"Mode:     " + item.Mode.String(),
+		"Modified: " + item.ModTime.Format("2006-01-02 15:04:05"),
+		"",
+	}
+
+	file, err := os.Open(item.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dumpLines := maxLines - len(lines)
+	if dumpLines <= 0 {
+		return lines, nil
+	}
+	buffer := make([]byte, dumpLines*16)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return append(lines, hexDump(buffer[:n])...), nil
+}
+
+// hexDump formats data like `hexdump -C`, 16 bytes per line.
+func hexDump(data []byte) []string {
+	var lines []string
+	for offset := 0; offset < len(data); offset += 16 {
+		end := offset + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[offset:end]
+
+		var hex, ascii strings.Builder
+		for i := 0; i < 16; i++ {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			if i < len(chunk) {
+				fmt.Fprintf(&hex, "%02x ", chunk[i])
+			} else {
+				hex.WriteString("   ")
+			}
+		}
+		for _, b := range chunk {
+			if b >= 32 && b <= 126 {
+				ascii.WriteByte(b)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%08x  %s |%s|", offset, hex.String(), ascii.String()))
+	}
+	return lines
+}
+
+func (app *App) togglePreview() {
+	app.previewer.enabled = !app.previewer.enabled
+	app.previewer.path = ""
+	app.previewer.content = nil
+}
+
+// previewWidth is the width of the preview pane, or 0 when it is hidden.
+func (app *App) previewWidth() int {
+	if !app.previewer.enabled || app.width < 40 {
+		return 0
+	}
+	return app.width / 2
+}
+
+// listWidth is the width left for the file list.
+func (app *App) listWidth() int {
+	if pw := app.previewWidth(); pw > 0 {
+		return app.width - pw - 1
+	}
+	return app.width
+}
+
+// updatePreview starts loading the selected item if the pane is stale.
+func (app *App) updatePreview() {
+	p := &app.previewer
+	selected := app.navigator.getSelectedItem()
+	if selected == nil {
+		p.path = ""
+		p.content = nil
+		return
+	}
+	if p.path == selected.Path && p.modTime.Equal(selected.ModTime) {
+		return
+	}
+
+	p.path = selected.Path
+	p.modTime = selected.ModTime
+	p.content = nil
+	p.generation++
+
+	generation := p.generation
+	item := *selected
+	maxLines := app.height
+	go func() {
+		content := loadPreview(item, maxLines, app.isTextFile)
+		app.postUI(func() {
+			if p.generation == generation {
+				p.content = &content
+			}
+		})
+	}()
+}
+
+func (app *App) drawPreview() {
+	width := app.previewWidth()
+	if width == 0 {
+		return
+	}
+	app.updatePreview()
+
+	separatorX := app.width - width - 1
+	startX := separatorX + 2
+	theme := app.theme()
+	borderStyle := theme.PreviewBorder
+	for y := 1; y < app.height-1; y++ {
+		app.screen.SetContent(separatorX, y, '│', nil, borderStyle)
+	}
+
+	content := app.previewer.content
+	if app.previewer.path == "" {
+		return
+	}
+	if content == nil {
+		app.drawText(startX, 1, "Loading...", theme.Dim)
+		return
+	}
+
+	var style tcell.Style
+	switch content.kind {
+	case PreviewDir:
+		style = theme.Directory
+	case PreviewBinary:
+		style = theme.Dim
+	case PreviewError:
+		style = theme.PreviewError
+	default:
+		style = theme.File
+	}
+
+	for i, line := range content.lines {
+		y := 1 + i
+		if y >= app.height-1 {
+			break
+		}
+		if content.tokens == nil {
+			app.drawTextWithin(startX, y, app.width, line, style)
+			continue
+		}
+		x := startX
+		for _, token := range content.tokens[i] {
+			app.drawTextWithin(x, y, app.width, token.Text, theme.tokenStyle(token.Kind))
+			x += utf8.RuneCountInString(token.Text)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// ownProcessGroup does nothing: only the command itself can be stopped here.
+func ownProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Marks are keyed by full path so they survive scrolling, re-sorting and
+// search filtering. They are cleared whenever the current directory changes.
+
+func (n *Navigator) isMarked(item FileItem) bool {
+	return n.marked[item.Path]
+}
+
+func (n *Navigator) toggleMark() {
+	selected := n.getSelectedItem()
+	if selected == nil {
+		return
+	}
+	if n.marked == nil {
+		n.marked = make(map[string]bool)
+	}
+	if n.marked[selected.Path] {
+		delete(n.marked, selected.Path)
+	} else {
+		n.marked[selected.Path] = true
+	}
+}
+
+// markAll marks every visible item, so it respects an active search filter.
+func (n *Navigator) markAll() {
+	if n.marked == nil {
+		n.marked = make(map[string]bool)
+	}
+	for _, item := range n.filteredItems {
+		n.marked[item.Path] = true
+	}
+}
+
+func (n *Navigator) invertMarks() {
+	if n.marked == nil {
+		n.marked = make(map[string]bool)
+	}
+	for _, item := range n.filteredItems {
+		if n.marked[item.Path] {
+			delete(n.marked, item.Path)
+		} else {
+			n.marked[item.Path] = true
+		}
+	}
+}
+
+// markByGlob adds every item whose name matches pattern and returns how many matched.
+func (n *Navigator) markByGlob(pattern string) (int, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+	if n.marked == nil {
+		n.marked = make(map[string]bool)
+	}
+	count := 0
+	for _, item := range n.items {
+		if ok, _ := filepath.Match(pattern, item.Name); ok {
+			n.marked[item.Path] = true
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (n *Navigator) clearMarks() {
+	n.marked = nil
+}
+
+// pruneMarks drops marks for items that no longer exist in the listing.
+func (n *Navigator) pruneMarks() {
+	if len(n.marked) == 0 {
+		return
+	}
+	present := make(map[string]bool, len(n.items))
+	for _, item := range n.items {
+		present[item.Path] = true
+	}
+	for path := range n.marked {
+		if !present[path] {
+			delete(n.marked, path)
+		}
+	}
+}
+
+func (n *Navigator) markedItems() []FileItem {
+	var items []FileItem
+	for _, item := range n.items {
+		if n.marked[item.Path] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// targetItems returns the marked items, or the selected item when nothing is marked.
+func (n *Navigator) targetItems() []FileItem {
+	if marked := n.markedItems(); len(marked) > 0 {
+		return marked
+	}
+	if selected := n.getSelectedItem(); selected != nil {
+		return []FileItem{*selected}
+	}
+	return nil
+}
+
+// describeItems summarizes targets for confirmation popups and status messages.
+func describeItems(items []FileItem) string {
+	if len(items) == 1 {
+		return "'" + items[0].Name + "'"
+	}
+	return fmt.Sprintf("%d items", len(items))
+}
+
+// itemListLines lists up to max item names for a confirmation popup.
+func itemListLines(items []FileItem, max int) []string {
+	if len(items) < 2 {
+		return nil
+	}
+	var lines []string
+	for i, item := range items {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("...and %d more", len(items)-max))
+			break
+		}
+		name := item.Name
+		if item.IsDir {
+			name += "/"
+		}
+		lines = append(lines, "  "+name)
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// userShell is $SHELL, or the system shell when it is unset.
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+// userShellCommand runs script with the user's shell, so its syntax and
+// builtins work as they do at the prompt.
+func userShellCommand(script string) []string {
+	if os.Getenv("SHELL") == "" {
+		return shellCommand(script)
+	}
+	return []string{userShell(), "-c", script}
+}
+
+func (app *App) startShellCommand() {
+	app.showPopup(PopupShell, "Run in "+app.navigator.currentPath, "$ ", "", nil)
+}
+
+// runShellCommand runs a command typed at the ! prompt and shows its
+// output as it arrives, until it exits or the pager is closed. Placeholders
+// work as in configured commands. The command gets no terminal, so
+// programs that ask for input see end of file; the interactive shell is
+// for those.
+func (app *App) runShellCommand(input string) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	script, err := expandCommand(input, app.navigator)
+	if err != nil {
+		app.statusBar.showError("Cannot run command: " + err.Error())
+		return
+	}
+	run, err := app.startLogged("!", script, userShellCommand(script), func(err error) {
+		switch {
+		case err == errStopped:
+			app.statusBar.showMessage("Command stopped")
+		case err != nil:
+			app.statusBar.showError("Command failed: " + err.Error())
+		default:
+			app.statusBar.showMessage("Command finished")
+		}
+	})
+	if err != nil {
+		app.statusBar.showError("Cannot run command: " + err.Error())
+		return
+	}
+	app.showPager("$ "+input, run.outputLines, true)
+	app.pager.run = run
+}
+
+// openShell hands the terminal to an interactive shell in the current
+// directory and refreshes the listing once it exits.
+func (app *App) openShell() {
+	err := app.runSuspended(userShell())
+	// The exit status is that of the last command typed, not a failure
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		app.statusBar.showError("Cannot start shell: " + err.Error())
+	}
+	app.navigator.reload()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type SortMode string
+
+const (
+	SortName      SortMode = "name"
+	SortNatural   SortMode = "natural" // name, with digit runs compared as numbers
+	SortSize      SortMode = "size"    // largest first
+	SortTime      SortMode = "mtime"   // newest first
+	SortExtension SortMode = "extension"
+	SortType      SortMode = "type" // directories, symlinks, executables, files, others
+)
+
+// sortModes is the order the sort key cycles through.
+var sortModes = []SortMode{SortName, SortNatural, SortSize, SortTime, SortExtension, SortType}
+
+func validSortMode(mode SortMode) bool {
+	for _, m := range sortModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func (o SortOptions) next() SortOptions {
+	for i, mode := range sortModes {
+		if mode == o.By {
+			o.By = sortModes[(i+1)%len(sortModes)]
+			return o
+		}
+	}
+	o.By = SortName
+	return o
+}
+
+// label is the sort shown in the breadcrumb bar, e.g. "size, reversed".
+func (o SortOptions) label() string {
+	if o.Reverse {
+		return string(o.By) + ", reversed"
+	}
+	return string(o.By)
+}
+
+func (n *Navigator) less(a, b FileItem) bool {
+	if n.sort.DirsFirst && a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+	c := n.compare(a, b)
+	if n.sort.Reverse {
+		return c > 0
+	}
+	return c < 0
+}
+
+// compare orders two items by the sort mode, breaking ties by name.
+func (n *Navigator) compare(a, b FileItem) int {
+	nameA, nameB := a.Name, b.Name
+	if !n.sort.CaseSensitive {
+		nameA, nameB = strings.ToLower(nameA), strings.ToLower(nameB)
+	}
+
+	var c int
+	switch n.sort.By {
+	case SortNatural:
+		c = naturalCompare(nameA, nameB)
+	case SortSize:
+		c = cmp.Compare(b.Size, a.Size)
+	case SortTime:
+		c = b.ModTime.Compare(a.ModTime)
+	case SortExtension:
+		c = strings.Compare(strings.ToLower(filepath.Ext(a.Name)), strings.ToLower(filepath.Ext(b.Name)))
+	case SortType:
+		c = cmp.Compare(typeRank(a), typeRank(b))
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(nameA, nameB)
+}
+
+func typeRank(item FileItem) int {
+	switch {
+	case item.IsDir:
+		return 0
+	case item.IsLink:
+		return 1
+	case item.Mode.IsRegular() && item.Mode&0111 != 0:
+		return 2
+	case item.Mode.IsRegular():
+		return 3
+	}
+	return 4
+}
+
+// naturalCompare compares strings so that "file2" sorts before "file10".
+func naturalCompare(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			startA, startB := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			numA := strings.TrimLeft(string(ra[startA:i]), "0")
+			numB := strings.TrimLeft(string(rb[startB:j]), "0")
+			if c := cmp.Compare(len(numA), len(numB)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(numA, numB); c != 0 {
+				return c
+			}
+			// Equal values: fewer leading zeros first
+			if c := cmp.Compare(i-startA, j-startB); c != 0 {
+				return c
+			}
+			continue
+		}
+		if c := cmp.Compare(ra[i], rb[j]); c != 0 {
+			return c
+		}
+		i++
+		j++
+	}
+	return cmp.Compare(len(ra)-i, len(rb)-j)
+}
+
+// setSort re-sorts the listing in place, keeping the selected item selected.
+func (n *Navigator) setSort(options SortOptions) {
+	n.sort = options
+	var selectedPath string
+	if selected := n.getSelectedItem(); selected != nil {
+		selectedPath = selected.Path
+	}
+
+	sort.SliceStable(n.items, func(i, j int) bool {
+		return n.less(n.items[i], n.items[j])
+	})
+	n.updateFilteredItems()
+	n.selectPath(selectedPath)
+	n.clampSelection()
+}
+
+const (
+	RememberGlobal    = "global"    // one sort for every directory, kept across sessions
+	RememberDirectory = "directory" // each directory keeps its own sort
+	RememberNone      = "none"      // changes last until quit
+)
+
+// SortMemory persists sort choices in the state directory.
+type SortMemory struct {
+	path     string
+	remember string
+	fallback SortOptions
+	Global   *SortOptions           `json:"global,omitempty"`
+	Dirs     map[string]SortOptions `json:"dirs,omitempty"`
+}
+
+// LoadSortMemory reads saved sorts from path; a missing or corrupt file
+// leaves only the configured fallback.
+func LoadSortMemory(path, remember string, fallback SortOptions) *SortMemory {
+	m := &SortMemory{path: path, remember: remember, fallback: fallback}
+	if remember == RememberNone {
+		return m
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		m.Global, m.Dirs = nil, nil
+		return m
+	}
+	if m.Global != nil && !validSortMode(m.Global.By) {
+		m.Global = nil
+	}
+	for dir, options := range m.Dirs {
+		if !validSortMode(options.By) {
+			delete(m.Dirs, dir)
+		}
+	}
+	return m
+}
+
+func (m *SortMemory) get(dir string) SortOptions {
+	if m.remember == RememberDirectory {
+		if options, ok := m.Dirs[dir]; ok {
+			return options
+		}
+	}
+	if m.Global != nil {
+		return *m.Global
+	}
+	return m.fallback
+}
+
+func (m *SortMemory) set(dir string, options SortOptions) error {
+	if m.remember == RememberDirectory {
+		if m.Dirs == nil {
+			m.Dirs = make(map[string]SortOptions)
+		}
+		m.Dirs[dir] = options
+	} else {
+		m.Global = &options
+	}
+	if m.remember == RememberNone {
+		return nil
+	}
+	return writeStateFile(m.path, m)
+}
+
+// useSortMemory makes the navigator follow (and re-apply) remembered sorts.
+func (n *Navigator) useSortMemory(memory *SortMemory) {
+	n.sortMemory = memory
+	n.setSort(memory.get(n.currentPath))
+}
+
+func (app *App) changeSort(change func(SortOptions) SortOptions) {
+	nav := app.navigator
+	options := change(nav.sort)
+	nav.setSort(options)
+
+	scope := ""
+	if nav.sortMemory != nil {
+		if err := nav.sortMemory.set(nav.currentPath, options); err != nil {
+			app.statusBar.showError("Cannot save sort: " + err.Error())
+			return
+		}
+		if nav.sortMemory.remember == RememberDirectory {
+			scope = " (this directory)"
+		}
+	}
+	app.statusBar.showMessage(fmt.Sprintf("Sort: %s%s", options.label(), scope))
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Theme holds every style the interface draws with.
+type Theme struct {
+	Directory  tcell.Style
+	File       tcell.Style
+	Hidden     tcell.Style
+	Executable tcell.Style
+	Marked     tcell.Style
+	Selected   tcell.Style
+	Dim        tcell.Style // placeholders such as "Loading..." and binary previews
+	Link       tcell.Style
+	BrokenLink tcell.Style
+
+	Bar      tcell.Style // breadcrumb, status bar and trash header
+	Search   tcell.Style
+	Error    tcell.Style
+	Transfer tcell.Style
+
+	Popup       tcell.Style
+	PopupTitle  tcell.Style
+	Help        tcell.Style
+	HelpTitle   tcell.Style
+	HelpSection tcell.Style
+	HelpFooter  tcell.Style
+
+	PreviewBorder tcell.Style
+	PreviewError  tcell.Style
+
+	Keyword tcell.Style
+	Type    tcell.Style
+	String  tcell.Style
+	Number  tcell.Style
+	Comment tcell.Style
+	Key     tcell.Style
+	Heading tcell.Style
+	Tag     tcell.Style
+
+	// LSColors colors the file list by type and extension when set.
+	LSColors *LSColors
+}
+
+// slots maps the names used in config files to the theme's styles.
+func (t *Theme) slots() map[string]*tcell.Style {
+	return map[string]*tcell.Style{
+		"directory": &t.Directory, "file": &t.File, "hidden": &t.Hidden,
+		"executable": &t.Executable, "marked": &t.Marked, "selected": &t.Selected, "dim": &t.Dim,
+		"link": &t.Link, "broken_link": &t.BrokenLink,
+		"bar": &t.Bar, "search": &t.Search, "error": &t.Error, "transfer": &t.Transfer,
+		"popup": &t.Popup, "popup_title": &t.PopupTitle,
+		"help": &t.Help, "help_title": &t.HelpTitle, "help_section": &t.HelpSection, "help_footer": &t.HelpFooter,
+		"preview_border": &t.PreviewBorder, "preview_error": &t.PreviewError,
+		"syntax_keyword": &t.Keyword, "syntax_type": &t.Type, "syntax_string": &t.String,
+		"syntax_number": &t.Number, "syntax_comment": &t.Comment, "syntax_key": &t.Key,
+		"syntax_heading": &t.Heading, "syntax_tag": &t.Tag,
+	}
+}
+
+// builtinThemes are written in the same style syntax as user themes.
+// mono uses only attributes and is picked when NO_COLOR is set.
+var builtinThemes = map[string]map[string]string{
+	"dark": {
+		"directory": "blue", "file": "white", "hidden": "gray", "executable": "green",
+		"marked": "yellow", "selected": "white on darkblue", "dim": "gray",
+		"link": "teal", "broken_link": "red",
+		"bar": "white on darkgray", "search": "black on yellow", "error": "white on red",
+		"transfer": "white on darkgreen",
+		"popup":    "black on white", "popup_title": "blue on white",
+		"help": "white on black", "help_title": "yellow on black", "help_section": "blue on black",
+		"help_footer":    "green on black",
+		"preview_border": "darkgray", "preview_error": "red",
+		"syntax_keyword": "yellow", "syntax_type": "teal", "syntax_string": "green",
+		"syntax_number": "fuchsia", "syntax_comment": "gray", "syntax_key": "aqua",
+		"syntax_heading": "blue bold", "syntax_tag": "blue",
+	},
+	"light": {
+		"directory": "navy bold", "file": "default", "hidden": "gray", "executable": "green",
+		"marked": "purple bold", "selected": "black on lightsteelblue", "dim": "gray",
+		"link": "teal", "broken_link": "red",
+		"bar": "black on silver", "search": "black on khaki", "error": "white on darkred",
+		"transfer": "white on darkgreen",
+		"popup":    "black on whitesmoke", "popup_title": "navy on whitesmoke",
+		"help": "default", "help_title": "darkgoldenrod bold", "help_section": "navy bold",
+		"help_footer":    "darkgreen",
+		"preview_border": "silver", "preview_error": "darkred",
+		"syntax_keyword": "purple", "syntax_type": "teal", "syntax_string": "green",
+		"syntax_number": "maroon", "syntax_comment": "gray", "syntax_key": "navy",
+		"syntax_heading": "navy bold", "syntax_tag": "navy",
+	},
+	"mono": {
+		"directory": "bold", "file": "default", "hidden": "dim", "executable": "default",
+		"marked": "underline", "selected": "reverse", "dim": "dim",
+		"link": "italic", "broken_link": "strikethrough",
+		"bar": "reverse", "search": "reverse bold", "error": "reverse bold",
+		"transfer": "reverse",
+		"popup":    "reverse", "popup_title": "reverse bold",
+		"help": "default", "help_title": "bold", "help_section": "bold", "help_footer": "dim",
+		"preview_border": "dim", "preview_error": "bold",
+		"syntax_keyword": "bold", "syntax_type": "default", "syntax_string": "default",
+		"syntax_number": "default", "syntax_comment": "dim", "syntax_key": "bold",
+		"syntax_heading": "bold", "syntax_tag": "default",
+	},
+}
+
+// newTheme builds a theme from slot -> style spec layers, later layers
+// overriding earlier ones.
+func newTheme(layers ...map[string]string) (*Theme, error) {
+	theme := &Theme{}
+	slots := theme.slots()
+	for _, layer := range layers {
+		for _, name := range sortedKeys(layer) {
+			slot, ok := slots[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown theme style %q", name)
+			}
+			style, err := parseStyle(layer[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			*slot = style
+		}
+	}
+	return theme, nil
+}
+
+func builtinTheme(name string) *Theme {
+	theme, err := newTheme(builtinThemes[name])
+	if err != nil {
+		panic("builtin theme " + name + ": " + err.Error())
+	}
+	return theme
+}
+
+// parseStyle parses "FG [on BG] [ATTR...]", e.g. "white on #1e3a5f bold".
+// Colors are names or #rrggbb; "default" keeps the terminal's color.
+func parseStyle(spec string) (tcell.Style, error) {
+	style := tcell.StyleDefault
+	words := strings.Fields(spec)
+	if len(words) == 0 {
+		return style, fmt.Errorf("empty style")
+	}
+	haveFg := false
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		if attr, ok := styleAttributes[word]; ok {
+			_, _, attrs := style.Decompose()
+			style = style.Attributes(attrs | attr)
+			continue
+		}
+		if word == "on" {
+			if i+1 >= len(words) {
+				return style, fmt.Errorf("missing color after \"on\" in %q", spec)
+			}
+			i++
+			color, err := parseColor(words[i])
+			if err != nil {
+				return style, err
+			}
+			style = style.Background(color)
+			continue
+		}
+		if haveFg {
+			return style, fmt.Errorf("unexpected %q in style %q", words[i], spec)
+		}
+		color, err := parseColor(words[i])
+		if err != nil {
+			return style, err
+		}
+		style = style.Foreground(color)
+		haveFg = true
+	}
+	return style, nil
+}
+
+var styleAttributes = map[string]tcell.AttrMask{
+	"bold":          tcell.AttrBold,
+	"dim":           tcell.AttrDim,
+	"italic":        tcell.AttrItalic,
+	"underline":     tcell.AttrUnderline,
+	"reverse":       tcell.AttrReverse,
+	"blink":         tcell.AttrBlink,
+	"strikethrough": tcell.AttrStrikeThrough,
+}
+
+// overlay draws top's foreground and attributes over base.
+func overlay(base, top tcell.Style) tcell.Style {
+	fg, _, attrs := top.Decompose()
+	_, _, baseAttrs := base.Decompose()
+	if fg != tcell.ColorDefault {
+		base = base.Foreground(fg)
+	}
+	return base.Attributes(baseAttrs | attrs)
+}
+
+// applyColors applies the single-color overrides of a [colors] table.
+func (t *Theme) applyColors(c *ColorConfig) {
+	fg := func(style *tcell.Style, color tcell.Color) {
+		if color != tcell.ColorDefault {
+			*style = style.Foreground(color)
+		}
+	}
+	bg := func(style *tcell.Style, color tcell.Color) {
+		if color != tcell.ColorDefault {
+			*style = style.Background(color)
+		}
+	}
+	fg(&t.Directory, c.Directory)
+	fg(&t.File, c.File)
+	fg(&t.Hidden, c.Hidden)
+	fg(&t.Marked, c.Marked)
+	fg(&t.Selected, c.SelectedFg)
+	bg(&t.Selected, c.SelectedBg)
+	fg(&t.Bar, c.BarFg)
+	bg(&t.Bar, c.BarBg)
+	fg(&t.Search, c.SearchFg)
+	bg(&t.Search, c.SearchBg)
+	fg(&t.Error, c.ErrorFg)
+	bg(&t.Error, c.ErrorBg)
+}
+
+// tokenStyle maps a token kind to its preview style.
+func (t *Theme) tokenStyle(kind TokenKind) tcell.Style {
+	switch kind {
+	case TokenKeyword:
+		return t.Keyword
+	case TokenType:
+		return t.Type
+	case TokenString:
+		return t.String
+	case TokenNumber:
+		return t.Number
+	case TokenComment:
+		return t.Comment
+	case TokenKey:
+		return t.Key
+	case TokenHeading:
+		return t.Heading
+	case TokenTag:
+		return t.Tag
+	}
+	return t.File
+}
+
+// itemStyle is the style of an unselected, unmarked list entry.
+func (t *Theme) itemStyle(item FileItem) tcell.Style {
+	if style, ok := t.LSColors.styleFor(item); ok {
+		return style
+	}
+	switch {
+	case item.Broken:
+		return t.BrokenLink
+	case item.IsLink:
+		return t.Link
+	case item.IsDir:
+		return t.Directory
+	case item.IsHidden:
+		return t.Hidden
+	case item.Mode.IsRegular() && item.Mode&0111 != 0:
+		return t.Executable
+	}
+	return t.File
+}
+
+func (app *App) theme() *Theme {
+	return app.cfg().Theme
+}
+
+// LSColors is a parsed $LS_COLORS: styles per file type ("di", "ex", ...)
+// and per name suffix ("*.tar").
+type LSColors struct {
+	types    map[string]tcell.Style
+	suffixes []lsSuffix // longest first
+}
+
+type lsSuffix struct {
+	suffix string // lowercase
+	style  tcell.Style
+}
+
+// parseLSColors parses the dircolors format, skipping entries it cannot read.
+func parseLSColors(value string) *LSColors {
+	ls := &LSColors{types: make(map[string]tcell.Style)}
+	for _, entry := range strings.Split(value, ":") {
+		key, codes, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			continue
+		}
+		style, ok := parseSGR(codes)
+		if !ok {
+			continue
+		}
+		if suffix, isPattern := strings.CutPrefix(key, "*"); isPattern {
+			if suffix != "" {
+				ls.suffixes = append(ls.suffixes, lsSuffix{strings.ToLower(suffix), style})
+			}
+			continue
+		}
+		ls.types[key] = style
+	}
+	sort.SliceStable(ls.suffixes, func(i, j int) bool {
+		return len(ls.suffixes[i].suffix) > len(ls.suffixes[j].suffix)
+	})
+	if len(ls.types) == 0 && len(ls.suffixes) == 0 {
+		return nil
+	}
+	return ls
+}
+
+// styleFor follows ls: special file types first, then executables, then
+// name suffixes, then the generic "fi" style.
+func (ls *LSColors) styleFor(item FileItem) (tcell.Style, bool) {
+	if ls == nil {
+		return tcell.StyleDefault, false
+	}
+	var kind string
+	_, hasOrphan := ls.types["or"]
+	switch mode := item.Mode; {
+	case item.Broken && hasOrphan:
+		kind = "or"
+	case item.IsLink:
+		kind = "ln"
+	case item.IsDir:
+		kind = "di"
+	case mode&os.ModeNamedPipe != 0:
+		kind = "pi"
+	case mode&os.ModeSocket != 0:
+		kind = "so"
+	case mode&os.ModeCharDevice != 0:
+		kind = "cd"
+	case mode&os.ModeDevice != 0:
+		kind = "bd"
+	case mode&0111 != 0:
+		kind = "ex"
+	}
+	if kind != "" {
+		style, ok := ls.types[kind]
+		return style, ok
+	}
+
+	name := strings.ToLower(item.Name)
+	for _, s := range ls.suffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.style, true
+		}
+	}
+	style, ok := ls.types["fi"]
+	return style, ok
+}
+
+// parseSGR converts ANSI SGR codes such as "01;38;5;208" to a style.
+func parseSGR(codes string) (tcell.Style, bool) {
+	style := tcell.StyleDefault
+	var attrs tcell.AttrMask
+	parts := strings.Split(codes, ";")
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return style, false
+		}
+		switch {
+		case n == 0:
+			style, attrs = tcell.StyleDefault, 0
+		case n == 1:
+			attrs |= tcell.AttrBold
+		case n == 2:
+			attrs |= tcell.AttrDim
+		case n == 3:
+			attrs |= tcell.AttrItalic
+		case n == 4:
+			attrs |= tcell.AttrUnderline
+		case n == 5:
+			attrs |= tcell.AttrBlink
+		case n == 7:
+			attrs |= tcell.AttrReverse
+		case n >= 30 && n <= 37:
+			style = style.Foreground(tcell.PaletteColor(n - 30))
+		case n >= 90 && n <= 97:
+			style = style.Foreground(tcell.PaletteColor(n - 90 + 8))
+		case n >= 40 && n <= 47:
+			style = style.Background(tcell.PaletteColor(n - 40))
+		case n >= 100 && n <= 107:
+			style = style.Background(tcell.PaletteColor(n - 100 + 8))
+		case n == 38 || n == 48:
+			color, used, ok := parseExtendedColor(parts[i+1:])
+			if !ok {
+				return style, false
+			}
+			i += used
+			if n == 38 {
+				style = style.Foreground(color)
+			} else {
+				style = style.Background(color)
+			}
+		}
+	}
+	return style.Attributes(attrs), true
+}
+
+// parseExtendedColor reads "5;N" (256 colors) or "2;R;G;B" (truecolor).
+func parseExtendedColor(parts []string) (tcell.Color, int, bool) {
+	numbers := make([]int32, 0, 4)
+	for _, part := range parts {
+		if len(numbers) == 4 {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 255 {
+			break
+		}
+		numbers = append(numbers, int32(n))
+	}
+	switch {
+	case len(numbers) >= 2 && numbers[0] == 5:
+		return tcell.PaletteColor(int(numbers[1])), 2, true
+	case len(numbers) >= 4 && numbers[0] == 2:
+		return tcell.NewRGBColor(numbers[1], numbers[2], numbers[3]), 4, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by powpow's config: [tables],
+// [[arrays of tables]], and key = value pairs holding strings, integers,
+// floats, booleans and (possibly multi-line) arrays. Tables decode to
+// map[string]any and arrays to []any. Inline tables, multi-line strings
+// and dates are rejected with the line they appear on rather than being
+// misread.
+func parseTOML(data string) (map[string]any, error) {
+	root := make(map[string]any)
+	current := root
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			if !strings.HasSuffix(line, "]]") {
+				return nil, tomlErrorf(lineNo, "unterminated table header %q", line)
+			}
+			path, err := parseTOMLKey(strings.TrimSpace(line[2 : len(line)-2]))
+			if err != nil {
+				return nil, tomlErrorf(lineNo, "%v", err)
+			}
+			parent, err := tomlTable(root, path[:len(path)-1])
+			if err != nil {
+				return nil, tomlErrorf(lineNo, "%v", err)
+			}
+			last := path[len(path)-1]
+			array, _ := parent[last].([]any)
+			if _, exists := parent[last]; exists && !isTableArray(array) {
+				return nil, tomlErrorf(lineNo, "%s is not an array of tables", strings.Join(path, "."))
+			}
+			current = make(map[string]any)
+			parent[last] = append(array, current)
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, tomlErrorf(lineNo, "unterminated table header %q", line)
+			}
+			path, err := parseTOMLKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, tomlErrorf(lineNo, "%v", err)
+			}
+			current, err = tomlTable(root, path)
+			if err != nil {
+				return nil, tomlErrorf(lineNo, "%v", err)
+			}
+			continue
+		}
+
+		eq := indexOutsideQuotes(line, '=')
+		if eq < 0 {
+			return nil, tomlErrorf(lineNo, "expected key = value, got %q", line)
+		}
+		path, err := parseTOMLKey(strings.TrimSpace(line[:eq]))
+		if err != nil {
+			return nil, tomlErrorf(lineNo, "%v", err)
+		}
+
+		// Arrays may continue over several lines until their brackets balance
+		valueText := strings.TrimSpace(line[eq+1:])
+		startLine := lineNo
+		for !tomlBalanced(valueText) && i+1 < len(lines) {
+			i++
+			valueText += " " + strings.TrimSpace(stripTOMLComment(lines[i]))
+		}
+
+		value, rest, err := parseTOMLValue(valueText)
+		if err != nil {
+			return nil, tomlErrorf(startLine, "%v", err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, tomlErrorf(startLine, "unexpected %q after value", strings.TrimSpace(rest))
+		}
+
+		table, err := tomlTable(current, path[:len(path)-1])
+		if err != nil {
+			return nil, tomlErrorf(startLine, "%v", err)
+		}
+		key := path[len(path)-1]
+		if _, exists := table[key]; exists {
+			return nil, tomlErrorf(startLine, "duplicate key %s", strings.Join(path, "."))
+		}
+		table[key] = value
+	}
+	return root, nil
+}
+
+// isTableArray reports whether array was built by [[headers]]. Those are
+// the only arrays holding tables, since inline tables are not supported.
+func isTableArray(array []any) bool {
+	if len(array) == 0 {
+		return false
+	}
+	for _, element := range array {
+		if _, ok := element.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func tomlErrorf(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// tomlTable walks (creating as needed) the nested table at path.
+func tomlTable(root map[string]any, path []string) (map[string]any, error) {
+	table := root
+	for i, key := range path {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]any)
+			table[key] = created
+			table = created
+		case map[string]any:
+			table = next
+		case []any:
+			// [a.b] after [[a]] refers to the last element of a
+			if len(next) == 0 {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// parseTOMLKey splits a dotted key whose parts may be bare or quoted.
+func parseTOMLKey(text string) ([]string, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+	var parts []string
+	for text != "" {
+		var part string
+		if text[0] == '"' || text[0] == '\'' {
+			value, rest, err := parseTOMLString(text)
+			if err != nil {
+				return nil, err
+			}
+			part, text = value, strings.TrimSpace(rest)
+		} else {
+			end := strings.IndexByte(text, '.')
+			if end < 0 {
+				end = len(text)
+			}
+			part = strings.TrimSpace(text[:end])
+			text = text[end:]
+			for _, r := range part {
+				if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+					return nil, fmt.Errorf("invalid key %q", part)
+				}
+			}
+			if part == "" {
+				return nil, fmt.Errorf("empty key part")
+			}
+		}
+		parts = append(parts, part)
+		if text != "" {
+			if text[0] != '.' {
+				return nil, fmt.Errorf("invalid key near %q", text)
+			}
+			text = strings.TrimSpace(text[1:])
+		}
+	}
+	return parts, nil
+}
+
+func parseTOMLValue(text string) (any, string, error) {
+	text = strings.TrimLeft(text, " \t")
+	if text == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+
+	switch {
+	case text[0] == '"' || text[0] == '\'':
+		return parseTOMLString(text)
+
+	case text[0] == '{':
+		return nil, "", fmt.Errorf("inline tables are not supported; use a [table] header")
+
+	case text[0] == '[':
+		var array []any
+		text = strings.TrimLeft(text[1:], " \t")
+		for {
+			if strings.HasPrefix(text, "]") {
+				return array, text[1:], nil
+			}
+			value, rest, err := parseTOMLValue(text)
+			if err != nil {
+				return nil, "", err
+			}
+			array = append(array, value)
+			text = strings.TrimLeft(rest, " \t")
+			if strings.HasPrefix(text, ",") {
+				text = strings.TrimLeft(text[1:], " \t")
+			} else if !strings.HasPrefix(text, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+
+	case strings.HasPrefix(text, "true"):
+		return true, text[4:], nil
+
+	case strings.HasPrefix(text, "false"):
+		return false, text[5:], nil
+	}
+
+	end := strings.IndexAny(text, ",] \t")
+	if end < 0 {
+		end = len(text)
+	}
+	word := strings.ReplaceAll(text[:end], "_", "")
+	if n, err := strconv.ParseInt(word, 0, 64); err == nil {
+		return n, text[end:], nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, text[end:], nil
+	}
+	if isTOMLDateTime(word) {
+		return nil, "", fmt.Errorf("dates and times are not supported; quote %q as a string", text[:end])
+	}
+	return nil, "", fmt.Errorf("invalid value %q (strings must be quoted)", text[:end])
+}
+
+// isTOMLDateTime reports whether word starts like a TOML date (1979-05-27)
+// or time (07:32:00).
+func isTOMLDateTime(word string) bool {
+	digits := func(s string) bool {
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	date := len(word) >= 10 && word[4] == '-' && word[7] == '-' && digits(word[:4]) && digits(word[5:7]) && digits(word[8:10])
+	clock := len(word) >= 8 && word[2] == ':' && word[5] == ':' && digits(word[:2]) && digits(word[3:5]) && digits(word[6:8])
+	return date || clock
+}
+
+// parseTOMLString parses a basic ("...") or literal ('...') string.
+func parseTOMLString(text string) (string, string, error) {
+	quote := text[0]
+	if strings.HasPrefix(text, strings.Repeat(string(quote), 3)) {
+		return "", "", fmt.Errorf("multi-line strings are not supported")
+	}
+	if quote == '\'' {
+		end := strings.IndexByte(text[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '"':
+			return b.String(), text[i+1:], nil
+		case '\\':
+			if i+1 >= len(text) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(text[i])
+			case 'u', 'U':
+				size := 4
+				if text[i] == 'U' {
+					size = 8
+				}
+				if i+size >= len(text) {
+					return "", "", fmt.Errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(text[i+1:i+1+size], 16, 32)
+				if err != nil {
+					return "", "", fmt.Errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				i += size
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", text[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// stripTOMLComment removes a trailing # comment outside of strings.
+func stripTOMLComment(line string) string {
+	if i := indexOutsideQuotes(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func indexOutsideQuotes(line string, target byte) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == target:
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlBalanced reports whether every [ in text has been closed.
+func tomlBalanced(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
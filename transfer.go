@@ -0,0 +1,446 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type ClipMode int
+
+const (
+	ClipCopy ClipMode = iota
+	ClipCut
+)
+
+type Clipboard struct {
+	mode  ClipMode
+	paths []string
+}
+
+var errTransferCanceled = errors.New("transfer canceled")
+
+// TransferResult records where one source ended up.
+type TransferResult struct {
+	Source string
+	Dest   string
+}
+
+// Transfer copies or moves a set of paths into destDir. It runs on a
+// background goroutine; the UI reads progress through status().
+type Transfer struct {
+	mode    ClipMode
+	sources []string
+	destDir string
+
+	cancelCh   chan struct{}
+	cancelOnce sync.Once
+	notify     func()
+	lastNotify time.Time
+
+	mu      sync.Mutex
+	total   int64
+	done    int64
+	current string
+	results []TransferResult
+	skipped int
+}
+
+func NewTransfer(mode ClipMode, sources []string, destDir string) *Transfer {
+	return &Transfer{
+		mode:     mode,
+		sources:  sources,
+		destDir:  destDir,
+		cancelCh: make(chan struct{}),
+	}
+}
+
+func (t *Transfer) cancel() {
+	t.cancelOnce.Do(func() { close(t.cancelCh) })
+}
+
+func (t *Transfer) canceled() bool {
+	select {
+	case <-t.cancelCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *Transfer) verb() string {
+	if t.mode == ClipCut {
+		return "Moving"
+	}
+	return "Copying"
+}
+
+// status describes progress for the status bar.
+func (t *Transfer) status() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	percent := 100
+	if t.total > 0 {
+		percent = int(t.done * 100 / t.total)
+	}
+	return fmt.Sprintf("%s %d%% (%s / %s) %s",
+		t.verb(), percent, formatSize(t.done), formatSize(t.total), t.current)
+}
+
+func (t *Transfer) addProgress(n int64) {
+	t.mu.Lock()
+	t.done += n
+	t.mu.Unlock()
+
+	if t.notify != nil && time.Since(t.lastNotify) > 100*time.Millisecond {
+		t.lastNotify = time.Now()
+		t.notify()
+	}
+}
+
+// run performs the transfer. uniquePath resolves name conflicts in destDir.
+func (t *Transfer) run(uniquePath func(string) string) error {
+	var total int64
+	for _, src := range t.sources {
+		total += treeSize(src)
+	}
+	t.mu.Lock()
+	t.total = total
+	t.mu.Unlock()
+
+	for _, src := range t.sources {
+		if t.canceled() {
+			return errTransferCanceled
+		}
+
+		name := filepath.Base(src)
+		t.mu.Lock()
+		t.current = name
+		t.mu.Unlock()
+
+		if t.destDir == src || strings.HasPrefix(t.destDir, src+string(filepath.Separator)) {
+			return fmt.Errorf("cannot paste %s into itself", name)
+		}
+		if t.mode == ClipCut && filepath.Dir(src) == t.destDir {
+			t.mu.Lock()
+			t.skipped++
+			t.mu.Unlock()
+			t.addProgress(treeSize(src))
+			continue
+		}
+
+		dst := uniquePath(filepath.Join(t.destDir, name))
+		if err := t.transferOne(src, dst); err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		t.results = append(t.results, TransferResult{Source: src, Dest: dst})
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+func (t *Transfer) transferOne(src, dst string) error {
+	c := &copier{cancel: t.cancelCh, progress: t.addProgress}
+
+	if t.mode == ClipCut {
+		err := os.Rename(src, dst)
+		if err == nil {
+			t.addProgress(treeSize(dst))
+			return nil
+		}
+		if !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+	}
+
+	if err := c.copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	if t.mode == ClipCut {
+		return os.RemoveAll(src)
+	}
+	return nil
+}
+
+// treeSize sums the sizes of regular files below path.
+func treeSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// copier copies trees with optional progress reporting and cancellation.
+type copier struct {
+	cancel   <-chan struct{}
+	progress func(n int64)
+}
+
+func (c *copier) canceled() bool {
+	if c.cancel == nil {
+		return false
+	}
+	select {
+	case <-c.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// copyTree copies a file, symlink or directory tree, keeping mode bits and
+// mtimes. Pipes, sockets and devices are refused.
+func (c *copier) copyTree(src, dst string) error {
+	if c.canceled() {
+		return errTransferCanceled
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := c.copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+	case !info.Mode().IsRegular():
+		// Opening a pipe or a device could block or read forever
+		return fmt.Errorf("cannot copy %s: unsupported file type", src)
+
+	default:
+		if err := c.copyFile(src, dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func (c *copier) copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, 256*1024)
+	for {
+		if c.canceled() {
+			out.Close()
+			return errTransferCanceled
+		}
+		n, readErr := in.Read(buffer)
+		if n > 0 {
+			if _, err := out.Write(buffer[:n]); err != nil {
+				out.Close()
+				return err
+			}
+			if c.progress != nil {
+				c.progress(int64(n))
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			return readErr
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// Apply the exact mode bits, which the umask may have masked off on create
+	return os.Chmod(dst, perm)
+}
+
+// copyTree copies without progress or cancellation.
+func copyTree(src, dst string) error {
+	return (&copier{}).copyTree(src, dst)
+}
+
+// movePath renames src to dst, falling back to copy+remove across filesystems.
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// Clipboard actions
+
+func (app *App) yankItems(mode ClipMode) {
+	targets := app.navigator.targetItems()
+	if len(targets) == 0 {
+		return
+	}
+
+	paths := make([]string, len(targets))
+	for i, item := range targets {
+		paths[i] = item.Path
+	}
+	app.clipboard = &Clipboard{mode: mode, paths: paths}
+	app.navigator.clearMarks()
+
+	if mode == ClipCut {
+		app.statusBar.showMessage("Cut " + describeItems(targets) + " - p to paste")
+	} else {
+		app.statusBar.showMessage("Yanked " + describeItems(targets) + " - p to paste")
+	}
+}
+
+func (app *App) pasteItems() {
+	if app.clipboard == nil || len(app.clipboard.paths) == 0 {
+		app.statusBar.showError("Clipboard is empty")
+		return
+	}
+	if app.transfer != nil {
+		app.statusBar.showError("A transfer is already running")
+		return
+	}
+
+	transfer := NewTransfer(app.clipboard.mode, app.clipboard.paths, app.navigator.currentPath)
+	transfer.notify = app.wake
+	app.transfer = transfer
+	if transfer.mode == ClipCut {
+		// Cut items can only be pasted once; finishTransfer puts back any
+		// that did not move
+		app.clipboard = nil
+	}
+
+	go func() {
+		err := transfer.run(app.getUniqueFilePath)
+		app.postUI(func() {
+			app.finishTransfer(transfer, err)
+		})
+	}()
+}
+
+func (app *App) cancelTransfer() {
+	if app.transfer != nil {
+		app.transfer.cancel()
+	}
+}
+
+func (app *App) finishTransfer(transfer *Transfer, err error) {
+	app.transfer = nil
+	app.navigator.loadDirectory()
+
+	transfer.mu.Lock()
+	results := transfer.results
+	skipped := transfer.skipped
+	transfer.mu.Unlock()
+	count := len(results)
+
+	kind, verb := OpCopy, "Copied"
+	if transfer.mode == ClipCut {
+		kind, verb = OpMove, "Moved"
+	}
+	journal := make([]JournalItem, len(results))
+	for i, result := range results {
+		journal[i] = JournalItem{From: result.Source, To: result.Dest}
+	}
+	app.recordOp(kind, journal)
+	if transfer.mode == ClipCut && err != nil && app.clipboard == nil {
+		app.clipboard = unmovedClipboard(transfer.sources, results)
+	}
+
+	switch {
+	case errors.Is(err, errTransferCanceled):
+		app.statusBar.showMessage(fmt.Sprintf("Transfer canceled (%d of %d done)", count, len(transfer.sources)))
+	case err != nil:
+		app.statusBar.showError(fmt.Sprintf("Transfer failed (%d of %d done): %v", count, len(transfer.sources), err))
+	case skipped > 0:
+		app.statusBar.showMessage(fmt.Sprintf("%s %d items (%d already here)", verb, count, skipped))
+	default:
+		app.statusBar.showMessage(fmt.Sprintf("%s %d items", verb, count))
+	}
+}
+
+// unmovedClipboard is the clipboard left after a cut paste stopped early:
+// the sources still in place, so they can be pasted again. It is nil when
+// every source moved.
+func unmovedClipboard(sources []string, results []TransferResult) *Clipboard {
+	var paths []string
+	for _, src := range sources {
+		moved := slices.ContainsFunc(results, func(result TransferResult) bool { return result.Source == src })
+		if _, err := os.Lstat(src); err == nil && !moved {
+			paths = append(paths, src)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return &Clipboard{mode: ClipCut, paths: paths}
+}
+
+// uiEvent runs fn on the UI goroutine. Background workers post it to
+// hand results back to the run loop; a nil fn just wakes it to redraw.
+type uiEvent struct {
+	tcell.EventTime
+	fn func()
+}
+
+// postUI schedules fn on the run loop, waiting for room in the event queue.
+func (app *App) postUI(fn func()) {
+	ev := &uiEvent{fn: fn}
+	ev.SetEventNow()
+	app.screen.PostEventWait(ev)
+}
+
+// wake requests a redraw; it never blocks and may be dropped when the queue is full.
+func (app *App) wake() {
+	ev := &uiEvent{}
+	ev.SetEventNow()
+	app.screen.PostEvent(ev)
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Trash implements the freedesktop.org Trash specification: items live in
+// <dir>/files and each has a matching <dir>/info/<name>.trashinfo. The home
+// trash hands items on other filesystems to the trash of their volume, so
+// trashing never copies across devices.
+type Trash struct {
+	dir    string
+	topdir string          // the volume a per-volume trash serves; empty for the home trash
+	mounts func() []string // where the home trash looks for per-volume trashes
+}
+
+type TrashEntry struct {
+	Name         string // name inside the trash files directory
+	TrashDir     string // the trash holding it; empty for the home trash
+	OriginalPath string
+	DeletionDate time.Time
+	IsDir        bool
+	Size         int64
+}
+
+const trashDateFormat = "2006-01-02T15:04:05"
+
+func defaultTrashDir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash")
+}
+
+func NewTrash(dir string) *Trash {
+	return &Trash{dir: dir, mounts: mountPoints}
+}
+
+func (t *Trash) filesDir() string {
+	return filepath.Join(t.dir, "files")
+}
+
+func (t *Trash) infoDir() string {
+	return filepath.Join(t.dir, "info")
+}
+
+func (t *Trash) infoPath(name string) string {
+	return filepath.Join(t.infoDir(), name+".trashinfo")
+}
+
+// filePath returns where a trashed entry currently lives on disk.
+func (t *Trash) filePath(name string) string {
+	return filepath.Join(t.filesDir(), name)
+}
+
+// put moves path into the trash of its volume and returns the entry
+// describing it.
+func (t *Trash) put(path string) (TrashEntry, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return TrashEntry{}, err
+	}
+	target, err := t.trashFor(absPath, info)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	return target.putHere(absPath, info)
+}
+
+// trashFor picks the trash for an item: the home trash when they share a
+// filesystem, otherwise the trash at the top of the item's volume.
+func (t *Trash) trashFor(path string, info os.FileInfo) (*Trash, error) {
+	homeInfo, err := os.Stat(t.dir)
+	if t.topdir != "" || err != nil {
+		return t, nil
+	}
+	device, ok := fileDevice(info)
+	homeDevice, homeOK := fileDevice(homeInfo)
+	if !ok || !homeOK || device == homeDevice {
+		return t, nil
+	}
+	return volumeTrash(volumeTop(path, device))
+}
+
+// volumeTop is the highest directory above path still on device: the
+// mount point of its filesystem.
+func volumeTop(path string, device uint64) string {
+	top := path
+	for parent := filepath.Dir(top); parent != top; parent = filepath.Dir(top) {
+		info, err := os.Stat(parent)
+		if err != nil {
+			break
+		}
+		if parentDevice, ok := fileDevice(info); !ok || parentDevice != device {
+			break
+		}
+		top = parent
+	}
+	return top
+}
+
+// volumeTrash opens the trash of the volume at topdir, creating it if
+// needed: $topdir/.Trash/$uid when the administrator provided a shared,
+// sticky .Trash, else $topdir/.Trash-$uid.
+func volumeTrash(topdir string) (*Trash, error) {
+	uid := strconv.Itoa(os.Getuid())
+	if dir, ok := sharedVolumeTrash(topdir, uid); ok {
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return &Trash{dir: dir, topdir: topdir}, nil
+		}
+	}
+	dir := filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("no trash on the volume at %s: %v", topdir, err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a usable trash directory", dir)
+	}
+	return &Trash{dir: dir, topdir: topdir}, nil
+}
+
+// sharedVolumeTrash is $topdir/.Trash/$uid, if $topdir/.Trash passes the
+// spec's checks: a real directory with the sticky bit set.
+func sharedVolumeTrash(topdir, uid string) (string, bool) {
+	shared := filepath.Join(topdir, ".Trash")
+	info, err := os.Lstat(shared)
+	if err != nil || !info.IsDir() || info.Mode()&os.ModeSticky == 0 {
+		return "", false
+	}
+	return filepath.Join(shared, uid), true
+}
+
+// volumeTrashes finds the per-volume trashes that already exist.
+func (t *Trash) volumeTrashes() []*Trash {
+	if t.mounts == nil {
+		return nil
+	}
+	uid := strconv.Itoa(os.Getuid())
+	var trashes []*Trash
+	var seen []os.FileInfo // a filesystem mounted twice has one trash
+	for _, topdir := range t.mounts() {
+		candidates := []string{filepath.Join(topdir, ".Trash-"+uid)}
+		if dir, ok := sharedVolumeTrash(topdir, uid); ok {
+			candidates = append(candidates, dir)
+		}
+		for _, dir := range candidates {
+			info, err := os.Lstat(dir)
+			if err != nil || !info.IsDir() || dir == t.dir || slices.ContainsFunc(seen, func(s os.FileInfo) bool { return os.SameFile(s, info) }) {
+				continue
+			}
+			seen = append(seen, info)
+			trashes = append(trashes, &Trash{dir: dir, topdir: topdir})
+		}
+	}
+	return trashes
+}
+
+// holding returns the trash an entry lives in.
+func (t *Trash) holding(entry TrashEntry) *Trash {
+	if entry.TrashDir == "" || entry.TrashDir == t.dir {
+		return t
+	}
+	return &Trash{dir: entry.TrashDir}
+}
+
+// putHere moves path into this trash.
+func (t *Trash) putHere(absPath string, info os.FileInfo) (TrashEntry, error) {
+	if err := os.MkdirAll(t.filesDir(), 0700); err != nil {
+		return TrashEntry{}, err
+	}
+	if err := os.MkdirAll(t.infoDir(), 0700); err != nil {
+		return TrashEntry{}, err
+	}
+
+	now := time.Now()
+	name, infoFile, err := t.reserveName(filepath.Base(absPath))
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	// Volume trashes record paths relative to the volume, so they stay
+	// right wherever it is mounted
+	recorded := absPath
+	if t.topdir != "" {
+		if rel, err := filepath.Rel(t.topdir, absPath); err == nil {
+			recorded = rel
+		}
+	}
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: recorded}).EscapedPath(), now.Format(trashDateFormat))
+	_, err = infoFile.WriteString(content)
+	infoFile.Close()
+	if err != nil {
+		os.Remove(t.infoPath(name))
+		return TrashEntry{}, err
+	}
+
+	if err := movePath(absPath, t.filePath(name)); err != nil {
+		os.Remove(t.infoPath(name))
+		return TrashEntry{}, err
+	}
+
+	return TrashEntry{
+		Name:         name,
+		TrashDir:     t.trashDir(),
+		OriginalPath: absPath,
+		DeletionDate: now,
+		IsDir:        info.IsDir(),
+		Size:         info.Size(),
+	}, nil
+}
+
+// reserveName claims a unique name by exclusively creating its .trashinfo file,
+// as the spec requires, so concurrent trashers never collide.
+func (t *Trash) reserveName(base string) (string, *os.File, error) {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		stem, ext = base, ""
+	}
+
+	name := base
+	for counter := 1; ; counter++ {
+		if _, err := os.Lstat(t.filePath(name)); os.IsNotExist(err) {
+			f, err := os.OpenFile(t.infoPath(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err == nil {
+				return name, f, nil
+			}
+			if !os.IsExist(err) {
+				return "", nil, err
+			}
+		}
+		name = fmt.Sprintf("%s.%d%s", stem, counter, ext)
+	}
+}
+
+// trashDir is the TrashEntry.TrashDir of entries here.
+func (t *Trash) trashDir() string {
+	if t.topdir == "" {
+		return ""
+	}
+	return t.dir
+}
+
+// list returns all entries in the home trash and the volume trashes,
+// newest first.
+func (t *Trash) list() ([]TrashEntry, error) {
+	entries, err := t.listHere()
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range t.volumeTrashes() {
+		// An unreadable volume trash just isn't shown
+		more, _ := volume.listHere()
+		entries = append(entries, more...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletionDate.After(entries[j].DeletionDate)
+	})
+	return entries, nil
+}
+
+func (t *Trash) listHere() ([]TrashEntry, error) {
+	infos, err := os.ReadDir(t.infoDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := make([]TrashEntry, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".trashinfo") {
+			continue
+		}
+		name := strings.TrimSuffix(info.Name(), ".trashinfo")
+		entry, err := t.readInfo(name)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (t *Trash) readInfo(name string) (TrashEntry, error) {
+	f, err := os.Open(t.infoPath(name))
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	defer f.Close()
+
+	entry := TrashEntry{Name: name, TrashDir: t.trashDir()}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				entry.OriginalPath = unescaped
+			} else {
+				entry.OriginalPath = value
+			}
+		case "DeletionDate":
+			if date, err := time.ParseInLocation(trashDateFormat, value, time.Local); err == nil {
+				entry.DeletionDate = date
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TrashEntry{}, err
+	}
+	if entry.OriginalPath == "" {
+		return TrashEntry{}, errors.New("trashinfo has no Path")
+	}
+	if !filepath.IsAbs(entry.OriginalPath) {
+		entry.OriginalPath = filepath.Join(t.topdir, entry.OriginalPath)
+	}
+
+	if info, err := os.Lstat(t.filePath(name)); err == nil {
+		entry.IsDir = info.IsDir()
+		entry.Size = info.Size()
+	}
+	return entry, nil
+}
+
+// restoreTo moves a trashed entry to target and drops its metadata.
+func (t *Trash) restoreTo(entry TrashEntry, target string) error {
+	t = t.holding(entry)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := movePath(t.filePath(entry.Name), target); err != nil {
+		return err
+	}
+	return os.Remove(t.infoPath(entry.Name))
+}
+
+// purge permanently removes a trashed entry.
+func (t *Trash) purge(entry TrashEntry) error {
+	t = t.holding(entry)
+	if err := os.RemoveAll(t.filePath(entry.Name)); err != nil {
+		return err
+	}
+	return os.Remove(t.infoPath(entry.Name))
+}
+
+// Trash browser view
+
+type TrashView struct {
+	entries      []TrashEntry
+	selectedIdx  int
+	scrollOffset int
+}
+
+func (v *TrashView) clampSelection() {
+	if v.selectedIdx >= len(v.entries) {
+		v.selectedIdx = len(v.entries) - 1
+	}
+	if v.selectedIdx < 0 {
+		v.selectedIdx = 0
+	}
+}
+
+func (v *TrashView) getSelectedEntry() *TrashEntry {
+	if len(v.entries) == 0 || v.selectedIdx < 0 || v.selectedIdx >= len(v.entries) {
+		return nil
+	}
+	return &v.entries[v.selectedIdx]
+}
+
+func (app *App) openTrash() {
+	app.trashMode = true
+	app.trashView = TrashView{}
+	app.reloadTrash()
+}
+
+func (app *App) reloadTrash() {
+	entries, err := app.trash.list()
+	if err != nil {
+		app.statusBar.showError("Cannot read trash: " + err.Error())
+	}
+	app.trashView.entries = entries
+	app.trashView.clampSelection()
+}
+
+func (app *App) restoreTrashEntry() {
+	entry := app.trashView.getSelectedEntry()
+	if entry == nil {
+		return
+	}
+
+	target := app.getUniqueFilePath(entry.OriginalPath)
+	if err := app.trash.restoreTo(*entry, target); err != nil {
+		app.statusBar.showError("Cannot restore: " + err.Error())
+		return
+	}
+
+	app.reloadTrash()
+	app.navigator.loadDirectory()
+	if target != entry.OriginalPath {
+		app.statusBar.showMessage(fmt.Sprintf("Restored: %s (auto-renamed)", target))
+	} else {
+		app.statusBar.showMessage("Restored: " + target)
+	}
+}
+
+func (app *App) purgeTrashEntry(entry TrashEntry) {
+	if err := app.trash.purge(entry); err != nil {
+		app.statusBar.showError("Cannot purge: " + err.Error())
+		return
+	}
+	app.reloadTrash()
+	app.statusBar.showMessage("Permanently deleted: " + filepath.Base(entry.OriginalPath))
+}
+
+func (app *App) emptyTrash() {
+	count := 0
+	for _, entry := range app.trashView.entries {
+		if err := app.trash.purge(entry); err != nil {
+			app.reloadTrash()
+			app.statusBar.showError("Cannot empty trash: " + err.Error())
+			return
+		}
+		count++
+	}
+	app.reloadTrash()
+	app.statusBar.showMessage(fmt.Sprintf("Emptied trash (%d items)", count))
+}
+
+func (app *App) drawTrash() {
+	theme := app.theme()
+	style := theme.Bar
+	for i := 0; i < app.width; i++ {
+		app.screen.SetContent(i, 0, ' ', nil, style)
+	}
+	keys := app.keys()
+	app.drawText(1, 0, fmt.Sprintf("Trash (%d items) - %s:restore %s:delete forever %s:empty %s:back", len(app.trashView.entries),
+		keys.hint("trash.restore"), keys.hint("trash.purge"), keys.hint("trash.empty"), keys.hint("trash.close")), style)
+
+	view := &app.trashView
+	maxItems := app.height - 2
+	if view.selectedIdx >= view.scrollOffset+maxItems {
+		view.scrollOffset = view.selectedIdx - maxItems + 1
+	}
+	if view.selectedIdx < view.scrollOffset {
+		view.scrollOffset = view.selectedIdx
+	}
+
+	if len(view.entries) == 0 {
+		app.drawText(2, 1, "Trash is empty", theme.Dim)
+		return
+	}
+
+	for i := 0; i < maxItems && i+view.scrollOffset < len(view.entries); i++ {
+		entryIdx := i + view.scrollOffset
+		entry := view.entries[entryIdx]
+		y := 1 + i
+
+		var style tcell.Style
+		prefix := "  "
+		if entryIdx == view.selectedIdx {
+			style = theme.Selected
+			prefix = "> "
+			for j := 0; j < app.width; j++ {
+				app.screen.SetContent(j, y, ' ', nil, style)
+			}
+		} else if entry.IsDir {
+			style = theme.Directory
+		} else {
+			style = theme.File
+		}
+
+		text := prefix + entry.DeletionDate.Format("2006-01-02 15:04") + "  " + entry.OriginalPath
+		if entry.IsDir {
+			text += "/"
+		}
+		if len(text) > app.width-1 {
+			text = text[:app.width-4] + "..."
+		}
+		app.drawText(0, y, text, style)
+	}
+}
+
+func (app *App) moveTrashSelection(delta int) {
+	app.trashView.selectedIdx += delta
+	app.trashView.clampSelection()
+}
+
+func (app *App) closeTrash() {
+	app.trashMode = false
+	app.navigator.loadDirectory()
+}
+
+func (app *App) showTrashPopup(popupType PopupType, entry *TrashEntry) {
+	app.showPopup(popupType, "Delete Forever", "", "", nil)
+	if entry != nil {
+		target := *entry
+		app.popup.targetTrash = &target
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileDevice reports nothing here, so everything goes to the home trash.
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func mountPoints() []string {
+	return nil
+}
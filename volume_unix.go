@@ -0,0 +1,64 @@
+//go:build unix
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// fileDevice returns the device holding the file described by info.
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), true
+	}
+	return 0, false
+}
+
+// pseudoFilesystems hold no user files, so they never have a trash.
+var pseudoFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "cgroup": true, "cgroup2": true,
+	"mqueue": true, "securityfs": true, "debugfs": true, "tracefs": true, "pstore": true, "bpf": true,
+	"autofs": true, "hugetlbfs": true, "fusectl": true, "configfs": true, "binfmt_misc": true,
+}
+
+// mountPoints lists where filesystems are mounted, skipping the kernel's
+// own. It is empty where /proc/self/mounts doesn't exist.
+func mountPoints() []string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var points []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || pseudoFilesystems[fields[2]] {
+			continue
+		}
+		points = append(points, unescapeMount(fields[1]))
+	}
+	return points
+}
+
+// unescapeMount decodes the octal escapes, such as \040 for a space, used
+// in the mount table.
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// watchDebounce is how long a burst of changes is collected before the
+// listing refreshes, so a build writing hundreds of files reloads once.
+const watchDebounce = 150 * time.Millisecond
+
+// watchEvent tells the run loop that dir changed on disk.
+type watchEvent struct {
+	tcell.EventTime
+	dir string
+}
+
+// watchBackend watches a single directory, calling changed for every
+// change to it. Each platform provides newWatchBackend.
+type watchBackend interface {
+	watch(dir string) error
+	close() error
+}
+
+// DirWatcher follows the current directory and reports changes to it,
+// coalescing bursts into one notification per watchDebounce.
+type DirWatcher struct {
+	backend   watchBackend
+	notify    func(dir string)
+	dir       string
+	mu        sync.Mutex
+	scheduled bool
+}
+
+func NewDirWatcher(notify func(dir string)) (*DirWatcher, error) {
+	w := &DirWatcher{notify: notify}
+	backend, err := newWatchBackend(w.changed)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = backend
+	return w, nil
+}
+
+// changed schedules a notification for the watched directory. The timer
+// reports whichever directory is watched when it fires, so a burst that
+// starts before the watch moves still refreshes the new directory.
+func (w *DirWatcher) changed(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Events still queued for a directory we have left
+	if dir != w.dir || w.scheduled {
+		return
+	}
+	w.scheduled = true
+	time.AfterFunc(watchDebounce, func() {
+		w.mu.Lock()
+		w.scheduled = false
+		dir := w.dir
+		w.mu.Unlock()
+		w.notify(dir)
+	})
+}
+
+// watch moves the watch to dir; watching the same directory again is a no-op.
+func (w *DirWatcher) watch(dir string) error {
+	w.mu.Lock()
+	if dir == w.dir {
+		w.mu.Unlock()
+		return nil
+	}
+	w.dir = dir
+	w.mu.Unlock()
+	return w.backend.watch(dir)
+}
+
+func (w *DirWatcher) close() error {
+	return w.backend.close()
+}
+
+// syncWatcher points the watcher at the directory being shown.
+func (app *App) syncWatcher() {
+	if app.watcher != nil {
+		// An unwatchable directory just doesn't refresh by itself
+		app.watcher.watch(app.navigator.currentPath)
+	}
+}
+
+// refreshFromDisk reloads the listing after dir changed, keeping the
+// selection. When the directory itself is gone it moves up to the
+// nearest one that still exists.
+func (app *App) refreshFromDisk(dir string) {
+	nav := app.navigator
+	if dir != nav.currentPath {
+		return
+	}
+	if _, err := os.Stat(dir); err == nil && nav.loading() {
+		nav.refreshWhenLoaded()
+		return
+	}
+	if err := nav.reload(); err == nil {
+		return
+	}
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		if nav.changeDirectory(parent) == nil {
+			app.statusBar.showError(dir + " was removed")
+			return
+		}
+		if parent == filepath.Dir(parent) {
+			return
+		}
+	}
+}
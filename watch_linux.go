@@ -0,0 +1,78 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyBackend watches with inotify. The descriptor is non-blocking and
+// wrapped in an os.File, so reads park in the runtime poller and Close
+// unblocks them.
+type inotifyBackend struct {
+	file    *os.File
+	fd      int
+	changed func(dir string)
+	mu      sync.Mutex
+	wd      int // current watch, -1 for none
+	dir     string
+}
+
+func newWatchBackend(changed func(dir string)) (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	b := &inotifyBackend{file: os.NewFile(uintptr(fd), "inotify"), fd: fd, changed: changed, wd: -1}
+	go b.readEvents()
+	return b, nil
+}
+
+func (b *inotifyBackend) watch(dir string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.wd >= 0 {
+		syscall.InotifyRmWatch(b.fd, uint32(b.wd))
+		b.wd = -1
+	}
+	wd, err := syscall.InotifyAddWatch(b.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	b.wd, b.dir = wd, dir
+	return nil
+}
+
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
+
+func (b *inotifyBackend) readEvents() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return // closed
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			// struct inotify_event: int32 wd, uint32 mask, cookie, len, then the name
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			nameLen := binary.NativeEndian.Uint32(buf[offset+12:])
+			offset += syscall.SizeofInotifyEvent + int(nameLen)
+
+			b.mu.Lock()
+			current, dir := int32(b.wd) == wd, b.dir
+			b.mu.Unlock()
+			// Events still queued for a directory we moved away from are dropped
+			if current {
+				b.changed(dir)
+			}
+		}
+	}
+}
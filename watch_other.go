@@ -0,0 +1,72 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const pollInterval = time.Second
+
+// pollBackend checks the directory's modification time once a second.
+// Creating, removing and renaming entries update it; edits to existing
+// files don't, so those show on the next refresh.
+type pollBackend struct {
+	changed func(dir string)
+	mu      sync.Mutex
+	dir     string
+	modTime time.Time
+	stop    chan struct{}
+}
+
+func newWatchBackend(changed func(dir string)) (watchBackend, error) {
+	b := &pollBackend{changed: changed, stop: make(chan struct{})}
+	go b.poll()
+	return b, nil
+}
+
+func (b *pollBackend) watch(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.dir, b.modTime = dir, info.ModTime()
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *pollBackend) close() error {
+	close(b.stop)
+	return nil
+}
+
+func (b *pollBackend) poll() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		b.mu.Lock()
+		dir, modTime := b.dir, b.modTime
+		b.mu.Unlock()
+		if dir == "" {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err == nil && info.ModTime().Equal(modTime) {
+			continue
+		}
+		b.mu.Lock()
+		if b.dir == dir && err == nil {
+			b.modTime = info.ModTime()
+		}
+		b.mu.Unlock()
+		b.changed(dir)
+	}
+}